@@ -7,6 +7,7 @@ import (
 	"github.com/FPGSchiba/vcs-srs-server/control"
 	"github.com/FPGSchiba/vcs-srs-server/events"
 	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/FPGSchiba/vcs-srs-server/transmissions"
 	"github.com/FPGSchiba/vcs-srs-server/voice"
 	"github.com/google/uuid"
 	"github.com/wailsapp/wails/v3/pkg/application"
@@ -26,6 +27,7 @@ type VCSApplication struct {
 	controlServer     *control.Server // Add this
 	StopSignals       map[string]chan struct{}
 	eventBus          *events.EventBus // Event bus for handling events
+	transmissionLog   *transmissions.Log
 	App               *application.App
 	Logger            *slog.Logger // Optional Logger, only used in headless mode
 }
@@ -47,13 +49,11 @@ func New() *VCSApplication {
 	}
 	// Subscribe to the notification event to log notifications
 	notChan := app.eventBus.Subscribe(events.NotificationEvent)
-	allChan := app.eventBus.Subscribe("*")
-	go app.handleFrontendEmits(allChan)
 	go app.handleNotificationEvent(notChan)
 	return app
 }
 
-func (a *VCSApplication) StartUp(app *application.App, configFilePath, bannedFilePath string, autoStartServers bool) {
+func (a *VCSApplication) StartUp(app *application.App, configFilePath, bannedFilePath, muteHistoryFilePath string, autoStartServers bool) {
 	settingsState, err := state.GetSettingsState(configFilePath)
 	if err != nil {
 		app.Logger.Error("Failed to load settings", "error", err)
@@ -91,10 +91,17 @@ func (a *VCSApplication) StartUp(app *application.App, configFilePath, bannedFil
 		}
 	}
 
+	muteHistory, err := state.GetMuteHistoryState(muteHistoryFilePath)
+	if err != nil {
+		app.Logger.Error("Failed to load mute history", "error", err)
+		panic(err)
+	}
+
 	serverState := &state.ServerState{
 		Clients:      make(map[uuid.UUID]*state.ClientState),
 		RadioClients: make(map[uuid.UUID]*state.RadioState),
 		BannedState:  *bannedState,
+		MuteHistory:  *muteHistory,
 	}
 
 	distributionState := &state.DistributionState{
@@ -109,13 +116,17 @@ func (a *VCSApplication) StartUp(app *application.App, configFilePath, bannedFil
 	a.autoStart = autoStartServers
 	a.Logger = app.Logger
 	a.App = app
+	go a.handleFrontendEmits(a.eventBus.Subscribe("*"))
+	a.transmissionLog = transmissions.NewLog(settingsState, app.Logger)
+	go a.transmissionLog.Run(nil)
+	go a.muteExpiryRoutine()
 
 	if autoStartServers {
 		a.StartStandaloneServer()
 	}
 }
 
-func (a *VCSApplication) HeadlessStartup(logger *slog.Logger, configFilePath, bannedFilePath string, distributionMode uint8) {
+func (a *VCSApplication) HeadlessStartup(logger *slog.Logger, configFilePath, bannedFilePath, muteHistoryFilePath string, distributionMode uint8) {
 	settingsState, err := state.GetSettingsState(configFilePath)
 	if err != nil {
 		panic(err) // Without settings, we can't run
@@ -150,10 +161,16 @@ func (a *VCSApplication) HeadlessStartup(logger *slog.Logger, configFilePath, ba
 		}
 	}
 
+	muteHistory, err := state.GetMuteHistoryState(muteHistoryFilePath)
+	if err != nil {
+		panic(err)
+	}
+
 	serverState := &state.ServerState{
 		Clients:      make(map[uuid.UUID]*state.ClientState),
 		RadioClients: make(map[uuid.UUID]*state.RadioState),
 		BannedState:  *bannedState,
+		MuteHistory:  *muteHistory,
 	}
 
 	distributionState := &state.DistributionState{
@@ -168,6 +185,9 @@ func (a *VCSApplication) HeadlessStartup(logger *slog.Logger, configFilePath, ba
 	a.autoStart = true
 	a.Logger = logger
 	a.App = nil // No application context in headless mode
+	a.transmissionLog = transmissions.NewLog(settingsState, logger)
+	go a.transmissionLog.Run(nil)
+	go a.muteExpiryRoutine()
 
 	switch distributionMode {
 	case state.DistributionModeStandalone:
@@ -294,13 +314,12 @@ func (a *VCSApplication) handleNotificationEvent(channel chan events.Event) {
 	}
 }
 
+// handleFrontendEmits forwards all events of the event bus to the frontend, it is only started in GUI mode
 func (a *VCSApplication) handleFrontendEmits(channel chan events.Event) {
-	a.DistributionState.RLock()
-	if a.DistributionState.RuntimeMode == state.RuntimeModeGUI {
-		a.DistributionState.RUnlock()
-		for event := range channel {
-			a.App.Event.EmitEvent(&application.CustomEvent{Name: event.Name, Data: event.Data})
+	for event := range channel {
+		if event.Name == events.NotificationEvent {
+			continue // Emitted by handleNotificationEvent
 		}
+		a.App.Event.EmitEvent(&application.CustomEvent{Name: event.Name, Data: event.Data})
 	}
-	a.DistributionState.RUnlock() // In headless mode, we don't emit events to the frontend
 }
@@ -1,15 +1,23 @@
 package app
 
 import (
+	"time"
+
 	"github.com/FPGSchiba/vcs-srs-server/events"
 	"github.com/FPGSchiba/vcs-srs-server/state"
 	"github.com/FPGSchiba/vcs-srs-server/utils"
 	"github.com/google/uuid"
 )
 
+const (
+	guiAdminName       = "Server GUI" // Recorded as the admin for actions taken in the GUI
+	muteExpiryInterval = time.Second
+)
+
 // Clients is a workaround struct for wails to generate the wanted bindings
 type Clients struct {
-	Clients map[string]state.ClientState
+	Clients    map[string]state.ClientState
+	Spectators map[string]state.ClientState // Listen-only clients, they are not part of Clients
 }
 
 // RadioClients is a workaround struct for wails to generate the wanted bindings
@@ -33,10 +41,15 @@ func (a *VCSApplication) GetClients() Clients {
 	a.ServerState.Lock()
 	defer a.ServerState.Unlock()
 	clients := make(map[string]state.ClientState, len(a.ServerState.Clients))
+	spectators := make(map[string]state.ClientState)
 	for k, v := range a.ServerState.Clients {
-		clients[k.String()] = *v
+		if v.Spectator {
+			spectators[k.String()] = *v
+		} else {
+			clients[k.String()] = *v
+		}
 	}
-	return Clients{clients}
+	return Clients{Clients: clients, Spectators: spectators}
 }
 
 func (a *VCSApplication) GetBannedClients() []state.BannedClient {
@@ -46,16 +59,16 @@ func (a *VCSApplication) GetBannedClients() []state.BannedClient {
 }
 
 func (a *VCSApplication) BanClient(clientId string, reason string) { // TODO: Implement the Backend Logic to ban a client
-	a.ServerState.Lock()
-	defer a.ServerState.Unlock()
 	clientGuid, err := uuid.Parse(clientId)
 	if err != nil {
 		a.Notify(events.NewNotification("Ban failed", "Invalid client ID format", "error"))
 		a.Logger.Error("Failed to parse client ID", "clientId", clientId, "error", err)
 		return
 	}
+	a.ServerState.Lock()
 	client, ok := a.ServerState.Clients[clientGuid]
 	if !ok {
+		a.ServerState.Unlock()
 		a.Notify(events.NewNotification("Ban failed", "Client not found", "error"))
 		a.Logger.Error("Failed to ban client", "clientId", clientId, "reason", reason)
 		return
@@ -67,12 +80,13 @@ func (a *VCSApplication) BanClient(clientId string, reason string) { // TODO: Im
 		ID:        clientId,
 	})
 	err = a.ServerState.BannedState.Save()
+	a.ServerState.Unlock()
 	if err != nil {
 		a.Notify(events.NewNotification("Ban failed", "Failed to save banned clients", "error"))
 		a.Logger.Error("Failed to save banned clients", "error", err)
 		return
 	}
-	delete(a.ServerState.Clients, clientGuid)
+	a.ServerState.RemoveClient(clientGuid)
 	a.EmitEvent(events.Event{
 		Name: events.ClientsChanged,
 		Data: a.ServerState.Clients,
@@ -115,15 +129,13 @@ func (a *VCSApplication) UnbanClient(clientId string) {
 }
 
 func (a *VCSApplication) KickClient(clientId string, reason string) { // TODO: Implement Backend Logic to kick a client
-	a.ServerState.Lock()
-	defer a.ServerState.Unlock()
 	clientGuid, err := uuid.Parse(clientId)
 	if err != nil {
 		a.Notify(events.NewNotification("Kick failed", "Invalid client ID format", "error"))
 		a.Logger.Error("Failed to parse client ID", "clientId", clientId, "error", err)
 		return
 	}
-	delete(a.ServerState.Clients, clientGuid)
+	a.ServerState.RemoveClient(clientGuid)
 	a.EmitEvent(events.Event{
 		Name: events.ClientsChanged,
 		Data: a.ServerState.Clients,
@@ -132,54 +144,98 @@ func (a *VCSApplication) KickClient(clientId string, reason string) { // TODO: I
 	a.Logger.Info("Client kicked", "clientId", clientId, "reason", reason)
 }
 
-func (a *VCSApplication) MuteClient(clientId string) { // TODO: Implement Backend Logic to mute a client and notify the Client
-	a.ServerState.Lock()
-	defer a.ServerState.Unlock()
+// MuteClient mutes the client for the duration in seconds, 0 mutes it until it is unmuted
+func (a *VCSApplication) MuteClient(clientId string, reason string, duration int64) {
 	clientGuid, err := uuid.Parse(clientId)
 	if err != nil {
-		a.Notify(events.NewNotification("Kick failed", "Invalid client ID format", "error"))
+		a.Notify(events.NewNotification("Mute failed", "Invalid client ID format", "error"))
 		a.Logger.Error("Failed to parse client ID", "clientId", clientId, "error", err)
 		return
 	}
-	client, ok := a.ServerState.RadioClients[clientGuid]
-	if !ok {
+	record, err := a.ServerState.MuteClient(clientGuid, guiAdminName, reason, time.Duration(duration)*time.Second)
+	if err != nil {
 		a.Notify(events.NewNotification("Mute failed", "Client not found", "error"))
-		a.Logger.Error("Failed to mute client", "clientId", clientId)
+		a.Logger.Error("Failed to mute client", "clientId", clientId, "error", err)
 		return
 	}
-	client.Muted = true
-	a.ServerState.RadioClients[clientGuid] = client
-	a.EmitEvent(events.Event{
-		Name: events.RadioClientsChanged,
-		Data: a.ServerState.RadioClients,
-	})
+	a.emitMuteChanged(record)
 	a.Notify(events.NewNotification("Mute succeeded", "Client muted successfully", "success"))
-	a.Logger.Info("Client muted", "clientId", clientId)
+	a.Logger.Info("Client muted", "clientId", clientId, "by", record.By, "reason", reason, "until", record.Until)
 }
 
-func (a *VCSApplication) UnmuteClient(clientId string) { // TODO: Implement Backend Logic to unmute a client and notify the Client
-	a.ServerState.Lock()
-	defer a.ServerState.Unlock()
+func (a *VCSApplication) UnmuteClient(clientId string) {
 	clientGuid, err := uuid.Parse(clientId)
 	if err != nil {
-		a.Notify(events.NewNotification("Kick failed", "Invalid client ID format", "error"))
+		a.Notify(events.NewNotification("Unmute failed", "Invalid client ID format", "error"))
 		a.Logger.Error("Failed to parse client ID", "clientId", clientId, "error", err)
 		return
 	}
-	client, ok := a.ServerState.RadioClients[clientGuid]
-	if !ok {
+	record, err := a.ServerState.UnmuteClient(clientGuid, guiAdminName)
+	if err != nil {
 		a.Notify(events.NewNotification("Unmute failed", "Client not found", "error"))
-		a.Logger.Error("Failed to unmute client", "clientId", clientId)
+		a.Logger.Error("Failed to unmute client", "clientId", clientId, "error", err)
+		return
+	}
+	a.emitMuteChanged(record)
+	a.Notify(events.NewNotification("Unmute succeeded", "Client unmuted successfully", "success"))
+	a.Logger.Info("Client unmuted", "clientId", clientId, "by", record.By)
+}
+
+// SetPriorityOverride lets the transmissions of the client pre-empt everybody on any frequency, for emergency broadcasts
+func (a *VCSApplication) SetPriorityOverride(clientId string, enabled bool) {
+	clientGuid, err := uuid.Parse(clientId)
+	if err != nil {
+		a.Notify(events.NewNotification("Priority override failed", "Invalid client ID format", "error"))
+		a.Logger.Error("Failed to parse client ID", "clientId", clientId, "error", err)
+		return
+	}
+	if err := a.ServerState.SetPriorityOverride(clientGuid, enabled); err != nil {
+		a.Notify(events.NewNotification("Priority override failed", "Client not found", "error"))
+		a.Logger.Error("Failed to set priority override", "clientId", clientId, "error", err)
 		return
 	}
-	client.Muted = false
-	a.ServerState.RadioClients[clientGuid] = client
+	a.EmitEvent(events.Event{
+		Name: events.ClientsChanged,
+		Data: a.ServerState.Clients,
+	})
+	a.Logger.Info("Client priority override changed", "clientId", clientId, "enabled", enabled, "by", guiAdminName)
+}
+
+// GetIntercomSessions returns the multi-crew units whose crew is talking on the intercom
+func (a *VCSApplication) GetIntercomSessions() []state.IntercomSession {
+	return a.ServerState.GetIntercomSessions()
+}
+
+// GetMuteHistory returns who muted and unmuted whom and why
+func (a *VCSApplication) GetMuteHistory() []state.MuteRecord {
+	return a.ServerState.GetMuteHistory()
+}
+
+// muteExpiryRoutine lifts mutes once their duration ran out and saves the mute history once it changed, so mutes
+// recorded by the GUI or the voice server never wait for the file
+func (a *VCSApplication) muteExpiryRoutine() {
+	ticker := time.NewTicker(muteExpiryInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		for _, record := range a.ServerState.ExpireMutes(now) {
+			a.emitMuteChanged(record)
+			a.Logger.Info("Client mute expired", "clientId", record.ClientGuid, "reason", record.Reason)
+		}
+		if err := a.ServerState.SaveMuteHistory(); err != nil {
+			a.Logger.Error("Failed to save mute history", "error", err)
+		}
+	}
+}
+
+func (a *VCSApplication) emitMuteChanged(record state.MuteRecord) {
+	a.EmitEvent(events.Event{
+		Name: events.ClientMuteChanged,
+		Data: record,
+	})
 	a.EmitEvent(events.Event{
 		Name: events.RadioClientsChanged,
 		Data: a.ServerState.RadioClients,
 	})
-	a.Notify(events.NewNotification("Unmute succeeded", "Client unmuted successfully", "success"))
-	a.Logger.Info("Client unmuted", "clientId", clientId)
 }
 
 func (a *VCSApplication) IsClientMuted(clientId string) bool {
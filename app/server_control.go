@@ -30,7 +30,7 @@ func (a *VCSApplication) startHTTPServer() {
 
 	go func() {
 		gin.SetMode(gin.ReleaseMode)
-		r := rest.GetRouter(a.Logger)
+		r := rest.GetRouter(a.Logger, a.SettingsState, a.transmissionLog)
 
 		a.SettingsState.Lock()
 
@@ -131,7 +131,8 @@ func (a *VCSApplication) startVoiceServer() {
 	a.StopSignals["voice"] = stopChan
 
 	go func() {
-		a.voiceServer = voice.NewServer(a.ServerState, a.Logger, a.DistributionState, a.SettingsState)
+		a.voiceServer = voice.NewServer(a.ServerState, a.Logger, a.DistributionState, a.SettingsState, a.eventBus)
+		a.voiceServer.SetTransmissionLog(a.transmissionLog)
 
 		// Update status
 		a.AdminState.Lock()
@@ -212,7 +213,7 @@ func (a *VCSApplication) startGrpcServer() {
 	a.StopSignals["control"] = stopChan
 	a.AdminState.Unlock()
 
-	controlServer := control.NewServer(a.ServerState, a.SettingsState, a.Logger, a.DistributionState, a.eventBus)
+	controlServer := control.NewServer(a.ServerState, a.SettingsState, a.Logger, a.DistributionState, a.eventBus, a.transmissionLog)
 	a.controlServer = controlServer
 
 	a.SettingsState.Lock()
@@ -8,6 +8,7 @@ import (
 	"github.com/FPGSchiba/vcs-srs-server/srs"
 	"github.com/FPGSchiba/vcs-srs-server/srspb"
 	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/FPGSchiba/vcs-srs-server/transmissions"
 	"github.com/FPGSchiba/vcs-srs-server/utils"
 	"github.com/FPGSchiba/vcs-srs-server/voicecontrolpb"
 	"github.com/FPGSchiba/vcs-srs-server/voiceontrol"
@@ -50,17 +51,19 @@ type Server struct {
 	settingsState     *state.SettingsState
 	distributionState *state.DistributionState
 	eventBus          *events.EventBus // Add event bus for handling events
+	transmissionLog   *transmissions.Log
 	isRunning         bool
 	stopOnce          sync.Once // Add this to ensure we only stop once
 }
 
-func NewServer(serverState *state.ServerState, settingsState *state.SettingsState, logger *slog.Logger, distributionState *state.DistributionState, eventBus *events.EventBus) *Server {
+func NewServer(serverState *state.ServerState, settingsState *state.SettingsState, logger *slog.Logger, distributionState *state.DistributionState, eventBus *events.EventBus, transmissionLog *transmissions.Log) *Server {
 	return &Server{
 		serverState:       serverState,
 		settingsState:     settingsState,
 		eventBus:          eventBus,
 		logger:            logger,
 		distributionState: distributionState,
+		transmissionLog:   transmissionLog,
 	}
 }
 
@@ -92,6 +95,7 @@ func (s *Server) Start(address string, stopChan chan struct{}) error {
 
 	s.clientGrpcServer = grpc.NewServer(
 		grpc.ChainUnaryInterceptor(s.loggingInterceptor, s.authInterceptor),
+		grpc.ChainStreamInterceptor(s.streamAuthInterceptor),
 		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
 			MinTime:             60 * time.Second, // allow pings every 60s
 			PermitWithoutStream: true,
@@ -102,7 +106,7 @@ func (s *Server) Start(address string, stopChan chan struct{}) error {
 		}),
 	)
 
-	srsServer := srs.NewSimpleRadioServer(s.serverState, s.settingsState, s.logger, s.eventBus)
+	srsServer := srs.NewSimpleRadioServer(s.serverState, s.settingsState, s.logger, s.eventBus, s.transmissionLog)
 	authServer := srs.NewAuthServer(s.serverState, s.settingsState, s.logger, s.distributionState, s.eventBus)
 	srspb.RegisterSRSServiceServer(s.clientGrpcServer, srsServer)
 	srspb.RegisterAuthServiceServer(s.clientGrpcServer, authServer)
@@ -273,27 +277,56 @@ func (s *Server) loggingInterceptor(ctx context.Context, req interface{}, info *
 }
 
 func (s *Server) authInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	elements := strings.Split(info.FullMethod, "/")
+	ctx, err := s.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authenticatedStream overrides the context of a server stream, so handlers can read the authenticated client
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *Server) streamAuthInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	s.logger.Debug("gRPC stream", "method", info.FullMethod)
+	ctx, err := s.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		s.logger.Error("gRPC stream error", "method", info.FullMethod, "error", err)
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticate validates the token of a request and returns a context carrying the client ID
+func (s *Server) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	elements := strings.Split(fullMethod, "/")
 	fullServiceName := elements[1] // Get the service name from the method path
 	pathName := elements[2]        // Get the method name from the method path
 	serviceName := strings.Split(fullServiceName, ".")[1]
 
 	if serviceName == "AuthService" || serviceName == "VoiceControlService" {
 		// Skip authentication for AuthService and VoiceControlService
-		return handler(ctx, req)
+		return ctx, nil
 	} else {
 		// For other services, perform authentication
-		s.logger.Debug("Authentication required for service", "service", serviceName, "method", info.FullMethod)
+		s.logger.Debug("Authentication required for service", "service", serviceName, "method", fullMethod)
 	}
 
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return nil, fmt.Errorf("unauthenticated request to %s: missing metadata", info.FullMethod)
+		return nil, fmt.Errorf("unauthenticated request to %s: missing metadata", fullMethod)
 	}
 
 	tokens := md.Get("authorization") // Check for an "authorization" header
 	if len(tokens) == 0 {
-		return nil, fmt.Errorf("unauthenticated request to %s: missing authorization token", info.FullMethod)
+		return nil, fmt.Errorf("unauthenticated request to %s: missing authorization token", fullMethod)
 	}
 	token := strings.TrimPrefix(tokens[0], "Bearer ") // Remove "Bearer " prefix if present
 
@@ -302,15 +335,13 @@ func (s *Server) authInterceptor(ctx context.Context, req interface{}, info *grp
 	claims, err := utils.GetTokenClaims(token, utils.SrsServiceMinimumRoleMap[pathName], s.settingsState.Security.Token.PrivateKeyFile, s.settingsState.Security.Token.PublicKeyFile)
 	s.settingsState.RUnlock()
 	if err != nil {
-		s.logger.Error("Authentication error", "method", info.FullMethod, "error", err)
-		return nil, fmt.Errorf("authentication error for %s: %v", info.FullMethod, err)
+		s.logger.Error("Authentication error", "method", fullMethod, "error", err)
+		return nil, fmt.Errorf("authentication error for %s: %v", fullMethod, err)
 	}
 
 	if claims == nil {
-		return nil, fmt.Errorf("unauthenticated request to %s", info.FullMethod)
+		return nil, fmt.Errorf("unauthenticated request to %s", fullMethod)
 	}
 
-	ctx = context.WithValue(ctx, "client_id", claims.ClientGuid)
-
-	return handler(ctx, req)
+	return context.WithValue(ctx, "client_id", claims.ClientGuid), nil
 }
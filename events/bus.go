@@ -1,6 +1,7 @@
 package events
 
 import (
+	"slices"
 	"sync"
 	"time"
 )
@@ -70,6 +71,21 @@ func (eb *EventBus) Subscribe(eventName string) chan Event {
 	return ch
 }
 
+// Unsubscribe removes a subscriber channel for a specific event type and closes it.
+func (eb *EventBus) Unsubscribe(eventName string, ch chan Event) {
+	eb.Lock()
+	defer eb.Unlock()
+
+	subs := eb.subscribers[eventName]
+	for i, sub := range subs {
+		if sub == ch {
+			eb.subscribers[eventName] = slices.Delete(subs, i, i+1)
+			close(ch)
+			return
+		}
+	}
+}
+
 // Publish enqueues an event for dispatching.
 func (eb *EventBus) Publish(event Event) {
 	eb.Lock()
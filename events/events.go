@@ -18,12 +18,26 @@ const (
 	RadioClientsChanged  = "clients/radio/changed"
 	ClientsChanged       = "clients/changed"
 	BannedClientsChanged = "clients/banned/changed"
+	ClientMuteChanged    = "clients/mute/changed"
+	VoiceQualityChanged  = "clients/quality/changed"
 )
 
 const (
 	NotificationEvent = "notification"
 )
 
+const (
+	VoiceAddressBlocked   = "voice/address/blocked"
+	VoiceAddressUnblocked = "voice/address/unblocked"
+)
+
+const (
+	TransmissionStarted  = "transmission/started"
+	TransmissionEnded    = "transmission/ended"
+	TransmissionConflict = "transmission/conflict"
+	TransmissionCutOff   = "transmission/cutoff"
+)
+
 type Notification struct {
 	Title   string `json:"title"`
 	Message string `json:"message"`
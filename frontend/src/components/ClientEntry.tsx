@@ -1,15 +1,15 @@
 import * as React from 'react';
-import {ClientState, RadioState, Coalition} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/state";
+import {ClientState, RadioState, Coalition, IntercomSession} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/state";
 import {Notification} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/events";
 import {Box, Button, Paper, Typography} from "@mui/material";
 import CircleIcon from "@mui/icons-material/Circle";
 import {GetCoalitionByName,} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/services/coalitionservice";
 import {Notify} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/services/notificationservice";
-import {IsClientMuted, MuteClient, UnmuteClient} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/services/clientservice"
+import {IsClientMuted, SetPriorityOverride, UnmuteClient} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/services/clientservice"
 import {Events} from "@wailsio/runtime";
 
-function ClientEntry(props: Readonly<{ client: ClientState, clientId: string, handleBan: (clientId: string) => void, handleKick: (clientId: string) => void }>) {
-    const { client, clientId, handleBan, handleKick } = props;
+function ClientEntry(props: Readonly<{ client: ClientState, clientId: string, intercom?: IntercomSession, talking?: number, handleBan: (clientId: string) => void, handleKick: (clientId: string) => void, handleMute: (clientId: string) => void }>) {
+    const { client, clientId, intercom, talking, handleBan, handleKick, handleMute } = props;
     const [coalition, setCoalition] = React.useState<Coalition | null>(null);
     const [muted, setMuted] = React.useState<boolean>(false);
 
@@ -42,23 +42,49 @@ function ClientEntry(props: Readonly<{ client: ClientState, clientId: string, ha
     }, [client]);
 
     return (
-        <Paper className="clients clients-entry clients-entry-paper">
-            <Typography className="clients clients-entry clients-entry-name" variant="body1">[{client.UnitId}] {client.Name}</Typography>
+        <Paper className={`clients clients-entry clients-entry-paper${talking !== undefined ? " clients-entry-talking" : ""}`}>
+            <Typography className="clients clients-entry clients-entry-name" variant="body1">[{client.Spectator ? "Spectator" : client.UnitId}] {client.Name}</Typography>
             <Box className="clients clients-entry clients-entry-coalition wrapper">
                 <CircleIcon className="clients clients-entry clients-entry-coalition circle" sx={{ color: coalition?.Color }} />
-                <Typography className="clients clients-entry clients-entry-coalition name" variant="body1">{coalition?.Name}</Typography>
+                <Typography className="clients clients-entry clients-entry-coalition name" variant="body1">{client.Spectator && !client.Coalition ? "All coalitions" : coalition?.Name}</Typography>
             </Box>
+            <Box className="clients clients-entry clients-entry-quality">
+                { client.VoiceQuality?.map((quality) => {
+                    const expected = quality.Received - quality.Duplicates + quality.Lost;
+                    const loss = expected > 0 ? (quality.Lost / expected) * 100 : 0;
+                    return (
+                        <Typography key={quality.Frequency} className="clients clients-entry clients-entry-quality-line" variant="body2">
+                            {quality.Frequency.toFixed(3)} MHz: {quality.Received} received, {loss.toFixed(1)}% lost, {quality.Reordered} reordered, {quality.Duplicates} duplicates, {quality.JitterMs.toFixed(1)} ms jitter
+                        </Typography>
+                    );
+                })}
+            </Box>
+            { talking !== undefined && (
+                <Typography className="clients clients-entry clients-entry-transmitting" variant="body2">Transmitting on {talking.toFixed(3)} MHz</Typography>
+            )}
+            { intercom && (
+                <Typography className="clients clients-entry clients-entry-intercom" variant="body2">Intercom ({intercom.Members.length} crew)</Typography>
+            )}
 
             <Box className="clients clients-entry clients-entry-actions">
                 <Button variant="contained" className="clients clients-entry clients-entry-action" onClick={() => {handleKick(clientId)}}>Kick</Button>
                 <Button variant="contained" className="clients clients-entry clients-entry-action" onClick={() => {handleBan(clientId)}}>Ban</Button>
-                <Button variant="contained" color={muted ? "primary" : "error"} className="clients clients-entry clients-entry-action" onClick={() => {
-                    if (muted) {
-                        UnmuteClient(clientId);
-                    } else {
-                        MuteClient(clientId);
-                    }
-                }}>{muted ? "Unmute" : "Mute"}</Button>
+                { !client.Spectator && (
+                    // Spectators never transmit, so there is nothing to mute or prioritize
+                    <>
+                        <Button variant="contained" color={muted ? "primary" : "error"} className="clients clients-entry clients-entry-action" onClick={() => {
+                            if (muted) {
+                                UnmuteClient(clientId);
+                            } else {
+                                handleMute(clientId);
+                            }
+                        }}>{muted ? "Unmute" : "Mute"}</Button>
+                        <Button variant="contained" color={client.PriorityOverride ? "warning" : "primary"} className="clients clients-entry clients-entry-action" onClick={() => {
+                            // Emergency broadcasts pre-empt every other transmission on any frequency
+                            SetPriorityOverride(clientId, !client.PriorityOverride);
+                        }}>{client.PriorityOverride ? "End Emergency" : "Emergency"}</Button>
+                    </>
+                )}
             </Box>
         </Paper>
     );
@@ -5,7 +5,7 @@ import { zodResolver } from "@hookform/resolvers/zod";
 import { Button, DialogActions, DialogContentText, Select, TextField } from "@mui/material";
 
 const frequencySchema = z.object({
-    frequencyType: z.enum(["global", "test"], { required_error: "Type is required" }),
+    frequencyType: z.enum(["global", "test", "priority", "guard", "recorded"], { required_error: "Type is required" }),
     frequency: z
         .number({ invalid_type_error: "Frequency must be a number" })
         .min(0.001, "Minimum is 000.001")
@@ -125,6 +125,9 @@ function FrequencyForm({ onSubmit, onCancel, }: Readonly<{
                     >
                         <option value="global">Global</option>
                         <option value="test">Test</option>
+                        <option value="priority">Priority</option>
+                        <option value="guard">Guard</option>
+                        <option value="recorded">Recorded</option>
                     </Select>
                 )}
             />
@@ -0,0 +1,150 @@
+import { useForm, Controller } from "react-hook-form";
+import { z } from "zod";
+import { zodResolver } from "@hookform/resolvers/zod";
+import { Button, DialogActions, DialogContentText, MenuItem, TextField } from "@mui/material";
+import { FrequencyPolicy } from "../../bindings/github.com/FPGSchiba/vcs-srs-server/state";
+
+const roles = ["Guest", "Member", "Officer", "Admin"];
+
+const policySchema = z.object({
+    Name: z.string(),
+    From: z
+        .number({ invalid_type_error: "Frequency must be a number" })
+        .min(0.001, "Minimum is 000.001")
+        .max(999.999, "Maximum is 999.999"),
+    To: z
+        .number({ invalid_type_error: "Frequency must be a number" })
+        .min(0, "Must not be negative")
+        .max(999.999, "Maximum is 999.999"),
+    Coalitions: z.string(),
+    Units: z.string(),
+    ListenRole: z.number(),
+    TransmitRole: z.number(),
+}).refine((policy) => policy.To === 0 || policy.To >= policy.From, {
+    message: "Must not be below the lowest frequency",
+    path: ["To"],
+});
+type PolicyFormType = z.infer<typeof policySchema>;
+
+// Comma separated names, empty allows all
+function parseList(list: string): string[] {
+    return list.split(",").map((name) => name.trim()).filter((name) => name !== "");
+}
+
+function FrequencyPolicyForm({ onSubmit, onCancel, }: Readonly<{
+    onSubmit: (policy: FrequencyPolicy) => void;
+    onCancel: () => void;
+}>) {
+    const { handleSubmit, control } = useForm<PolicyFormType>({
+        resolver: zodResolver(policySchema),
+        defaultValues: { Name: "", From: 0, To: 0, Coalitions: "", Units: "", ListenRole: 0, TransmitRole: 0 },
+    });
+
+    const numberField = (name: "From" | "To", label: string, hint: string) => (
+        <Controller
+            name={name}
+            control={control}
+            render={({ field, fieldState }) => (
+                <TextField
+                    {...field}
+                    margin="dense"
+                    label={label}
+                    type="number"
+                    fullWidth
+                    variant="outlined"
+                    inputProps={{ step: 0.001 }}
+                    error={!!fieldState.error}
+                    helperText={fieldState.error?.message ?? hint}
+                    onChange={e => field.onChange(e.target.value === "" ? "" : Number(e.target.value))}
+                    className="frequencies frequencies-policy frequencies-policy-frequency"
+                />
+            )}
+        />
+    );
+
+    const textField = (name: "Name" | "Coalitions" | "Units", label: string, hint: string) => (
+        <Controller
+            name={name}
+            control={control}
+            render={({ field, fieldState }) => (
+                <TextField
+                    {...field}
+                    margin="dense"
+                    label={label}
+                    fullWidth
+                    variant="outlined"
+                    error={!!fieldState.error}
+                    helperText={fieldState.error?.message ?? hint}
+                    className="frequencies frequencies-policy frequencies-policy-text"
+                />
+            )}
+        />
+    );
+
+    const roleField = (name: "ListenRole" | "TransmitRole", label: string) => (
+        <Controller
+            name={name}
+            control={control}
+            render={({ field }) => (
+                <TextField
+                    {...field}
+                    select
+                    margin="dense"
+                    label={label}
+                    fullWidth
+                    variant="outlined"
+                    onChange={e => field.onChange(Number(e.target.value))}
+                    className="frequencies frequencies-policy frequencies-policy-role"
+                >
+                    {roles.map((role, index) => (
+                        <MenuItem key={role} value={index}>{role}</MenuItem>
+                    ))}
+                </TextField>
+            )}
+        />
+    );
+
+    return (
+        <form
+            onSubmit={handleSubmit((data) => {
+                onSubmit(new FrequencyPolicy({
+                    ...data,
+                    Coalitions: parseList(data.Coalitions),
+                    Units: parseList(data.Units),
+                }));
+            })}
+            className="frequencies frequencies-policy frequencies-policy-form"
+        >
+            {textField("Name", "Name", "e.g. Command Net")}
+            {numberField("From", "From (MHz)", "Lowest frequency of the range")}
+            {numberField("To", "To (MHz)", "Highest frequency of the range, 0 for a single frequency")}
+            {textField("Coalitions", "Coalitions", "Comma separated, empty allows all")}
+            {textField("Units", "Units", "Comma separated, empty allows all")}
+            {roleField("ListenRole", "Minimum Role to Listen")}
+            {roleField("TransmitRole", "Minimum Role to Transmit")}
+            <DialogContentText className="frequencies frequencies-policy frequencies-policy-text">
+                Restrict a range of frequencies. Clients outside the policy can not tune to or transmit on it.
+            </DialogContentText>
+            <DialogActions className="frequencies frequencies-policy frequencies-policy-actions">
+                <Button
+                    onClick={onCancel}
+                    variant="contained"
+                    className="frequencies frequencies-policy frequencies-policy-action"
+                >
+                    Cancel
+                </Button>
+                <Button
+                    type="submit"
+                    variant="contained"
+                    color="secondary"
+                    className="frequencies frequencies-policy frequencies-policy-action"
+                >
+                    Add
+                </Button>
+            </DialogActions>
+        </form>
+    );
+}
+
+export { roles };
+export default FrequencyPolicyForm;
@@ -1,25 +1,49 @@
 import React from "react";
-import {Box, Button, Dialog, DialogActions, DialogContent, DialogContentText, DialogTitle, Paper, TextField} from "@mui/material";
-import {ClientState} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/state";
+import {Box, Button, Dialog, DialogActions, DialogContent, DialogContentText, DialogTitle, Paper, TextField, Typography} from "@mui/material";
+import {ClientState, IntercomSession} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/state";
 import {Notification} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/events";
-import {BanClient, GetClients, KickClient} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/services/clientservice";
+import {BanClient, GetClients, GetIntercomSessions, KickClient, MuteClient} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/services/clientservice";
 import {Notify} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/services/notificationservice";
 import {Events} from "@wailsio/runtime";
 import ClientEntry from "../components/ClientEntry";
 import {WailsEvent} from "@wailsio/runtime/types/events";
 
+// JSON of the transmissions.Record of the transmission/started and transmission/ended events
+type Transmission = {
+    clientGuid: string;
+    frequency: number;
+};
+
 function ClientListPage() {
     const [clients, setClients] = React.useState<Record<string, ClientState> | null>(null);
+    const [intercoms, setIntercoms] = React.useState<Record<string, IntercomSession>>({});
+    // Frequencies the clients are transmitting on right now
+    const [talking, setTalking] = React.useState<Record<string, number>>({});
     const [banOpen, setBanOpen] = React.useState(false);
     const [banItem, setBanItem] = React.useState<string | null>(null);
     const [banReason, setBanReason] = React.useState<string>("");
     const [kickOpen, setKickOpen] = React.useState(false);
     const [kickItem, setKickItem] = React.useState<string | null>(null);
     const [kickReason, setKickReason] = React.useState<string>("");
+    const [muteOpen, setMuteOpen] = React.useState(false);
+    const [muteItem, setMuteItem] = React.useState<string | null>(null);
+    const [muteReason, setMuteReason] = React.useState<string>("");
+    const [muteMinutes, setMuteMinutes] = React.useState<number>(0);
 
     const fetchClients = async () => {
         const clients = await GetClients();
-        setClients(clients.Clients);
+        setClients({...clients.Clients, ...clients.Spectators});
+    }
+
+    const fetchIntercoms = async () => {
+        const sessions = await GetIntercomSessions();
+        const byClient: Record<string, IntercomSession> = {};
+        sessions.forEach((session) => {
+            session.Members.forEach((member) => {
+                byClient[member] = session;
+            });
+        });
+        setIntercoms(byClient);
     }
 
     const handleBan = (clientId: string) => {
@@ -32,11 +56,43 @@ function ClientListPage() {
         setKickOpen(true);
     }
 
+    const handleMute = (clientId: string) => {
+        setMuteItem(clientId);
+        setMuteOpen(true);
+    }
+
+    const closeMute = () => {
+        setMuteOpen(false);
+        setMuteItem(null);
+        setMuteReason("");
+        setMuteMinutes(0);
+    }
+
     React.useEffect(() => {
         fetchClients();
+        fetchIntercoms();
         Events.On("clients/changed", (event: WailsEvent) => {
             const clients = event.data[0] as Record<string, ClientState>
             setClients(clients);
+            fetchIntercoms();
+        });
+        Events.On("clients/radio/changed", () => {
+            fetchIntercoms();
+        });
+        Events.On("clients/quality/changed", () => {
+            fetchClients();
+        });
+        Events.On("transmission/started", (event: WailsEvent) => {
+            const transmission = event.data[0] as Transmission;
+            setTalking((talking) => ({...talking, [transmission.clientGuid]: transmission.frequency}));
+        });
+        Events.On("transmission/ended", (event: WailsEvent) => {
+            const transmission = event.data[0] as Transmission;
+            setTalking((talking) => {
+                const rest = {...talking};
+                delete rest[transmission.clientGuid];
+                return rest;
+            });
         });
     }, []);
 
@@ -44,10 +100,20 @@ function ClientListPage() {
         <>
             <Paper className="clients clients-paper">
                 <Box className="clients clients-content">
-                    { clients && Object.entries(clients).map(([key, client]) => (
-                        <ClientEntry key={key} client={client} clientId={key} handleBan={handleBan} handleKick={handleKick} />
+                    { clients && Object.entries(clients).filter(([, client]) => !client.Spectator).map(([key, client]) => (
+                        <ClientEntry key={key} client={client} clientId={key} intercom={intercoms[key]} talking={talking[key]} handleBan={handleBan} handleKick={handleKick} handleMute={handleMute} />
                     ))}
                 </Box>
+                { clients && Object.values(clients).some((client) => client.Spectator) && (
+                    <>
+                        <Typography className="clients clients-spectators-title" variant="h6">Spectators</Typography>
+                        <Box className="clients clients-content clients-spectators">
+                            { Object.entries(clients).filter(([, client]) => client.Spectator).map(([key, client]) => (
+                                <ClientEntry key={key} client={client} clientId={key} handleBan={handleBan} handleKick={handleKick} handleMute={handleMute} />
+                            ))}
+                        </Box>
+                    </>
+                )}
             </Paper>
             <Dialog
                 open={banOpen}
@@ -139,6 +205,58 @@ function ClientListPage() {
                     }} variant="contained" autoFocus color="error">Kick</Button>
                 </DialogActions>
             </Dialog>
+            <Dialog
+                open={muteOpen}
+                onClose={closeMute}
+            >
+                <DialogTitle>
+                    Mute Client
+                </DialogTitle>
+                <DialogContent>
+                    <DialogContentText>
+                        You are attempting to mute a client. This action requires a reason. Leave the duration at 0 to mute the client until it is unmuted.
+                    </DialogContentText>
+                    <TextField
+                        autoFocus
+                        margin="dense"
+                        id="reason"
+                        label="Reason for mute"
+                        type="text"
+                        fullWidth
+                        value={muteReason}
+                        variant="outlined"
+                        onChange={(e) => {
+                            setMuteReason(e.target.value);
+                        }}/>
+                    <TextField
+                        margin="dense"
+                        id="duration"
+                        label="Duration in minutes"
+                        type="number"
+                        fullWidth
+                        value={muteMinutes}
+                        variant="outlined"
+                        inputProps={{ min: 0 }}
+                        onChange={(e) => {
+                            setMuteMinutes(Math.max(0, Number(e.target.value)));
+                        }}/>
+                </DialogContent>
+                <DialogActions>
+                    <Button onClick={closeMute} variant="contained">Cancel</Button>
+                    <Button onClick={() => {
+                        if (muteOpen && muteItem) {
+                            MuteClient(muteItem, muteReason, muteMinutes * 60);
+                        } else {
+                            Notify(new Notification({
+                                title: "No client selected",
+                                message: `No client selected for mute`,
+                                level: "error",
+                            }));
+                        }
+                        closeMute();
+                    }} variant="contained" autoFocus color="error">Mute</Button>
+                </DialogActions>
+            </Dialog>
         </>
     )
 }
@@ -14,11 +14,16 @@ import {
     Paper
 } from "@mui/material";
 import PodcastsIcon from '@mui/icons-material/Podcasts';
-import {GetSettings, SaveFrequencySettings} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/services/settingsservice";
+import FiberManualRecordIcon from '@mui/icons-material/FiberManualRecord';
+import PriorityHighIcon from '@mui/icons-material/PriorityHigh';
+import LockIcon from '@mui/icons-material/Lock';
+import ShieldIcon from '@mui/icons-material/Shield';
+import {GetSettings, SaveFrequencyPolicies, SaveFrequencySettings, SetFrequencyRecording} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/services/settingsservice";
 import {Events} from "@wailsio/runtime";
 import CloseIcon from '@mui/icons-material/Close';
-import {SettingsState, FrequencySettings} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/state";
+import {SettingsState, FrequencySettings, FrequencyPolicy} from "../../bindings/github.com/FPGSchiba/vcs-srs-server/state";
 import FrequencyForm from "../components/FrequencyForm";
+import FrequencyPolicyForm, {roles} from "../components/FrequencyPolicyForm";
 import {WailsEvent} from "@wailsio/runtime/types/events";
 
 function formatFrequencyNumber(num: number): string {
@@ -27,10 +32,26 @@ function formatFrequencyNumber(num: number): string {
     return `${digits.slice(0, 3)}.${digits.slice(3, 6)}`;
 }
 
+function formatPolicy(policy: FrequencyPolicy): string {
+    const range = policy.To > policy.From ? `${formatFrequencyNumber(policy.From)} - ${formatFrequencyNumber(policy.To)}` : formatFrequencyNumber(policy.From);
+    return policy.Name ? `${policy.Name} (${range})` : range;
+}
+
+function describePolicy(policy: FrequencyPolicy): string {
+    const coalitions = policy.Coalitions?.length ? policy.Coalitions.join(", ") : "All coalitions";
+    const units = policy.Units?.length ? policy.Units.join(", ") : "all units";
+    return `${coalitions}, ${units} - listen: ${roles[policy.ListenRole]}, transmit: ${roles[Math.max(policy.ListenRole, policy.TransmitRole)]}`;
+}
+
 function FrequencyPage() {
     const [globalFrequencies, setGlobalFrequencies] = React.useState<number[]>([]);
     const [testFrequencies, setTestFrequencies] = React.useState<number[]>([]);
+    const [priorityFrequencies, setPriorityFrequencies] = React.useState<number[]>([]);
+    const [guardFrequencies, setGuardFrequencies] = React.useState<number[]>([]);
+    const [recordedFrequencies, setRecordedFrequencies] = React.useState<number[]>([]);
+    const [policies, setPolicies] = React.useState<FrequencyPolicy[]>([]);
     const [open, setOpen] = React.useState(false);
+    const [policyOpen, setPolicyOpen] = React.useState(false);
 
     const fetchFrequencies = async () => {
         const settings = await GetSettings();
@@ -40,12 +61,18 @@ function FrequencyPage() {
         }
         setGlobalFrequencies(settings.Frequencies.GlobalFrequencies);
         setTestFrequencies(settings.Frequencies.TestFrequencies);
+        setPriorityFrequencies(settings.Frequencies.PriorityFrequencies ?? []);
+        setGuardFrequencies(settings.Frequencies.GuardFrequencies ?? []);
+        setRecordedFrequencies(settings.Recording.Frequencies ?? []);
+        setPolicies(settings.FrequencyPolicies ?? []);
     }
 
     const handleSave = async () => {
         await SaveFrequencySettings(new FrequencySettings({
             GlobalFrequencies: globalFrequencies,
             TestFrequencies: testFrequencies,
+            PriorityFrequencies: priorityFrequencies,
+            GuardFrequencies: guardFrequencies,
         }));
     }
 
@@ -56,7 +83,13 @@ function FrequencyPage() {
             if (settings.Frequencies) {
                 setGlobalFrequencies(settings.Frequencies.GlobalFrequencies);
                 setTestFrequencies(settings.Frequencies.TestFrequencies);
+                setPriorityFrequencies(settings.Frequencies.PriorityFrequencies ?? []);
+                setGuardFrequencies(settings.Frequencies.GuardFrequencies ?? []);
+            }
+            if (settings.Recording) {
+                setRecordedFrequencies(settings.Recording.Frequencies ?? []);
             }
+            setPolicies(settings.FrequencyPolicies ?? []);
         })
     }, []);
 
@@ -104,9 +137,88 @@ function FrequencyPage() {
                     ))}
 
                 </List>
+                <List
+                    subheader={<ListSubheader>Priority Frequencies</ListSubheader>}
+                    className="frequencies frequencies-list frequencies-list-priority"
+                >
+                    {priorityFrequencies.map((frequency, index) => (
+                        <ListItem key={index} className="frequencies frequencies-list frequencies-list-item">
+                            <ListItemIcon className="frequencies frequencies-list frequencies-list-icon">
+                                <PriorityHighIcon color="warning" />
+                            </ListItemIcon>
+                            <ListItemText primary={formatFrequencyNumber(frequency)} className="frequencies frequencies-list frequencies-list-name" />
+                            <IconButton className="frequencies frequencies-list frequencies-list-close" onClick={() => {
+                                const newFrequencies = [...priorityFrequencies];
+                                newFrequencies.splice(index, 1);
+                                setPriorityFrequencies(newFrequencies);
+                            }}>
+                                <CloseIcon />
+                            </IconButton>
+                        </ListItem>
+                    ))}
+                </List>
+                <List
+                    subheader={<ListSubheader>Guard Frequencies</ListSubheader>}
+                    className="frequencies frequencies-list frequencies-list-guard"
+                >
+                    {guardFrequencies.map((frequency, index) => (
+                        <ListItem key={index} className="frequencies frequencies-list frequencies-list-item">
+                            <ListItemIcon className="frequencies frequencies-list frequencies-list-icon">
+                                <ShieldIcon color="success" />
+                            </ListItemIcon>
+                            <ListItemText primary={formatFrequencyNumber(frequency)} className="frequencies frequencies-list frequencies-list-name" />
+                            <IconButton className="frequencies frequencies-list frequencies-list-close" onClick={() => {
+                                const newFrequencies = [...guardFrequencies];
+                                newFrequencies.splice(index, 1);
+                                setGuardFrequencies(newFrequencies);
+                            }}>
+                                <CloseIcon />
+                            </IconButton>
+                        </ListItem>
+                    ))}
+                </List>
+                <List
+                    subheader={<ListSubheader>Recorded Frequencies</ListSubheader>}
+                    className="frequencies frequencies-list frequencies-list-recorded"
+                >
+                    {recordedFrequencies.map((frequency) => (
+                        <ListItem key={frequency} className="frequencies frequencies-list frequencies-list-item">
+                            <ListItemIcon className="frequencies frequencies-list frequencies-list-icon">
+                                <FiberManualRecordIcon color="error" />
+                            </ListItemIcon>
+                            <ListItemText primary={formatFrequencyNumber(frequency)} className="frequencies frequencies-list frequencies-list-name" />
+                            <IconButton className="frequencies frequencies-list frequencies-list-close" onClick={() => {
+                                // Recording changes apply immediately and do not wait for Save
+                                SetFrequencyRecording(frequency, false);
+                            }}>
+                                <CloseIcon />
+                            </IconButton>
+                        </ListItem>
+                    ))}
+                </List>
+                <List
+                    subheader={<ListSubheader>Frequency Policies</ListSubheader>}
+                    className="frequencies frequencies-list frequencies-list-policies"
+                >
+                    {policies.map((policy, index) => (
+                        <ListItem key={index} className="frequencies frequencies-list frequencies-list-item">
+                            <ListItemIcon className="frequencies frequencies-list frequencies-list-icon">
+                                <LockIcon color="info" />
+                            </ListItemIcon>
+                            <ListItemText primary={formatPolicy(policy)} secondary={describePolicy(policy)} className="frequencies frequencies-list frequencies-list-name" />
+                            <IconButton className="frequencies frequencies-list frequencies-list-close" onClick={() => {
+                                // Policy changes apply immediately and do not wait for Save
+                                SaveFrequencyPolicies(policies.filter((_, i) => i !== index));
+                            }}>
+                                <CloseIcon />
+                            </IconButton>
+                        </ListItem>
+                    ))}
+                </List>
             </Paper>
             <Box className="frequencies frequencies-actions">
                 <Button variant="contained" color="secondary" className="frequencies frequencies-action" onClick={() => {setOpen(true)}}>Add Frequency</Button>
+                <Button variant="contained" color="secondary" className="frequencies frequencies-action" onClick={() => {setPolicyOpen(true)}}>Add Policy</Button>
                 <Button variant="contained" className="frequencies frequencies-action" onClick={handleSave}>Save</Button>
             </Box>
             <Dialog
@@ -121,6 +233,12 @@ function FrequencyPage() {
                         onSubmit={({ frequencyType, frequency }) => {
                             if (frequencyType === "global") {
                                 setGlobalFrequencies([...globalFrequencies, frequency]);
+                            } else if (frequencyType === "priority") {
+                                setPriorityFrequencies([...priorityFrequencies, frequency]);
+                            } else if (frequencyType === "guard") {
+                                setGuardFrequencies([...guardFrequencies, frequency]);
+                            } else if (frequencyType === "recorded") {
+                                SetFrequencyRecording(frequency, true);
                             } else {
                                 setTestFrequencies([...testFrequencies, frequency]);
                             }
@@ -130,6 +248,23 @@ function FrequencyPage() {
                     />
                 </DialogContent>
             </Dialog>
+            <Dialog
+                open={policyOpen}
+                onClose={() => { setPolicyOpen(false); }}
+            >
+                <DialogTitle>
+                    Add Frequency Policy
+                </DialogTitle>
+                <DialogContent>
+                    <FrequencyPolicyForm
+                        onSubmit={(policy) => {
+                            SaveFrequencyPolicies([...policies, policy]);
+                            setPolicyOpen(false);
+                        }}
+                        onCancel={() => setPolicyOpen(false)}
+                    />
+                </DialogContent>
+            </Dialog>
         </>
     )
 }
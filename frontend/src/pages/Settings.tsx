@@ -1,16 +1,23 @@
 import React, { useEffect } from 'react';
-import { Box, Button, FormControl, FormLabel, Paper, TextField, Typography } from "@mui/material";
+import { Box, Button, FormControl, FormControlLabel, FormLabel, MenuItem, Paper, Switch, TextField, Typography } from "@mui/material";
 import { GetSettings, SaveGeneralSettings, SaveServerSettings } from "../../bindings/github.com/FPGSchiba/vcs-srs-server/services/settingsservice";
 import { useForm, Controller } from "react-hook-form";
 import { z } from "zod";
 import { zodResolver } from "@hookform/resolvers/zod";
-import { SettingsState } from "../../bindings/github.com/FPGSchiba/vcs-srs-server/state";
+import { GeneralSettings, SettingsState } from "../../bindings/github.com/FPGSchiba/vcs-srs-server/state";
 import { Events } from "@wailsio/runtime";
 import { WailsEvent } from "@wailsio/runtime/types/events";
 
 const settingsSchema = z.object({
     General: z.object({
         MaxRadiosPerUser: z.number().min(1, "Must be at least 1"),
+        ChannelSpacing: z.number().min(0, "Must not be negative"),
+        FrequencyTolerance: z.number().min(0, "Must not be negative"),
+        TransmitPolicy: z.enum(["strict", "lenient"]),
+        SimultaneousPolicy: z.enum(["first", "mix", "flag"]),
+        TestFrequencyPlayback: z.boolean(),
+        RadioEncryption: z.boolean(),
+        StrictRadioEncryption: z.boolean(),
     }),
     Servers: z.object({
         HTTP: z.object({
@@ -30,11 +37,16 @@ const settingsSchema = z.object({
 
 type SettingsFormType = z.infer<typeof settingsSchema>;
 
+// Settings without a policy for simultaneous transmissions mix all talkers
+const toSimultaneousPolicy = (policy: string): "first" | "mix" | "flag" => (policy === "first" || policy === "flag") ? policy : "mix";
+
 function SettingsPage() {
+    // Keeps the general settings that are not part of the form, so saving does not reset them
+    const generalSettings = React.useRef<GeneralSettings | null>(null);
     const { control, handleSubmit, reset } = useForm<SettingsFormType>({
         resolver: zodResolver(settingsSchema),
         defaultValues: {
-            General: { MaxRadiosPerUser: 1 },
+            General: { MaxRadiosPerUser: 1, ChannelSpacing: 0, FrequencyTolerance: 500, TransmitPolicy: "strict", SimultaneousPolicy: "first", TestFrequencyPlayback: false, RadioEncryption: true, StrictRadioEncryption: false },
             Servers: {
                 HTTP: { Port: 80, Host: "" },
                 Voice: { Port: 5002, Host: "" },
@@ -50,9 +62,17 @@ function SettingsPage() {
                 console.error('No settings found');
                 return;
             }
+            generalSettings.current = newSettings.General;
             reset({
                 General: {
                     MaxRadiosPerUser: Number(newSettings.General.MaxRadiosPerUser) || 1,
+                    ChannelSpacing: Number(newSettings.General.ChannelSpacing) || 0,
+                    FrequencyTolerance: Number(newSettings.General.FrequencyTolerance) || 0,
+                    TransmitPolicy: newSettings.General.TransmitPolicy === "lenient" ? "lenient" : "strict",
+                    SimultaneousPolicy: toSimultaneousPolicy(newSettings.General.SimultaneousPolicy),
+                    TestFrequencyPlayback: !!newSettings.General.TestFrequencyPlayback,
+                    RadioEncryption: !!newSettings.General.RadioEncryption,
+                    StrictRadioEncryption: !!newSettings.General.StrictRadioEncryption,
                 },
                 Servers: {
                     HTTP: {
@@ -76,7 +96,7 @@ function SettingsPage() {
 
     const onSubmit = async (data: SettingsFormType) => {
         try {
-            await SaveGeneralSettings(data.General);
+            await SaveGeneralSettings(new GeneralSettings({ ...generalSettings.current, ...data.General }));
             await SaveServerSettings({ ...data.Servers });
             await fetchSettings();
         } catch (error) {
@@ -86,9 +106,17 @@ function SettingsPage() {
 
     const handleSettingsChange = async (event: WailsEvent) => {
         const settings = event.data[0] as SettingsState;
+        generalSettings.current = settings.General;
         reset({
             General: {
                 MaxRadiosPerUser: Number(settings.General.MaxRadiosPerUser) || 1,
+                ChannelSpacing: Number(settings.General.ChannelSpacing) || 0,
+                FrequencyTolerance: Number(settings.General.FrequencyTolerance) || 0,
+                TransmitPolicy: settings.General.TransmitPolicy === "lenient" ? "lenient" : "strict",
+                SimultaneousPolicy: toSimultaneousPolicy(settings.General.SimultaneousPolicy),
+                TestFrequencyPlayback: !!settings.General.TestFrequencyPlayback,
+                RadioEncryption: !!settings.General.RadioEncryption,
+                StrictRadioEncryption: !!settings.General.StrictRadioEncryption,
             },
             Servers: {
                 HTTP: {
@@ -135,6 +163,115 @@ function SettingsPage() {
                                 )}
                             />
                         </FormControl>
+                        <FormControl className="settings settings-general settings-general-control" component="fieldset" >
+                            <FormLabel className="settings settings-general settings-general-label">Channel Spacing (Hz)</FormLabel>
+                            <Controller
+                                name="General.ChannelSpacing"
+                                control={control}
+                                render={({ field, fieldState }) => (
+                                    <TextField
+                                        {...field}
+                                        type="number"
+                                        variant="outlined"
+                                        error={!!fieldState.error}
+                                        helperText={fieldState.error?.message ?? "Frequencies are rounded to channels this far apart, 0 disables rounding"}
+                                        onChange={e => field.onChange(e.target.value === "" ? "" : Number(e.target.value))}
+                                    />
+                                )}
+                            />
+                        </FormControl>
+                        <FormControl className="settings settings-general settings-general-control" component="fieldset" >
+                            <FormLabel className="settings settings-general settings-general-label">Frequency Tolerance (Hz)</FormLabel>
+                            <Controller
+                                name="General.FrequencyTolerance"
+                                control={control}
+                                render={({ field, fieldState }) => (
+                                    <TextField
+                                        {...field}
+                                        type="number"
+                                        variant="outlined"
+                                        error={!!fieldState.error}
+                                        helperText={fieldState.error?.message ?? "Channels this close count as the same channel"}
+                                        onChange={e => field.onChange(e.target.value === "" ? "" : Number(e.target.value))}
+                                    />
+                                )}
+                            />
+                        </FormControl>
+                        <FormControl className="settings settings-general settings-general-control" component="fieldset" >
+                            <FormLabel className="settings settings-general settings-general-label">Transmit Policy</FormLabel>
+                            <Controller
+                                name="General.TransmitPolicy"
+                                control={control}
+                                render={({ field, fieldState }) => (
+                                    <TextField
+                                        {...field}
+                                        select
+                                        variant="outlined"
+                                        error={!!fieldState.error}
+                                        helperText={fieldState.error?.message ?? "Strict drops voice on frequencies the sender has no enabled radio on"}
+                                    >
+                                        <MenuItem value="strict">Strict</MenuItem>
+                                        <MenuItem value="lenient">Lenient (count and log only)</MenuItem>
+                                    </TextField>
+                                )}
+                            />
+                        </FormControl>
+                        <FormControl className="settings settings-general settings-general-control" component="fieldset" >
+                            <FormLabel className="settings settings-general settings-general-label">Simultaneous Transmissions</FormLabel>
+                            <Controller
+                                name="General.SimultaneousPolicy"
+                                control={control}
+                                render={({ field, fieldState }) => (
+                                    <TextField
+                                        {...field}
+                                        select
+                                        variant="outlined"
+                                        error={!!fieldState.error}
+                                        helperText={fieldState.error?.message ?? "What receivers hear when two clients transmit on the same frequency"}
+                                    >
+                                        <MenuItem value="first">First talker wins</MenuItem>
+                                        <MenuItem value="mix">Mix both</MenuItem>
+                                        <MenuItem value="flag">Mix both and flag the overlap</MenuItem>
+                                    </TextField>
+                                )}
+                            />
+                        </FormControl>
+                        <FormControl className="settings settings-general settings-general-control" component="fieldset" >
+                            <FormLabel className="settings settings-general settings-general-label">Test Frequencies</FormLabel>
+                            <Controller
+                                name="General.TestFrequencyPlayback"
+                                control={control}
+                                render={({ field }) => (
+                                    <FormControlLabel
+                                        control={<Switch checked={field.value} onChange={e => field.onChange(e.target.checked)} />}
+                                        label="Play on the server speakers instead of echoing back to the sender"
+                                    />
+                                )}
+                            />
+                        </FormControl>
+                        <FormControl className="settings settings-general settings-general-control" component="fieldset" >
+                            <FormLabel className="settings settings-general settings-general-label">Radio Encryption</FormLabel>
+                            <Controller
+                                name="General.RadioEncryption"
+                                control={control}
+                                render={({ field }) => (
+                                    <FormControlLabel
+                                        control={<Switch checked={field.value} onChange={e => field.onChange(e.target.checked)} />}
+                                        label="Allow radios to encrypt with a key"
+                                    />
+                                )}
+                            />
+                            <Controller
+                                name="General.StrictRadioEncryption"
+                                control={control}
+                                render={({ field }) => (
+                                    <FormControlLabel
+                                        control={<Switch checked={field.value} onChange={e => field.onChange(e.target.checked)} />}
+                                        label="Strict: receivers with another key hear nothing instead of noise"
+                                    />
+                                )}
+                            />
+                        </FormControl>
                     </Box>
                     <Box className="settings settings-server settings-server-wrapper">
                         <Typography className="settings settings-server settings-server-title" variant="h4">Servers</Typography>
@@ -10,7 +10,7 @@ import (
 
 func main() {
 	// In headless mode, we don't start the Wails application.
-	configFilepath, bannedFilePath, distributionModeFlag, _, logger := parseFlags(true)
+	configFilepath, bannedFilePath, muteHistoryFilePath, distributionModeFlag, _, logger := parseFlags(true)
 	distributionMode := state.DistributionModeStandalone
 	switch distributionModeFlag {
 	case "standalone":
@@ -36,7 +36,7 @@ func main() {
 		}
 	}()
 
-	vcs.HeadlessStartup(logger, configFilepath, bannedFilePath, distributionMode)
+	vcs.HeadlessStartup(logger, configFilepath, bannedFilePath, muteHistoryFilePath, distributionMode)
 
 	select {} // Block forever
 }
@@ -16,7 +16,7 @@ import (
 var assets embed.FS
 
 func main() {
-	configFilepath, bannedFilePath, _, autoStartServers, logger := parseFlags(false)
+	configFilepath, bannedFilePath, muteHistoryFilePath, _, autoStartServers, logger := parseFlags(false)
 
 	vcs := app.New()
 
@@ -48,7 +48,7 @@ func main() {
 	}
 
 	wails := application.New(appOptions)
-	vcs.StartUp(wails, configFilepath, bannedFilePath, autoStartServers)
+	vcs.StartUp(wails, configFilepath, bannedFilePath, muteHistoryFilePath, autoStartServers)
 	
 	wails.Window.NewWithOptions(application.WebviewWindowOptions{
 		Title:          "VCS Server",
@@ -0,0 +1,32 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/FPGSchiba/vcs-srs-server/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// requireRole only lets requests through that carry a bearer token of at least the given role
+func requireRole(settingsState *state.SettingsState, minRole uint8) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "missing authorization token"})
+			return
+		}
+
+		settingsState.RLock()
+		claims, err := utils.GetTokenClaims(token, minRole, settingsState.Security.Token.PrivateKeyFile, settingsState.Security.Token.PublicKeyFile)
+		settingsState.RUnlock()
+		if err != nil || claims == nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "error", "message": "not allowed"})
+			return
+		}
+
+		c.Set("client_id", claims.ClientGuid)
+		c.Next()
+	}
+}
@@ -0,0 +1,42 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/gin-gonic/gin"
+)
+
+type recordingRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// getRecordings lists all frequencies that are currently recorded
+func getRecordings(settingsState *state.SettingsState) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "success", "frequencies": settingsState.GetRecordingSettings().Frequencies})
+	}
+}
+
+// setRecording turns recording of the frequency in the path on or off
+func setRecording(settingsState *state.SettingsState) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		frequency, err := strconv.ParseFloat(c.Param("frequency"), 32)
+		if err != nil || frequency <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "invalid frequency"})
+			return
+		}
+		var request recordingRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+
+		if err := settingsState.SetFrequencyRecorded(state.FrequencyFromMHz(float32(frequency)), *request.Enabled); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "failed to save settings"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "success", "frequency": float32(frequency), "enabled": *request.Enabled})
+	}
+}
@@ -1,12 +1,14 @@
 package rest
 
 import (
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/FPGSchiba/vcs-srs-server/transmissions"
 	"github.com/FPGSchiba/vcs-srs-server/utils"
 	"github.com/gin-gonic/gin"
 	"log/slog"
 )
 
-func GetRouter(logger *slog.Logger) *gin.Engine {
+func GetRouter(logger *slog.Logger, settingsState *state.SettingsState, transmissionLog *transmissions.Log) *gin.Engine {
 	router := gin.New()
 	router.Use(gin.Recovery())
 	router.Use(utils.LogMiddleware(logger))
@@ -19,6 +21,9 @@ func GetRouter(logger *slog.Logger) *gin.Engine {
 		apiGroup.GET("/", func(c *gin.Context) {
 			c.JSON(200, gin.H{"version": "1.0.0", "status": "success", "message": "API is running"})
 		})
+		apiGroup.GET("/transmissions", requireRole(settingsState, utils.AdminRole), getTransmissions(transmissionLog))
+		apiGroup.GET("/recordings", requireRole(settingsState, utils.AdminRole), getRecordings(settingsState))
+		apiGroup.PUT("/recordings/:frequency", requireRole(settingsState, utils.AdminRole), setRecording(settingsState))
 	}
 
 	return router
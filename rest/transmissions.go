@@ -0,0 +1,65 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/FPGSchiba/vcs-srs-server/transmissions"
+	"github.com/gin-gonic/gin"
+)
+
+// getTransmissions queries the transmission log. All query parameters are optional:
+// from and to as RFC 3339 timestamps, frequency in MHz, client as client GUID and limit for the latest n transmissions.
+func getTransmissions(transmissionLog *transmissions.Log) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, err := parseTransmissionFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+
+		records, err := transmissionLog.Query(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "failed to read the transmission log"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "success", "transmissions": records})
+	}
+}
+
+func parseTransmissionFilter(c *gin.Context) (transmissions.Filter, error) {
+	filter := transmissions.Filter{
+		ClientGuid: c.Query("client"),
+	}
+	if from := c.Query("from"); from != "" {
+		parsed, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return filter, err
+		}
+		filter.From = parsed
+	}
+	if to := c.Query("to"); to != "" {
+		parsed, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return filter, err
+		}
+		filter.To = parsed
+	}
+	if frequency := c.Query("frequency"); frequency != "" {
+		parsed, err := strconv.ParseFloat(frequency, 32)
+		if err != nil {
+			return filter, err
+		}
+		filter.Frequency = state.FrequencyFromMHz(float32(parsed))
+	}
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil {
+			return filter, err
+		}
+		filter.Limit = parsed
+	}
+	return filter, nil
+}
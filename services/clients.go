@@ -39,8 +39,8 @@ func (c *ClientService) KickClient(clientId string, reason string) {
 	c.App.KickClient(clientId, reason)
 }
 
-func (c *ClientService) MuteClient(clientId string) {
-	c.App.MuteClient(clientId)
+func (c *ClientService) MuteClient(clientId string, reason string, duration int64) {
+	c.App.MuteClient(clientId, reason, duration)
 }
 
 func (c *ClientService) UnmuteClient(clientId string) {
@@ -50,3 +50,15 @@ func (c *ClientService) UnmuteClient(clientId string) {
 func (c *ClientService) IsClientMuted(clientId string) bool {
 	return c.App.IsClientMuted(clientId)
 }
+
+func (c *ClientService) SetPriorityOverride(clientId string, enabled bool) {
+	c.App.SetPriorityOverride(clientId, enabled)
+}
+
+func (c *ClientService) GetMuteHistory() []state.MuteRecord {
+	return c.App.GetMuteHistory()
+}
+
+func (c *ClientService) GetIntercomSessions() []state.IntercomSession {
+	return c.App.GetIntercomSessions()
+}
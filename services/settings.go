@@ -75,3 +75,39 @@ func (s *SettingsService) SaveFrequencySettings(newSettings *state.FrequencySett
 	})
 	s.App.Notify(events.NewNotification("Settings saved", "Frequency Settings were successfully saved", "info"))
 }
+
+func (s *SettingsService) SaveFrequencyPolicies(policies []state.FrequencyPolicy) {
+	err := s.App.SettingsState.SetFrequencyPolicies(policies)
+	if err != nil {
+		s.App.Logger.Error(fmt.Sprintf("Failed to save settings: %v", err))
+		s.App.Notify(events.NewNotification("Failed to save settings", fmt.Sprintf("Failed to save frequency policies: %v", err), "error"))
+		return
+	}
+	s.App.SettingsState.RLock()
+	defer s.App.SettingsState.RUnlock()
+	s.App.App.Event.EmitEvent(&application.CustomEvent{
+		Name: events.SettingsChanged,
+		Data: s.App.SettingsState,
+	})
+	s.App.Notify(events.NewNotification("Settings saved", "Frequency Policies were successfully saved", "info"))
+}
+
+func (s *SettingsService) SetFrequencyRecording(frequency float32, enabled bool) {
+	err := s.App.SettingsState.SetFrequencyRecorded(state.FrequencyFromMHz(frequency), enabled)
+	if err != nil {
+		s.App.Logger.Error(fmt.Sprintf("Failed to save settings: %v", err))
+		s.App.Notify(events.NewNotification("Failed to save settings", "Failed to save recording settings", "error"))
+		return
+	}
+	s.App.SettingsState.RLock()
+	defer s.App.SettingsState.RUnlock()
+	s.App.App.Event.EmitEvent(&application.CustomEvent{
+		Name: events.SettingsChanged,
+		Data: s.App.SettingsState,
+	})
+	if enabled {
+		s.App.Notify(events.NewNotification("Recording started", fmt.Sprintf("Frequency %.3f is being recorded", frequency), "info"))
+	} else {
+		s.App.Notify(events.NewNotification("Recording stopped", fmt.Sprintf("Frequency %.3f is no longer recorded", frequency), "info"))
+	}
+}
@@ -11,11 +11,12 @@ import (
 	slogmulti "github.com/samber/slog-multi"
 )
 
-func parseFlags(isHeadless bool) (configFilepath, bannedFilePath, distributionMode string, autoStartServers bool, logger *slog.Logger) {
+func parseFlags(isHeadless bool) (configFilepath, bannedFilePath, muteHistoryFilePath, distributionMode string, autoStartServers bool, logger *slog.Logger) {
 	var logFolder string
 	var fileLogEnabled bool
 	flag.StringVar(&configFilepath, "config", "config.yaml", "Path to the configuration file")
 	flag.StringVar(&bannedFilePath, "banned", "banned_clients.json", "Path to the banned clients file")
+	flag.StringVar(&muteHistoryFilePath, "mute-history", "mute_history.json", "Path to the mute history file")
 	flag.StringVar(&logFolder, "log-folder", "log", "Folder to store log files")
 	flag.BoolVar(&autoStartServers, "autostart", false, "Automatically start servers on application startup")
 	flag.BoolVar(&fileLogEnabled, "file-log", true, "Enable file logging")
@@ -47,6 +48,7 @@ func parseFlags(isHeadless bool) (configFilepath, bannedFilePath, distributionMo
 	logger.Info("Auto-start servers", "autostart", autoStartServers)
 	logger.Info("Using config file", "config", configFilepath)
 	logger.Info("Using banned clients file", "bannedFile", bannedFilePath)
+	logger.Info("Using mute history file", "muteHistoryFile", muteHistoryFilePath)
 	logger.Info("Using log folder", "logFolder", logFolder)
 	logger.Info("File logging enabled", "fileLogEnabled", fileLogEnabled)
 	logger.Info("Version", "version", app.Version)
@@ -31,6 +31,13 @@ type AuthServer struct {
 	pluginClients         map[string]*PluginClient
 }
 
+// voiceCredentials secure the voice session of a client, they are handed out on login
+type voiceCredentials struct {
+	secret          []byte // Key to authenticate voice packets with
+	key             []byte // Master key for voice encryption, nil if the client did not negotiate one
+	serverPublicKey []byte // Public key the client derives the master key with
+}
+
 type AuthenticatingClient struct {
 	Name           string
 	Secret         string
@@ -132,10 +139,11 @@ func (s *AuthServer) InitAuth(ctx context.Context, request *pb.ClientAuthInitReq
 		Success: true,
 		InitResult: &pb.ServerAuthInitResponse_Result{
 			Result: &pb.AuthInitResult{
-				DistributionMode: s.GetProtoDistributionMode(),
-				AvailablePlugins: s.settingsState.GetAllPluginNames(),
-				ClientGuid:       clientGuid.String(),
-				HasGuestLogin:    s.settingsState.Security.EnableGuestAuth,
+				DistributionMode:  s.GetProtoDistributionMode(),
+				AvailablePlugins:  s.settingsState.GetAllPluginNames(),
+				ClientGuid:        clientGuid.String(),
+				HasGuestLogin:     s.settingsState.Security.EnableGuestAuth,
+				HasSpectatorLogin: s.hasSpectatorLogin(),
 			},
 		},
 	}, nil
@@ -186,15 +194,15 @@ func (s *AuthServer) GuestLogin(ctx context.Context, request *pb.ClientGuestLogi
 	}
 
 	// Check Password > Select coalition
-	s.mu.Lock()
+	s.settingsState.RLock()
 	var selectedCoalition *state.Coalition
 	for _, coalition := range s.settingsState.Coalitions {
-		if utils.HashPassword(coalition.Password) == request.Password {
-			s.mu.Unlock()
+		if utils.CheckPassword(coalition.Password, request.Password) {
 			selectedCoalition = &coalition
 			break
 		}
 	}
+	s.settingsState.RUnlock()
 
 	if selectedCoalition == nil {
 		return &pb.ServerGuestLoginResponse{
@@ -211,12 +219,23 @@ func (s *AuthServer) GuestLogin(ctx context.Context, request *pb.ClientGuestLogi
 		}, nil
 	}
 
+	voice, err := s.newVoiceCredentials(clientGuid, request.VoicePublicKey)
+	if err != nil {
+		s.logger.Warn("Failed to set up voice credentials for guest login", "ClientGuid", clientGuid, "error", err)
+		return &pb.ServerGuestLoginResponse{
+			Success:     false,
+			LoginResult: &pb.ServerGuestLoginResponse_ErrorMessage{ErrorMessage: err.Error()},
+		}, nil
+	}
+
 	// Add Client to State
 	s.serverState.AddClient(clientGuid, &state.ClientState{
-		Name:      request.Name,
-		UnitId:    request.UnitId,
-		Coalition: selectedCoalition.Name,
-		Role:      utils.GuestRole,
+		Name:        request.Name,
+		UnitId:      request.UnitId,
+		Coalition:   selectedCoalition.Name,
+		Role:        utils.GuestRole,
+		VoiceSecret: voice.secret,
+		VoiceKey:    voice.key,
 	})
 
 	// Return Response
@@ -247,8 +266,120 @@ func (s *AuthServer) GuestLogin(ctx context.Context, request *pb.ClientGuestLogi
 		Success: true,
 		LoginResult: &pb.ServerGuestLoginResponse_Result{
 			Result: &pb.GuestLoginResult{
-				Token:     token,
-				Coalition: selectedCoalition.Name,
+				Token:          token,
+				Coalition:      selectedCoalition.Name,
+				VoiceSecret:    voice.secret,
+				VoicePublicKey: voice.serverPublicKey,
+			},
+		},
+	}, nil
+}
+
+func (s *AuthServer) SpectatorLogin(ctx context.Context, request *pb.ClientSpectatorLoginRequest) (*pb.ServerGuestLoginResponse, error) {
+	p, _ := peer.FromContext(ctx)
+	s.logger.Debug("Getting Spectator Login", "IP", p.Addr.String(), "Name", request.Name)
+
+	// check if This auth type is enabled
+	if !s.hasSpectatorLogin() {
+		return &pb.ServerGuestLoginResponse{
+			Success:     false,
+			LoginResult: &pb.ServerGuestLoginResponse_ErrorMessage{ErrorMessage: "Spectator login is disabled"},
+		}, nil
+	}
+
+	s.removeExpiredAuthenticatingClients()
+
+	// Check if client is initialized
+	clientGuid, err := uuid.Parse(request.ClientGuid)
+	if err != nil {
+		s.logger.Error("Failed to parse ClientGuid", "ClientGuid", request.ClientGuid, "Error", err)
+		return &pb.ServerGuestLoginResponse{
+			Success:     false,
+			LoginResult: &pb.ServerGuestLoginResponse_ErrorMessage{ErrorMessage: "Invalid ClientGuid"},
+		}, err
+	}
+	s.mu.RLock()
+	if _, ok := s.authenticatingClients[clientGuid]; !ok {
+		s.mu.RUnlock()
+		return &pb.ServerGuestLoginResponse{
+			Success:     false,
+			LoginResult: &pb.ServerGuestLoginResponse_ErrorMessage{ErrorMessage: "ClientGuid not found, please initialize first"},
+		}, nil
+	}
+	s.mu.RUnlock()
+
+	// Check Username
+	if !checkUsername(request.Name) {
+		return &pb.ServerGuestLoginResponse{
+			Success:     false,
+			LoginResult: &pb.ServerGuestLoginResponse_ErrorMessage{ErrorMessage: "Invalid username"},
+		}, nil
+	}
+
+	// Check Password
+	spectators := s.settingsState.GetSpectatorSettings()
+	if !utils.CheckPassword(spectators.Password, request.Password) {
+		return &pb.ServerGuestLoginResponse{
+			Success:     false,
+			LoginResult: &pb.ServerGuestLoginResponse_ErrorMessage{ErrorMessage: "Wrong spectator password"},
+		}, nil
+	}
+
+	voice, err := s.newVoiceCredentials(clientGuid, request.VoicePublicKey)
+	if err != nil {
+		s.logger.Warn("Failed to set up voice credentials for spectator login", "ClientGuid", clientGuid, "error", err)
+		return &pb.ServerGuestLoginResponse{
+			Success:     false,
+			LoginResult: &pb.ServerGuestLoginResponse_ErrorMessage{ErrorMessage: err.Error()},
+		}, nil
+	}
+
+	// Add Client to State
+	s.serverState.AddClient(clientGuid, &state.ClientState{
+		Name:        request.Name,
+		Coalition:   spectators.Coalition,
+		Role:        utils.GuestRole,
+		Spectator:   true,
+		VoiceSecret: voice.secret,
+		VoiceKey:    voice.key,
+	})
+
+	s.mu.Lock()
+	delete(s.authenticatingClients, clientGuid)
+	s.mu.Unlock()
+
+	// Return Response
+	s.settingsState.RLock()
+	token, err := utils.GenerateToken(
+		clientGuid.String(),
+		utils.GuestRole,
+		s.settingsState.Security.Token.Issuer,
+		s.settingsState.Security.Token.Subject,
+		time.Duration(s.settingsState.Security.Token.Expiration)*time.Second,
+		s.settingsState.Security.Token.PrivateKeyFile,
+		s.settingsState.Security.Token.PublicKeyFile)
+	s.settingsState.RUnlock()
+	if err != nil {
+		s.logger.Error("Failed to generate token for spectator login", "error", err)
+		return &pb.ServerGuestLoginResponse{
+			Success:     false,
+			LoginResult: &pb.ServerGuestLoginResponse_ErrorMessage{ErrorMessage: "Failed to generate token"},
+		}, err
+	}
+
+	s.logger.Info("spectator login succeeded", "Spectator Name", request.Name, "Coalition", spectators.Coalition, "ClientGuid", clientGuid)
+	s.eventBus.Publish(events.Event{
+		Name: events.ClientsChanged,
+		Data: s.serverState.Clients,
+	})
+	return &pb.ServerGuestLoginResponse{
+		Success: true,
+		LoginResult: &pb.ServerGuestLoginResponse_Result{
+			Result: &pb.GuestLoginResult{
+				Token:          token,
+				Coalition:      spectators.Coalition,
+				VoiceSecret:    voice.secret,
+				VoicePublicKey: voice.serverPublicKey,
 			},
 		},
 	}, nil
@@ -436,19 +567,45 @@ func (s *AuthServer) UnitSelect(ctx context.Context, request *pb.ClientUnitSelec
 		}, nil
 	}
 
-	// Check if the coalition is available
-	if !s.isCoalitionAvailable(request.Coalition) {
+	// Spectators monitor the coalition of the spectator settings instead of the selected one
+	coalition := request.Coalition
+	if request.Spectator {
+		spectators := s.settingsState.GetSpectatorSettings()
+		if !spectators.Enabled || uint8(request.Role) < spectators.PluginRole {
+			return &pb.ServerUnitSelectResponse{
+				Success: false,
+				Result:  &pb.ServerUnitSelectResponse_ErrorMessage{ErrorMessage: "You may not join as spectator"},
+			}, nil
+		}
+		coalition = spectators.Coalition
+	} else if !s.isCoalitionAvailable(request.Coalition) {
 		return &pb.ServerUnitSelectResponse{
 			Success: false,
 			Result:  &pb.ServerUnitSelectResponse_ErrorMessage{ErrorMessage: "Invalid Coalition"},
 		}, nil
 	}
 
+	voice, err := s.newVoiceCredentials(clientGuid, request.VoicePublicKey)
+	if err != nil {
+		s.logger.Warn("Failed to set up voice credentials for unit select", "ClientGuid", clientGuid, "error", err)
+		return &pb.ServerUnitSelectResponse{
+			Success: false,
+			Result:  &pb.ServerUnitSelectResponse_ErrorMessage{ErrorMessage: err.Error()},
+		}, nil
+	}
+
+	unitId := selectedUnit.UnitId
+	if request.Spectator {
+		unitId = ""
+	}
 	s.serverState.AddClient(clientGuid, &state.ClientState{
-		Name:      authClient.Name,
-		UnitId:    selectedUnit.UnitId,
-		Coalition: request.Coalition,
-		Role:      uint8(request.Role),
+		Name:        authClient.Name,
+		UnitId:      unitId,
+		Coalition:   coalition,
+		Role:        uint8(request.Role),
+		Spectator:   request.Spectator,
+		VoiceSecret: voice.secret,
+		VoiceKey:    voice.key,
 	})
 
 	s.mu.Lock()
@@ -481,8 +638,10 @@ func (s *AuthServer) UnitSelect(ctx context.Context, request *pb.ClientUnitSelec
 	})
 
 	return &pb.ServerUnitSelectResponse{
-		Success: true,
-		Result:  &pb.ServerUnitSelectResponse_Token{Token: token},
+		Success:        true,
+		Result:         &pb.ServerUnitSelectResponse_Token{Token: token},
+		VoiceSecret:    voice.secret,
+		VoicePublicKey: voice.serverPublicKey,
 	}, nil
 }
 
@@ -534,6 +693,12 @@ func (s *AuthServer) GetProtoDistributionMode() pb.DistributionMode {
 	}
 }
 
+// hasSpectatorLogin reports if spectators may log in with the spectator password
+func (s *AuthServer) hasSpectatorLogin() bool {
+	spectators := s.settingsState.GetSpectatorSettings()
+	return spectators.Enabled && spectators.Password != ""
+}
+
 func (s *AuthServer) isCoalitionAvailable(selectedCoalition string) bool {
 	var coalitionAvailable bool
 	s.settingsState.RLock()
@@ -547,6 +712,29 @@ func (s *AuthServer) isCoalitionAvailable(selectedCoalition string) bool {
 	return coalitionAvailable
 }
 
+// newVoiceCredentials generates the voice secret and negotiates the voice encryption key, if the client sent a public key.
+// The returned errors are meant for the client.
+func (s *AuthServer) newVoiceCredentials(clientGuid uuid.UUID, clientPublicKey []byte) (*voiceCredentials, error) {
+	secret, err := utils.GenerateVoiceSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate voice secret")
+	}
+	credentials := &voiceCredentials{secret: secret}
+
+	if len(clientPublicKey) == 0 {
+		if s.settingsState.IsVoiceEncryptionRequired() {
+			return nil, fmt.Errorf("voice encryption is required on this server")
+		}
+		return credentials, nil
+	}
+
+	credentials.serverPublicKey, credentials.key, err = utils.DeriveVoiceKey(clientPublicKey, clientGuid.String())
+	if err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
 func (s *AuthServer) removeExpiredAuthenticatingClients() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
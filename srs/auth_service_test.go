@@ -0,0 +1,58 @@
+package srs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	pb "github.com/FPGSchiba/vcs-srs-server/srspb"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/FPGSchiba/vcs-srs-server/utils"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/peer"
+)
+
+func TestSpectatorLogin(t *testing.T) {
+	dir := t.TempDir()
+	serverState := &state.ServerState{}
+	settingsState := &state.SettingsState{
+		Spectators: state.SpectatorSettings{Enabled: true, Password: "spectate", Coalition: "blue"},
+		Security: state.SecuritySettings{Token: state.TokenSettings{
+			Expiration:     60,
+			PrivateKeyFile: filepath.Join(dir, "key.pem"),
+			PublicKeyFile:  filepath.Join(dir, "pubkey.pem"),
+		}},
+	}
+	server := NewAuthServer(serverState, settingsState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, events.NewEventBus())
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5002}})
+
+	login := func(password string) (uuid.UUID, *pb.ServerGuestLoginResponse) {
+		clientGuid := uuid.New()
+		server.authenticatingClients[clientGuid] = &AuthenticatingClient{Expires: time.Now().Add(time.Minute)}
+		response, err := server.SpectatorLogin(ctx, &pb.ClientSpectatorLoginRequest{Name: "Streamer", Password: password, ClientGuid: clientGuid.String()})
+		if err != nil {
+			t.Fatalf("SpectatorLogin() error = %v", err)
+		}
+		return clientGuid, response
+	}
+
+	if _, response := login(utils.HashPassword("wrong")); response.Success {
+		t.Errorf("SpectatorLogin() with the hash of a wrong password succeeded")
+	}
+	if _, response := login("spectate"); response.Success {
+		t.Errorf("SpectatorLogin() with the plain password succeeded, clients send a hash")
+	}
+
+	clientGuid, response := login(utils.HashPassword("spectate"))
+	if !response.Success || response.GetResult().GetToken() == "" || response.GetResult().GetCoalition() != "blue" {
+		t.Fatalf("SpectatorLogin() with the hashed password = %v, want a token for blue", response)
+	}
+	if !serverState.IsSpectator(clientGuid) {
+		t.Errorf("client %s is not a spectator after SpectatorLogin()", clientGuid)
+	}
+}
@@ -6,10 +6,14 @@ import (
 	"github.com/FPGSchiba/vcs-srs-server/events"
 	pb "github.com/FPGSchiba/vcs-srs-server/srspb"
 	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/FPGSchiba/vcs-srs-server/transmissions"
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 	"log/slog"
+	"math"
 	"strings"
 	"sync"
 	"time"
@@ -22,17 +26,19 @@ type SimpleRadioServer struct {
 	serverState   *state.ServerState
 	settingsState *state.SettingsState
 	eventBus      *events.EventBus
-	streams       map[uuid.UUID]grpc.ServerStreamingServer[pb.ServerUpdate]
+	streams       map[uuid.UUID]*clientSubscription
+	transmissions *transmissions.Log
 }
 
-func NewSimpleRadioServer(serverState *state.ServerState, settingsState *state.SettingsState, logger *slog.Logger, bus *events.EventBus) *SimpleRadioServer {
+func NewSimpleRadioServer(serverState *state.ServerState, settingsState *state.SettingsState, logger *slog.Logger, bus *events.EventBus, transmissionLog *transmissions.Log) *SimpleRadioServer {
 	server := SimpleRadioServer{
 		serverState:   serverState,
 		settingsState: settingsState,
 		eventBus:      bus,
+		transmissions: transmissionLog,
 		logger:        logger,
 		mu:            sync.Mutex{},
-		streams:       make(map[uuid.UUID]grpc.ServerStreamingServer[pb.ServerUpdate]),
+		streams:       make(map[uuid.UUID]*clientSubscription),
 	}
 	server.StartCleanupRoutine(time.Second*15, time.Minute*10)
 	return &server
@@ -49,7 +55,8 @@ func (s *SimpleRadioServer) GetServerState() healthpb.HealthCheckResponse_Servin
 	return healthpb.HealthCheckResponse_SERVING
 }
 
-func (s *SimpleRadioServer) SyncClient(_ context.Context, _ *pb.Empty) (*pb.ServerSyncResponse, error) {
+func (s *SimpleRadioServer) SyncClient(ctx context.Context, _ *pb.Empty) (*pb.ServerSyncResponse, error) {
+	requester, _ := ctx.Value("client_id").(string)
 	clients := s.serverState.GetAllClients()
 	radioClients := s.serverState.GetAllRadios()
 
@@ -66,6 +73,7 @@ func (s *SimpleRadioServer) SyncClient(_ context.Context, _ *pb.Empty) (*pb.Serv
 			Name:       client.State.Name,
 			Coalition:  client.State.Coalition,
 			LastUpdate: ptrInt64(client.State.LastUpdate.Unix()),
+			Spectator:  client.State.Spectator,
 		}
 	}
 
@@ -78,7 +86,7 @@ func (s *SimpleRadioServer) SyncClient(_ context.Context, _ *pb.Empty) (*pb.Serv
 		}
 		s.serverState.RLock()
 		srsRadios[radio.ID.String()] = &pb.RadioInfo{
-			Radios:     convertRadios(radio.State.Radios),
+			Radios:     convertRadios(radio.State.Radios, radio.ID.String() == requester),
 			Muted:      radio.State.Muted,
 			LastUpdate: ptrInt64(s.serverState.Clients[radio.ID].LastUpdate.Unix()),
 		}
@@ -106,6 +114,57 @@ func (s *SimpleRadioServer) GetServerSettings(_ context.Context, _ *pb.Empty) (*
 	return s.buildServerSettings(), nil
 }
 
+func (s *SimpleRadioServer) GetTransmissionLog(_ context.Context, req *pb.TransmissionLogRequest) (*pb.TransmissionLogResponse, error) {
+	filter := transmissions.Filter{
+		Frequency:  state.FrequencyFromMHz(req.GetFrequency()),
+		ClientGuid: req.GetClientGuid(),
+		Limit:      int(req.GetLimit()),
+	}
+	if req.From != nil {
+		filter.From = time.UnixMilli(req.GetFrom())
+	}
+	if req.To != nil {
+		filter.To = time.UnixMilli(req.GetTo())
+	}
+
+	records, err := s.transmissions.Query(filter)
+	if err != nil {
+		s.logger.Error("Failed to query transmission log", "error", err)
+		return &pb.TransmissionLogResponse{
+			Success:      false,
+			ErrorMessage: "Failed to read the transmission log",
+		}, nil
+	}
+
+	return &pb.TransmissionLogResponse{
+		Success:       true,
+		Transmissions: convertTransmissions(records),
+	}, nil
+}
+
+func (s *SimpleRadioServer) SetPriorityOverride(ctx context.Context, req *pb.PriorityOverrideRequest) (*pb.ServerResponse, error) {
+	clientID, err := uuid.Parse(req.ClientGuid)
+	if err != nil {
+		return &pb.ServerResponse{
+			Success:      false,
+			ErrorMessage: "Invalid client ID",
+		}, nil
+	}
+	if err := s.serverState.SetPriorityOverride(clientID, req.Enabled); err != nil {
+		return &pb.ServerResponse{
+			Success:      false,
+			ErrorMessage: "Client not found",
+		}, nil
+	}
+
+	s.logger.Info("Priority override changed", "client_id", clientID, "enabled", req.Enabled, "by", ctx.Value("client_id"))
+	s.eventBus.Publish(events.Event{
+		Name: events.ClientsChanged,
+		Data: s.serverState.Clients,
+	})
+	return &pb.ServerResponse{Success: true}, nil
+}
+
 func (s *SimpleRadioServer) Disconnect(ctx context.Context, _ *pb.Empty) (*pb.ServerResponse, error) {
 	clientID, err := uuid.Parse(ctx.Value("client_id").(string))
 	if err != nil {
@@ -131,6 +190,7 @@ func (s *SimpleRadioServer) Disconnect(ctx context.Context, _ *pb.Empty) (*pb.Se
 
 	s.logger.Info("Disconnecting client", "client_id", clientID, "client_name", client.Name)
 	s.cleanupClientState(clientID)
+	s.cancelSubscription(clientID)
 	s.eventBus.Publish(events.Event{
 		Name: events.ClientsChanged,
 		Data: s.serverState.Clients,
@@ -174,22 +234,25 @@ func (s *SimpleRadioServer) UpdateClientInfo(ctx context.Context, req *pb.Client
 		s.serverState.Unlock()
 	}
 
-	if !checkUnitId(req.UnitId) {
-		s.logger.Warn("UpdateClientInfo failed: invalid unit ID", "client_id", clientID, "unit_id", req.UnitId)
-		errors = append(errors, "Invalid unit ID. It must be 2 to 4 uppercase alphanumeric characters.")
-	} else {
-		s.serverState.Lock()
-		client.UnitId = req.UnitId
-		s.serverState.Unlock()
-	}
+	// Spectators keep the coalition they monitor and have no unit
+	if !client.Spectator {
+		if !checkUnitId(req.UnitId) {
+			s.logger.Warn("UpdateClientInfo failed: invalid unit ID", "client_id", clientID, "unit_id", req.UnitId)
+			errors = append(errors, "Invalid unit ID. It must be 2 to 4 uppercase alphanumeric characters.")
+		} else {
+			s.serverState.Lock()
+			client.UnitId = req.UnitId
+			s.serverState.Unlock()
+		}
 
-	if !s.settingsState.DoesCoalitionExist(req.Coalition) {
-		s.logger.Warn("UpdateClientInfo failed: invalid coalition", "client_id", clientID, "coalition", req.Coalition)
-		errors = append(errors, "Coalition not found, please select an existing coalition.")
-	} else {
-		s.serverState.Lock()
-		client.Coalition = req.Coalition
-		s.serverState.Unlock()
+		if !s.settingsState.DoesCoalitionExist(req.Coalition) {
+			s.logger.Warn("UpdateClientInfo failed: invalid coalition", "client_id", clientID, "coalition", req.Coalition)
+			errors = append(errors, "Coalition not found, please select an existing coalition.")
+		} else {
+			s.serverState.Lock()
+			client.Coalition = req.Coalition
+			s.serverState.Unlock()
+		}
 	}
 
 	if !canSwapRoles(client, uint8(req.RoleId)) {
@@ -247,10 +310,36 @@ func (s *SimpleRadioServer) UpdateRadioInfo(ctx context.Context, req *pb.RadioIn
 		}, nil
 	}
 
-	// Client has control over their own radios, so we don't need to check if the radios are valid or not.
-	s.serverState.Lock()
-	s.serverState.RadioClients[clientID] = convertRadioInfo(req)
-	s.serverState.Unlock()
+	for _, radio := range req.Radios {
+		if _, known := pb.Radio_Modulation_name[int32(radio.Modulation)]; !known {
+			s.logger.Info("UpdateRadioInfo rejected: unknown modulation", "client_id", clientID, "radio", radio.Id, "modulation", radio.Modulation)
+			return nil, status.Errorf(codes.InvalidArgument, "radio %s has the unknown modulation %d", radio.Name, radio.Modulation)
+		}
+		if radio.EncryptionKey > math.MaxUint8 {
+			return &pb.ServerResponse{
+				Success:      false,
+				ErrorMessage: fmt.Sprintf("Radio %s has the invalid encryption key %d, keys go from 0 to 255.", radio.Name, radio.EncryptionKey),
+			}, nil
+		}
+	}
+
+	// Client has control over their own radios, so we only check that they may tune them to restricted frequencies.
+	radioState := convertRadioInfo(req)
+	if client, exists := s.serverState.GetClient(clientID); exists {
+		for _, radio := range radioState.Radios {
+			if radio.IsIntercom {
+				continue
+			}
+			if err := s.settingsState.CheckFrequencyAccess(radio.Frequency, client, state.FrequencyAccessListen); err != nil {
+				s.logger.Info("UpdateRadioInfo rejected: frequency policy", "client_id", clientID, "radio", radio.ID, "frequency", radio.Frequency.String(), "reason", err)
+				return &pb.ServerResponse{
+					Success:      false,
+					ErrorMessage: fmt.Sprintf("Radio %s can not be tuned to %s MHz: %v", radio.Name, radio.Frequency, err),
+				}, nil
+			}
+		}
+	}
+	s.serverState.SetRadioState(clientID, radioState)
 
 	s.eventBus.Publish(events.Event{
 		Name: events.RadioClientsChanged,
@@ -263,21 +352,92 @@ func (s *SimpleRadioServer) UpdateRadioInfo(ctx context.Context, req *pb.RadioIn
 	}, nil
 }
 
+func (s *SimpleRadioServer) UpdatePosition(ctx context.Context, req *pb.ClientPosition) (*pb.ServerResponse, error) {
+	clientID, err := uuid.Parse(ctx.Value("client_id").(string))
+	if err != nil {
+		s.logger.Error("UpdatePosition failed: invalid client ID", "error", err)
+		return &pb.ServerResponse{
+			Success:      false,
+			ErrorMessage: "Internal error, please try logging in again.",
+		}, nil
+	}
+
+	position := state.Position{
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Altitude:  req.Altitude,
+	}
+	if err := position.Validate(); err != nil {
+		s.logger.Warn("UpdatePosition failed: invalid position", "client_id", clientID, "error", err)
+		return &pb.ServerResponse{
+			Success:      false,
+			ErrorMessage: fmt.Sprintf("Invalid position: %v", err),
+		}, nil
+	}
+	if err := s.serverState.SetPosition(clientID, position); err != nil {
+		s.logger.Error("UpdatePosition failed: client not found", "client_id", clientID)
+		return &pb.ServerResponse{
+			Success:      false,
+			ErrorMessage: "Internal error: You may already have been disconnected.",
+		}, nil
+	}
+
+	// Positions change constantly, so they are not published on the event bus
+	s.logger.Debug("Updated client position", "client_id", clientID, "latitude", position.Latitude, "longitude", position.Longitude, "altitude", position.Altitude)
+	return &pb.ServerResponse{
+		Success:      true,
+		ErrorMessage: "",
+	}, nil
+}
+
 func (s *SimpleRadioServer) SubscribeToUpdates(_ *pb.Empty, stream grpc.ServerStreamingServer[pb.ServerUpdate]) error {
 	clientID, err := uuid.Parse(stream.Context().Value("client_id").(string))
 	if err != nil {
 		s.logger.Error("SubscribeToUpdates failed: invalid client ID", "error", err)
 		return err
 	}
+	if !s.serverState.DoesClientExist(clientID) {
+		s.logger.Warn("SubscribeToUpdates failed: client not found", "client_id", clientID)
+		return fmt.Errorf("client %s not found, please try logging in again", clientID)
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	if _, exists := s.streams[clientID]; exists {
+		s.mu.Unlock()
 		s.logger.Warn("SubscribeToUpdates: client already subscribed", "client_id", clientID)
 		return fmt.Errorf("client %s is already subscribed to updates", clientID)
 	}
-	s.streams[clientID] = stream
+	s.streams[clientID] = &clientSubscription{
+		stream: stream,
+		cancel: cancel,
+	}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.streams, clientID)
+		s.mu.Unlock()
+	}()
+
 	s.logger.Info("Client subscribed to updates", "client_id", clientID)
-	return nil
+	err = s.streamUpdates(ctx, clientID, stream)
+	if err != nil {
+		s.logger.Warn("Update stream failed", "client_id", clientID, "error", err)
+	}
+	s.logger.Info("Client unsubscribed from updates", "client_id", clientID)
+	return err
+}
+
+// cancelSubscription closes the update stream of a client, if it has one
+func (s *SimpleRadioServer) cancelSubscription(clientID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if subscription, exists := s.streams[clientID]; exists {
+		subscription.cancel()
+	}
 }
 
 func (s *SimpleRadioServer) buildServerSettings() *pb.ServerSettings {
@@ -294,11 +454,17 @@ func (s *SimpleRadioServer) buildServerSettings() *pb.ServerSettings {
 	}
 
 	settings := &pb.ServerSettings{
-		Coalitions:        coalitions,
-		TestFrequencies:   s.settingsState.Frequencies.TestFrequencies,
-		GlobalFrequencies: s.settingsState.Frequencies.GlobalFrequencies,
+		Coalitions:          coalitions,
+		TestFrequencies:     convertFrequencies(s.settingsState.Frequencies.TestFrequencies),
+		GlobalFrequencies:   convertFrequencies(s.settingsState.Frequencies.GlobalFrequencies),
+		PriorityFrequencies: convertFrequencies(s.settingsState.Frequencies.PriorityFrequencies),
+		CoalitionRelations:  convertCoalitionRelations(s.settingsState.CoalitionRelations),
+		GuardFrequencies:    convertFrequencies(s.settingsState.Frequencies.GuardFrequencies),
 		GeneralSettings: &pb.GeneralServerSettings{
-			MaxRadiosPerClient: int32(s.settingsState.General.MaxRadiosPerUser),
+			MaxRadiosPerClient:      int32(s.settingsState.General.MaxRadiosPerUser),
+			RadioEncryption:         s.settingsState.General.RadioEncryption,
+			StrictRadioEncryption:   s.settingsState.General.StrictRadioEncryption,
+			RetransmissionNodeLimit: int32(s.settingsState.Retransmission.NodeLimit),
 		},
 	}
 
@@ -306,39 +472,44 @@ func (s *SimpleRadioServer) buildServerSettings() *pb.ServerSettings {
 }
 
 func (s *SimpleRadioServer) cleanupClientState(clientID uuid.UUID) {
-	s.serverState.Lock()
-	defer s.serverState.Unlock()
-
-	// Remove client from server state
-	if _, exists := s.serverState.Clients[clientID]; exists {
-		delete(s.serverState.Clients, clientID)
-	}
-
-	// Remove radio state if it exists
-	if _, exists := s.serverState.RadioClients[clientID]; exists {
-		delete(s.serverState.RadioClients, clientID)
-	}
+	// Remove client and radio state from server state
+	s.serverState.RemoveClient(clientID)
 }
 
 // StartCleanupRoutine launches a goroutine that periodically removes stale clients.
+// Update streams of clients that no longer exist are closed, clients without a stream are removed once they are stale.
 func (s *SimpleRadioServer) StartCleanupRoutine(interval time.Duration, staleAfter time.Duration) {
 	go func() {
 		for {
 			time.Sleep(interval)
 			now := time.Now()
-			for clientID, stream := range s.streams {
+
+			s.mu.Lock()
+			for clientID, subscription := range s.streams {
+				if !s.serverState.DoesClientExist(clientID) {
+					subscription.cancel()
+					s.logger.Info("Closed update stream of removed client", "client_id", clientID)
+				}
+			}
+			s.mu.Unlock()
+
+			for _, client := range s.serverState.GetAllClients() {
+				s.mu.Lock()
+				_, subscribed := s.streams[client.ID]
+				s.mu.Unlock()
+				if subscribed {
+					continue // An open stream keeps the client alive
+				}
 				s.serverState.RLock()
-				client, exists := s.serverState.Clients[clientID]
+				stale := now.Sub(client.State.LastUpdate) > staleAfter
 				s.serverState.RUnlock()
-				if !exists || now.Sub(client.LastUpdate) > staleAfter {
-					if stream != nil {
-						stream.Context().Done()
-					}
-					s.cleanupClientState(clientID)
-					s.mu.Lock()
-					delete(s.streams, clientID)
-					s.mu.Unlock()
-					s.logger.Info("Cleaned up stale client", "client_id", clientID)
+				if stale {
+					s.cleanupClientState(client.ID)
+					s.logger.Info("Cleaned up stale client", "client_id", client.ID)
+					s.eventBus.Publish(events.Event{
+						Name: events.ClientsChanged,
+						Data: s.serverState.Clients,
+					})
 				}
 			}
 		}
@@ -0,0 +1,42 @@
+package srs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	pb "github.com/FPGSchiba/vcs-srs-server/srspb"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUpdateRadioInfoModulation(t *testing.T) {
+	serverState := &state.ServerState{}
+	server := &SimpleRadioServer{
+		serverState:   serverState,
+		settingsState: &state.SettingsState{},
+		eventBus:      events.NewEventBus(),
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	id := uuid.New()
+	serverState.AddClient(id, &state.ClientState{Name: "Pilot", Coalition: "blue"})
+	serverState.SetRadioState(id, &state.RadioState{})
+	ctx := context.WithValue(context.Background(), "client_id", id.String())
+
+	_, err := server.UpdateRadioInfo(ctx, &pb.RadioInfo{Radios: []*pb.Radio{{Id: 1, Name: "UHF", Frequency: 251, Modulation: pb.Radio_Modulation(7)}}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unknown modulation returned %v, want InvalidArgument", err)
+	}
+
+	response, err := server.UpdateRadioInfo(ctx, &pb.RadioInfo{Radios: []*pb.Radio{{Id: 1, Name: "UHF", Frequency: 251, Modulation: pb.Radio_FM}}})
+	if err != nil || !response.Success {
+		t.Fatalf("UpdateRadioInfo() = %v, %v, want success", response, err)
+	}
+	if radio, _ := serverState.GetRadioOnFrequency(id, 251*state.MHz, state.ChannelPlan{}); radio.Modulation != state.ModulationFM {
+		t.Errorf("modulation = %d, want FM", radio.Modulation)
+	}
+}
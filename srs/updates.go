@@ -0,0 +1,309 @@
+package srs
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	pb "github.com/FPGSchiba/vcs-srs-server/srspb"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/FPGSchiba/vcs-srs-server/transmissions"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+)
+
+// muteCheckInterval is the interval the admin mute of a subscriber is diffed with, even without an event
+const muteCheckInterval = 5 * time.Second
+
+// clientSubscription is an open SubscribeToUpdates stream of a single client
+type clientSubscription struct {
+	stream grpc.ServerStreamingServer[pb.ServerUpdate]
+	cancel context.CancelFunc
+}
+
+// updateSnapshot holds the client and radio state a subscriber was last told about.
+// The event bus only signals that something changed, so every event is diffed against this snapshot.
+type updateSnapshot struct {
+	subscriber uuid.UUID // Client the updates are sent to
+	clients    map[uuid.UUID]state.ClientState
+	radios     map[uuid.UUID]state.RadioState
+	mute       *state.Mute // Admin mute of the subscriber, nil if it is not muted
+}
+
+// streamUpdates bridges the event bus into the update stream of the client until the context is done or sending fails
+func (s *SimpleRadioServer) streamUpdates(ctx context.Context, clientID uuid.UUID, stream grpc.ServerStreamingServer[pb.ServerUpdate]) error {
+	clientsChan := s.eventBus.Subscribe(events.ClientsChanged)
+	defer s.eventBus.Unsubscribe(events.ClientsChanged, clientsChan)
+	radiosChan := s.eventBus.Subscribe(events.RadioClientsChanged)
+	defer s.eventBus.Unsubscribe(events.RadioClientsChanged, radiosChan)
+	settingsChan := s.eventBus.Subscribe(events.SettingsChanged)
+	defer s.eventBus.Unsubscribe(events.SettingsChanged, settingsChan)
+	coalitionsChan := s.eventBus.Subscribe(events.CoalitionsChanged)
+	defer s.eventBus.Unsubscribe(events.CoalitionsChanged, coalitionsChan)
+	mutesChan := s.eventBus.Subscribe(events.ClientMuteChanged)
+	defer s.eventBus.Unsubscribe(events.ClientMuteChanged, mutesChan)
+	conflictsChan := s.eventBus.Subscribe(events.TransmissionConflict)
+	defer s.eventBus.Unsubscribe(events.TransmissionConflict, conflictsChan)
+	cutOffsChan := s.eventBus.Subscribe(events.TransmissionCutOff)
+	defer s.eventBus.Unsubscribe(events.TransmissionCutOff, cutOffsChan)
+	startedChan := s.eventBus.Subscribe(events.TransmissionStarted)
+	defer s.eventBus.Unsubscribe(events.TransmissionStarted, startedChan)
+	endedChan := s.eventBus.Subscribe(events.TransmissionEnded)
+	defer s.eventBus.Unsubscribe(events.TransmissionEnded, endedChan)
+
+	snapshot := &updateSnapshot{
+		subscriber: clientID,
+		clients:    s.snapshotClients(),
+		radios:     s.snapshotRadios(),
+	}
+	muteTicker := time.NewTicker(muteCheckInterval)
+	defer muteTicker.Stop()
+
+	// A reconnecting client is told about its mute right away
+	mute, muted := s.serverState.GetMute(clientID)
+	for _, update := range snapshot.muteUpdates(mute, muted, time.Now()) {
+		if err := stream.Send(update); err != nil {
+			return err
+		}
+	}
+
+	for {
+		var updates []*pb.ServerUpdate
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-clientsChan:
+			updates = snapshot.clientUpdates(s.snapshotClients())
+		case <-radiosChan:
+			updates = snapshot.radioUpdates(s.snapshotRadios())
+		case <-settingsChan:
+			updates = []*pb.ServerUpdate{s.settingsUpdate()}
+		case <-coalitionsChan:
+			updates = []*pb.ServerUpdate{s.settingsUpdate()}
+		case event := <-mutesChan:
+			// Only the muted client is told, others would learn the reasons and admins of every coalition
+			if record, ok := event.Data.(state.MuteRecord); ok && record.ClientGuid == clientID.String() {
+				mute, muted := s.serverState.GetMute(clientID)
+				updates = snapshot.muteUpdates(mute, muted, time.Now())
+			}
+		case now := <-muteTicker.C:
+			// The event bus drops events, so a missed mute or unmute is sent on the next check
+			mute, muted := s.serverState.GetMute(clientID)
+			updates = snapshot.muteUpdates(mute, muted, now)
+		case event := <-conflictsChan:
+			if conflict, ok := event.Data.(transmissions.Conflict); ok && isConflictInvolved(clientID, conflict) {
+				updates = []*pb.ServerUpdate{conflictUpdate(conflict)}
+			}
+		case event := <-cutOffsChan:
+			// Only the sender that was cut off is told
+			if cutOff, ok := event.Data.(transmissions.CutOff); ok && cutOff.ClientGuid == clientID.String() {
+				updates = []*pb.ServerUpdate{cutOffUpdate(cutOff)}
+			}
+		case event := <-startedChan:
+			if record, ok := event.Data.(transmissions.Record); ok && s.isTransmissionVisible(clientID, record) {
+				updates = []*pb.ServerUpdate{transmissionUpdate(pb.ServerUpdate_TRANSMISSION_STARTED, record)}
+			}
+		case event := <-endedChan:
+			if record, ok := event.Data.(transmissions.Record); ok && s.isTransmissionVisible(clientID, record) {
+				updates = []*pb.ServerUpdate{transmissionUpdate(pb.ServerUpdate_TRANSMISSION_ENDED, record)}
+			}
+		}
+
+		for _, update := range updates {
+			if err := stream.Send(update); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *SimpleRadioServer) snapshotClients() map[uuid.UUID]state.ClientState {
+	s.serverState.RLock()
+	defer s.serverState.RUnlock()
+	clients := make(map[uuid.UUID]state.ClientState, len(s.serverState.Clients))
+	for id, client := range s.serverState.Clients {
+		clients[id] = *client
+	}
+	return clients
+}
+
+func (s *SimpleRadioServer) snapshotRadios() map[uuid.UUID]state.RadioState {
+	s.serverState.RLock()
+	defer s.serverState.RUnlock()
+	radios := make(map[uuid.UUID]state.RadioState, len(s.serverState.RadioClients))
+	for id, radio := range s.serverState.RadioClients {
+		radios[id] = state.RadioState{
+			Radios: slices.Clone(radio.Radios),
+			Muted:  radio.Muted,
+		}
+	}
+	return radios
+}
+
+func (s *SimpleRadioServer) settingsUpdate() *pb.ServerUpdate {
+	return &pb.ServerUpdate{
+		Type:   pb.ServerUpdate_SERVER_SETTINGS_CHANGED,
+		Update: &pb.ServerUpdate_SettingsUpdate{SettingsUpdate: s.buildServerSettings()},
+	}
+}
+
+// clientUpdates diffs the current clients against the snapshot and replaces the snapshot afterwards
+func (u *updateSnapshot) clientUpdates(current map[uuid.UUID]state.ClientState) []*pb.ServerUpdate {
+	var updates []*pb.ServerUpdate
+	for id, client := range current {
+		previous, known := u.clients[id]
+		switch {
+		case !known:
+			updates = append(updates, newClientUpdate(pb.ServerUpdate_CLIENT_JOINED, id, &pb.ClientUpdate{ClientInfo: convertClientInfo(client)}))
+		case hasClientInfoChanged(previous, client):
+			updates = append(updates, newClientUpdate(pb.ServerUpdate_CLIENT_INFO_UPDATE, id, &pb.ClientUpdate{ClientInfo: convertClientInfo(client)}))
+		}
+	}
+	for id := range u.clients {
+		if _, exists := current[id]; !exists {
+			updates = append(updates, newClientUpdate(pb.ServerUpdate_CLIENT_LEFT, id, &pb.ClientUpdate{}))
+		}
+	}
+	u.clients = current
+	return updates
+}
+
+// radioUpdates diffs the current radios against the snapshot and replaces the snapshot afterwards.
+// Removed radio states are not reported, as the client leaving is already reported through clientUpdates.
+func (u *updateSnapshot) radioUpdates(current map[uuid.UUID]state.RadioState) []*pb.ServerUpdate {
+	var updates []*pb.ServerUpdate
+	for id, radio := range current {
+		previous, known := u.radios[id]
+		if known && !hasRadioStateChanged(previous, radio) {
+			continue
+		}
+		if !known && len(radio.Radios) == 0 && !radio.Muted {
+			continue // Freshly joined client without radios, nothing to tell yet
+		}
+		updates = append(updates, newClientUpdate(pb.ServerUpdate_CLIENT_RADIO_UPDATE, id, &pb.ClientUpdate{
+			RadioInfo: &pb.RadioInfo{
+				Radios: convertRadios(radio.Radios, id == u.subscriber),
+				Muted:  radio.Muted,
+			},
+		}))
+	}
+	u.radios = current
+	return updates
+}
+
+// muteUpdates diffs the admin mute of the subscriber against the snapshot and replaces the snapshot afterwards
+func (u *updateSnapshot) muteUpdates(mute state.Mute, muted bool, now time.Time) []*pb.ServerUpdate {
+	record := state.MuteRecord{ClientGuid: u.subscriber.String(), Action: state.MuteActionUnmute}
+	switch {
+	case !muted && u.mute == nil, muted && u.mute != nil && *u.mute == mute:
+		return nil
+	case muted:
+		u.mute = &mute
+		record.Action = state.MuteActionMute
+		record.By = mute.MutedBy
+		record.Reason = mute.Reason
+		record.Time = mute.Since
+		record.Until = mute.Until
+	default:
+		u.mute = nil
+	}
+	return []*pb.ServerUpdate{muteUpdate(record, now)}
+}
+
+// muteUpdate turns a mute record into a MUTE or UNMUTE server action, the duration is the time left of the mute
+func muteUpdate(record state.MuteRecord, now time.Time) *pb.ServerUpdate {
+	action := &pb.ServerAction{
+		Type:             pb.ServerAction_UNMUTE,
+		TargetClientGuid: record.ClientGuid,
+		Reason:           record.Reason,
+	}
+	if record.Action == state.MuteActionMute {
+		action.Type = pb.ServerAction_MUTE
+		if !record.Until.IsZero() {
+			action.Duration = ptrInt64(int64(record.Until.Sub(now).Round(time.Second).Seconds()))
+		}
+	}
+	return &pb.ServerUpdate{
+		Type:   pb.ServerUpdate_SERVER_ACTION,
+		Update: &pb.ServerUpdate_ServerAction{ServerAction: action},
+	}
+}
+
+// cutOffUpdate tells the sender of a transmission that exceeded the maximum transmission length that it was cut off
+func cutOffUpdate(cutOff transmissions.CutOff) *pb.ServerUpdate {
+	return &pb.ServerUpdate{
+		Type: pb.ServerUpdate_SERVER_ACTION,
+		Update: &pb.ServerUpdate_ServerAction{ServerAction: &pb.ServerAction{
+			Type:             pb.ServerAction_CUT_OFF,
+			TargetClientGuid: cutOff.ClientGuid,
+			Reason:           fmt.Sprintf("Transmission on %s MHz exceeded %s", cutOff.Frequency, time.Duration(cutOff.MaxDurationMs)*time.Millisecond),
+		}},
+	}
+}
+
+// isConflictInvolved reports if the client is one of the talkers of the conflict. Only they are told about it, others
+// could learn the talkers and frequencies of coalitions they do not hear.
+func isConflictInvolved(clientID uuid.UUID, conflict transmissions.Conflict) bool {
+	return conflict.ClientGuid == clientID.String() || conflict.ActiveClientGuid == clientID.String()
+}
+
+// conflictUpdate tells the clients that a transmission was blocked, pre-empted or overlaps with another one
+func conflictUpdate(conflict transmissions.Conflict) *pb.ServerUpdate {
+	return &pb.ServerUpdate{
+		Type: pb.ServerUpdate_TRANSMISSION_CONFLICT,
+		Update: &pb.ServerUpdate_TransmissionConflict{TransmissionConflict: &pb.TransmissionConflict{
+			Frequency:        conflict.Frequency.MHz(),
+			ClientGuid:       conflict.ClientGuid,
+			ActiveClientGuid: conflict.ActiveClientGuid,
+			Blocked:          conflict.Blocked,
+			Preempted:        conflict.Preempted,
+		}},
+	}
+}
+
+// isTransmissionVisible reports if the client is told about the transmission: it has to hear the coalition of the
+// sender, unless the frequency is global. Test frequencies are only echoed to the sender, so nobody else sees them.
+func (s *SimpleRadioServer) isTransmissionVisible(clientID uuid.UUID, record transmissions.Record) bool {
+	if record.ClientGuid == clientID.String() {
+		return true
+	}
+	frequency := record.Frequency
+	if s.settingsState.IsFrequencyTest(frequency) {
+		return false
+	}
+	if s.settingsState.IsFrequencyGlobal(frequency) {
+		return true
+	}
+	return s.serverState.HearsCoalition(clientID, record.Coalition, s.settingsState.GetCoalitionMatrix())
+}
+
+// transmissionUpdate tells the clients that a client started or ended a transmission
+func transmissionUpdate(updateType pb.ServerUpdate_UpdateType, record transmissions.Record) *pb.ServerUpdate {
+	return &pb.ServerUpdate{
+		Type:   updateType,
+		Update: &pb.ServerUpdate_Transmission{Transmission: convertTransmission(record)},
+	}
+}
+
+func newClientUpdate(updateType pb.ServerUpdate_UpdateType, clientID uuid.UUID, update *pb.ClientUpdate) *pb.ServerUpdate {
+	clientGuid := clientID.String()
+	update.ClientGuid = &clientGuid
+	return &pb.ServerUpdate{
+		Type:   updateType,
+		Update: &pb.ServerUpdate_ClientUpdate{ClientUpdate: update},
+	}
+}
+
+// hasClientInfoChanged ignores LastUpdate, every SyncClient heartbeat bumps it
+func hasClientInfoChanged(previous, current state.ClientState) bool {
+	return previous.Name != current.Name ||
+		previous.UnitId != current.UnitId ||
+		previous.Coalition != current.Coalition ||
+		previous.Role != current.Role
+}
+
+func hasRadioStateChanged(previous, current state.RadioState) bool {
+	return previous.Muted != current.Muted || !slices.Equal(previous.Radios, current.Radios)
+}
@@ -0,0 +1,236 @@
+package srs
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	pb "github.com/FPGSchiba/vcs-srs-server/srspb"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/FPGSchiba/vcs-srs-server/transmissions"
+	"github.com/google/uuid"
+)
+
+func TestClientUpdates(t *testing.T) {
+	stayed := uuid.New()
+	left := uuid.New()
+	joined := uuid.New()
+	now := time.Now()
+
+	snapshot := &updateSnapshot{
+		clients: map[uuid.UUID]state.ClientState{
+			stayed: {Name: "Stayed", Coalition: "blue", LastUpdate: now},
+			left:   {Name: "Left", Coalition: "red", LastUpdate: now},
+		},
+	}
+
+	updates := snapshot.clientUpdates(map[uuid.UUID]state.ClientState{
+		stayed: {Name: "Renamed", Coalition: "blue", LastUpdate: now.Add(time.Second)},
+		joined: {Name: "Joined", Coalition: "red", LastUpdate: now},
+	})
+
+	got := make(map[string]pb.ServerUpdate_UpdateType)
+	for _, update := range updates {
+		got[update.GetClientUpdate().GetClientGuid()] = update.Type
+	}
+	want := map[string]pb.ServerUpdate_UpdateType{
+		stayed.String(): pb.ServerUpdate_CLIENT_INFO_UPDATE,
+		left.String():   pb.ServerUpdate_CLIENT_LEFT,
+		joined.String(): pb.ServerUpdate_CLIENT_JOINED,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("clientUpdates() returned %d updates, want %d", len(got), len(want))
+	}
+	for id, updateType := range want {
+		if got[id] != updateType {
+			t.Errorf("clientUpdates() type for %s = %v, want %v", id, got[id], updateType)
+		}
+	}
+
+	if updates := snapshot.clientUpdates(snapshot.clients); len(updates) != 0 {
+		t.Errorf("clientUpdates() without changes returned %d updates, want 0", len(updates))
+	}
+
+	heartbeat := make(map[uuid.UUID]state.ClientState)
+	for id, client := range snapshot.clients {
+		client.LastUpdate = client.LastUpdate.Add(time.Minute)
+		heartbeat[id] = client
+	}
+	if updates := snapshot.clientUpdates(heartbeat); len(updates) != 0 {
+		t.Errorf("clientUpdates() after a heartbeat returned %d updates, want 0", len(updates))
+	}
+}
+
+func TestRadioUpdates(t *testing.T) {
+	tuned := uuid.New()
+	fresh := uuid.New()
+
+	snapshot := &updateSnapshot{
+		radios: map[uuid.UUID]state.RadioState{
+			tuned: {Radios: []state.Radio{{ID: 1, Frequency: 251 * state.MHz, Enabled: true}}},
+		},
+	}
+
+	updates := snapshot.radioUpdates(map[uuid.UUID]state.RadioState{
+		tuned: {Radios: []state.Radio{{ID: 1, Frequency: 243 * state.MHz, Enabled: true}}},
+		fresh: {Radios: []state.Radio{}},
+	})
+	if len(updates) != 1 {
+		t.Fatalf("radioUpdates() returned %d updates, want 1", len(updates))
+	}
+	if updates[0].Type != pb.ServerUpdate_CLIENT_RADIO_UPDATE || updates[0].GetClientUpdate().GetClientGuid() != tuned.String() {
+		t.Errorf("radioUpdates() = %v, want a radio update for %s", updates[0], tuned)
+	}
+	if freq := updates[0].GetClientUpdate().GetRadioInfo().GetRadios()[0].GetFrequency(); freq != 243.0 {
+		t.Errorf("radioUpdates() frequency = %v, want 243.0", freq)
+	}
+}
+
+func TestRadioUpdatesEncryptionKey(t *testing.T) {
+	subscriber, other := uuid.New(), uuid.New()
+	snapshot := &updateSnapshot{subscriber: subscriber}
+	encrypted := state.RadioState{Radios: []state.Radio{{ID: 1, Frequency: 251 * state.MHz, Enabled: true, EncryptionKey: 7}}}
+
+	updates := snapshot.radioUpdates(map[uuid.UUID]state.RadioState{subscriber: encrypted, other: encrypted})
+	if len(updates) != 2 {
+		t.Fatalf("radioUpdates() returned %d updates, want 2", len(updates))
+	}
+	for _, update := range updates {
+		key := update.GetClientUpdate().GetRadioInfo().GetRadios()[0].GetEncryptionKey()
+		switch update.GetClientUpdate().GetClientGuid() {
+		case subscriber.String():
+			if key != 7 {
+				t.Errorf("own encryption key = %d, want 7", key)
+			}
+		case other.String():
+			if key != 0 {
+				t.Errorf("encryption key of another client = %d, want it omitted", key)
+			}
+		}
+	}
+}
+
+func TestMuteUpdate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		record   state.MuteRecord
+		want     pb.ServerAction_ActionType
+		duration *int64
+	}{
+		{"timed mute", state.MuteRecord{Action: state.MuteActionMute, Until: now.Add(90 * time.Second)}, pb.ServerAction_MUTE, ptrInt64(90)},
+		{"mute until unmuted", state.MuteRecord{Action: state.MuteActionMute}, pb.ServerAction_MUTE, nil},
+		{"unmute", state.MuteRecord{Action: state.MuteActionUnmute}, pb.ServerAction_UNMUTE, nil},
+		{"expired", state.MuteRecord{Action: state.MuteActionExpired, Until: now}, pb.ServerAction_UNMUTE, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.record.ClientGuid = uuid.NewString()
+			tt.record.Reason = "reason"
+			update := muteUpdate(tt.record, now)
+			action := update.GetServerAction()
+			if update.Type != pb.ServerUpdate_SERVER_ACTION || action == nil {
+				t.Fatalf("expected a server action, got %v", update)
+			}
+			if action.Type != tt.want || action.TargetClientGuid != tt.record.ClientGuid || action.Reason != "reason" {
+				t.Errorf("unexpected action: %v", action)
+			}
+			if (action.Duration == nil) != (tt.duration == nil) || (tt.duration != nil && *action.Duration != *tt.duration) {
+				t.Errorf("expected duration %v, got %v", tt.duration, action.Duration)
+			}
+		})
+	}
+}
+
+func TestMuteUpdates(t *testing.T) {
+	now := time.Now()
+	subscriber := uuid.New()
+	snapshot := &updateSnapshot{subscriber: subscriber}
+	mute := state.Mute{MutedBy: "Admin", Reason: "hot mic", Since: now, Until: now.Add(time.Minute)}
+
+	expect := func(updates []*pb.ServerUpdate, want pb.ServerAction_ActionType) {
+		t.Helper()
+		if len(updates) != 1 {
+			t.Fatalf("expected a single update, got %v", updates)
+		}
+		if action := updates[0].GetServerAction(); action.GetType() != want || action.GetTargetClientGuid() != subscriber.String() {
+			t.Errorf("expected a %v of the subscriber, got %v", want, action)
+		}
+	}
+
+	if updates := snapshot.muteUpdates(state.Mute{}, false, now); len(updates) != 0 {
+		t.Errorf("expected no update for a client that is not muted, got %v", updates)
+	}
+	expect(snapshot.muteUpdates(mute, true, now), pb.ServerAction_MUTE)
+	// A mute that was already sent is not sent again, a changed one is
+	if updates := snapshot.muteUpdates(mute, true, now.Add(muteCheckInterval)); len(updates) != 0 {
+		t.Errorf("expected no update for an unchanged mute, got %v", updates)
+	}
+	mute.Until = mute.Until.Add(time.Minute)
+	expect(snapshot.muteUpdates(mute, true, now), pb.ServerAction_MUTE)
+	expect(snapshot.muteUpdates(state.Mute{}, false, now), pb.ServerAction_UNMUTE)
+	if updates := snapshot.muteUpdates(state.Mute{}, false, now); len(updates) != 0 {
+		t.Errorf("expected no update after the unmute was sent, got %v", updates)
+	}
+}
+
+func TestTransmissionVisibility(t *testing.T) {
+	serverState := &state.ServerState{}
+	settingsState := &state.SettingsState{
+		Frequencies: state.FrequencySettings{
+			TestFrequencies:   []state.Frequency{100 * state.MHz},
+			GlobalFrequencies: []state.Frequency{243 * state.MHz},
+		},
+		CoalitionRelations: []state.CoalitionRelation{{Coalition: "green", Other: "blue", Policy: state.CoalitionPolicyOneWay}},
+	}
+	server := &SimpleRadioServer{serverState: serverState, settingsState: settingsState}
+	clients := make(map[string]uuid.UUID)
+	for name, client := range map[string]state.ClientState{
+		"sender":        {Name: "sender", Coalition: "blue"},
+		"blue":          {Name: "blue", Coalition: "blue"},
+		"red":           {Name: "red", Coalition: "red"},
+		"green":         {Name: "green", Coalition: "green"},
+		"red spectator": {Name: "red spectator", Coalition: "red", Spectator: true},
+		"spectator":     {Name: "spectator", Spectator: true},
+	} {
+		id := uuid.New()
+		clients[name] = id
+		serverState.AddClient(id, &client)
+	}
+
+	tests := []struct {
+		frequency state.Frequency
+		visible   []string
+	}{
+		{251 * state.MHz, []string{"sender", "blue", "green", "spectator"}},
+		{243 * state.MHz, []string{"sender", "blue", "red", "green", "red spectator", "spectator"}},
+		{100 * state.MHz, []string{"sender"}},
+	}
+	for _, tt := range tests {
+		record := transmissions.Record{ClientGuid: clients["sender"].String(), Coalition: "blue", Frequency: tt.frequency, Start: time.Now()}
+		for name, id := range clients {
+			want := slices.Contains(tt.visible, name)
+			if got := server.isTransmissionVisible(id, record); got != want {
+				t.Errorf("isTransmissionVisible() for %s on %s MHz = %t, want %t", name, tt.frequency, got, want)
+			}
+		}
+	}
+
+	update := transmissionUpdate(pb.ServerUpdate_TRANSMISSION_ENDED, transmissions.Record{ClientGuid: clients["sender"].String(), Frequency: 251 * state.MHz, DurationMs: 1500})
+	if got := update.GetTransmission(); update.Type != pb.ServerUpdate_TRANSMISSION_ENDED || got.GetClientGuid() != clients["sender"].String() || got.GetDurationMs() != 1500 {
+		t.Errorf("transmissionUpdate() = %v, want the ended transmission of the sender", update)
+	}
+}
+
+func TestIsConflictInvolved(t *testing.T) {
+	blocked, active := uuid.New(), uuid.New()
+	conflict := transmissions.Conflict{ClientGuid: blocked.String(), ActiveClientGuid: active.String(), Frequency: 251 * state.MHz, Blocked: true}
+	for _, id := range []uuid.UUID{blocked, active} {
+		if !isConflictInvolved(id, conflict) {
+			t.Errorf("isConflictInvolved() for talker %s = false, want true", id)
+		}
+	}
+	if isConflictInvolved(uuid.New(), conflict) {
+		t.Errorf("isConflictInvolved() for an uninvolved client = true, want false")
+	}
+}
@@ -3,6 +3,7 @@ package srs
 import (
 	pb "github.com/FPGSchiba/vcs-srs-server/srspb"
 	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/FPGSchiba/vcs-srs-server/transmissions"
 	"github.com/FPGSchiba/vcs-srs-server/utils"
 	"regexp"
 )
@@ -47,20 +48,41 @@ func isRoleAvailable(authClient *AuthenticatingClient, selectedRole uint8) bool
 	return roleAvailable
 }
 
-func convertRadios(radio []state.Radio) []*pb.Radio {
+// convertRadios converts the radios of a client. Encryption keys are only sent to the owner of the radios, other
+// clients must not learn the key slots of nets they are not part of.
+func convertRadios(radio []state.Radio, owner bool) []*pb.Radio {
 	var pbRadios []*pb.Radio
 	for _, r := range radio {
-		pbRadios = append(pbRadios, convertSingleRadio(&r))
+		pbRadios = append(pbRadios, convertSingleRadio(&r, owner))
 	}
 	return pbRadios
 }
 
-func convertSingleRadio(r *state.Radio) *pb.Radio {
-	return &pb.Radio{
-		Id:        r.ID,
-		Name:      r.Name,
-		Frequency: r.Frequency,
-		Enabled:   r.Enabled,
+func convertSingleRadio(r *state.Radio, owner bool) *pb.Radio {
+	radio := &pb.Radio{
+		Id:         r.ID,
+		Name:       r.Name,
+		Frequency:  r.Frequency.MHz(),
+		Enabled:    r.Enabled,
+		IsIntercom: r.IsIntercom,
+		Modulation: pb.Radio_Modulation(r.Modulation),
+		Guard:      r.Guard,
+		Retransmit: r.Retransmit,
+	}
+	if owner {
+		radio.EncryptionKey = uint32(r.EncryptionKey)
+	}
+	return radio
+}
+
+func convertClientInfo(client state.ClientState) *pb.ClientInfo {
+	return &pb.ClientInfo{
+		Name:       client.Name,
+		Coalition:  client.Coalition,
+		UnitId:     client.UnitId,
+		RoleId:     uint32(client.Role),
+		LastUpdate: ptrInt64(client.LastUpdate.Unix()),
+		Spectator:  client.Spectator,
 	}
 }
 
@@ -99,11 +121,68 @@ func convertRadioInfo(radio *pb.RadioInfo) *state.RadioState {
 	}
 }
 
+// convertSingleRadioState keeps is_intercom and the intercom modulation in sync, clients may set either of them
 func convertSingleRadioState(r *pb.Radio) state.Radio {
-	return state.Radio{
-		ID:        r.Id,
-		Name:      r.Name,
-		Frequency: r.Frequency,
-		Enabled:   r.Enabled,
+	radio := state.Radio{
+		ID:            r.Id,
+		Name:          r.Name,
+		Frequency:     state.FrequencyFromMHz(r.Frequency),
+		Modulation:    state.Modulation(r.Modulation), // Validated by UpdateRadioInfo
+		Enabled:       r.Enabled,
+		IsIntercom:    r.IsIntercom || r.Modulation == pb.Radio_INTERCOM,
+		Guard:         r.Guard,
+		EncryptionKey: uint8(r.EncryptionKey), // Validated by UpdateRadioInfo
+		Retransmit:    r.Retransmit,
+	}
+	if radio.IsIntercom {
+		radio.Modulation = state.ModulationIntercom
+	}
+	return radio
+}
+
+func convertFrequencies(frequencies []state.Frequency) []float32 {
+	converted := make([]float32, 0, len(frequencies))
+	for _, frequency := range frequencies {
+		converted = append(converted, frequency.MHz())
+	}
+	return converted
+}
+
+func convertCoalitionRelations(relations state.CoalitionMatrix) []*pb.CoalitionRelation {
+	converted := make([]*pb.CoalitionRelation, 0, len(relations))
+	for _, relation := range relations {
+		policy := pb.CoalitionRelation_ISOLATED
+		switch relation.Policy {
+		case state.CoalitionPolicyAllied:
+			policy = pb.CoalitionRelation_ALLIED
+		case state.CoalitionPolicyOneWay:
+			policy = pb.CoalitionRelation_ONE_WAY
+		}
+		converted = append(converted, &pb.CoalitionRelation{
+			Coalition: relation.Coalition,
+			Other:     relation.Other,
+			Policy:    policy,
+		})
+	}
+	return converted
+}
+
+func convertTransmissions(records []transmissions.Record) []*pb.Transmission {
+	result := make([]*pb.Transmission, 0, len(records))
+	for _, record := range records {
+		result = append(result, convertTransmission(record))
+	}
+	return result
+}
+
+func convertTransmission(record transmissions.Record) *pb.Transmission {
+	return &pb.Transmission{
+		ClientGuid: record.ClientGuid,
+		Name:       record.Name,
+		UnitId:     record.UnitId,
+		Coalition:  record.Coalition,
+		Frequency:  record.Frequency.MHz(),
+		Start:      record.Start.UnixMilli(),
+		DurationMs: record.DurationMs,
 	}
 }
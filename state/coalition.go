@@ -0,0 +1,59 @@
+package state
+
+import "slices"
+
+const (
+	CoalitionPolicyIsolated = "isolated" // Neither coalition hears the other, except on global frequencies
+	CoalitionPolicyAllied   = "allied"   // Both coalitions hear each other
+	CoalitionPolicyOneWay   = "oneWay"   // The coalition hears the other one, but is not heard by it, like spectators or GCI
+)
+
+// CoalitionRelation is the listening policy of a pair of coalitions, see CoalitionPolicyIsolated
+type CoalitionRelation struct {
+	Coalition string `yaml:"coalition"`
+	Other     string `yaml:"other"`
+	Policy    string `yaml:"policy"`
+}
+
+// CoalitionMatrix decides which coalitions hear each other. Clients always hear their own coalition, and pairs of
+// coalitions without a relation are isolated.
+type CoalitionMatrix []CoalitionRelation
+
+// Hears reports if clients of the listener coalition hear transmissions of the speaker coalition
+func (m CoalitionMatrix) Hears(listener, speaker string) bool {
+	if listener == speaker {
+		return true
+	}
+	for _, relation := range m {
+		switch {
+		case relation.Coalition == listener && relation.Other == speaker:
+			return relation.Policy == CoalitionPolicyAllied || relation.Policy == CoalitionPolicyOneWay
+		case relation.Coalition == speaker && relation.Other == listener:
+			return relation.Policy == CoalitionPolicyAllied
+		}
+	}
+	return false
+}
+
+// SharesListeners reports if clients of any coalition hear transmissions of both coalitions, so transmissions of the
+// two compete for the same listeners
+func (m CoalitionMatrix) SharesListeners(coalition, other string) bool {
+	if m.Hears(coalition, other) || m.Hears(other, coalition) {
+		return true
+	}
+	for _, relation := range m {
+		for _, listener := range []string{relation.Coalition, relation.Other} {
+			if m.Hears(listener, coalition) && m.Hears(listener, other) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetCoalitionMatrix returns a copy of the coalition relations
+func (s *SettingsState) GetCoalitionMatrix() CoalitionMatrix {
+	s.RLock()
+	defer s.RUnlock()
+	return slices.Clone(s.CoalitionRelations)
+}
@@ -0,0 +1,114 @@
+package state
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Frequency is a radio frequency in Hz. Frequencies are compared as integers, so values that were converted from
+// floating point MHz like 247.2 and 247.19999997 are not silently different. Config files and JSON use MHz.
+type Frequency uint32
+
+const (
+	Hz  Frequency = 1
+	KHz           = 1000 * Hz
+	MHz           = 1000 * KHz
+)
+
+// FrequencyFromMHz converts a frequency in MHz, like the ones of the SRS protocol, to Hz. The shortest decimal that
+// represents the float32 is used, so 247.2 becomes exactly 247200000 Hz.
+func FrequencyFromMHz(mhz float32) Frequency {
+	decimal, _ := strconv.ParseFloat(strconv.FormatFloat(float64(mhz), 'f', -1, 32), 64)
+	return Frequency(math.Round(max(decimal, 0) * 1e6))
+}
+
+// FrequencyFromKHz converts a frequency in kHz, like the one of voice packets, to Hz
+func FrequencyFromKHz(khz uint32) Frequency {
+	return Frequency(khz) * KHz
+}
+
+// MHz returns the frequency in MHz for the SRS protocol and display
+func (f Frequency) MHz() float32 {
+	return float32(float64(f) / 1e6)
+}
+
+func (f Frequency) String() string {
+	return strconv.FormatFloat(float64(f)/1e6, 'f', -1, 64)
+}
+
+func (f Frequency) MarshalYAML() (interface{}, error) {
+	return float64(f) / 1e6, nil
+}
+
+func (f *Frequency) UnmarshalYAML(node *yaml.Node) error {
+	var mhz float32
+	if err := node.Decode(&mhz); err != nil {
+		return err
+	}
+	*f = FrequencyFromMHz(mhz)
+	return nil
+}
+
+func (f Frequency) MarshalJSON() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+func (f *Frequency) UnmarshalJSON(data []byte) error {
+	var mhz float32
+	if err := json.Unmarshal(data, &mhz); err != nil {
+		return err
+	}
+	*f = FrequencyFromMHz(mhz)
+	return nil
+}
+
+// Modulation of a radio, the values match the Modulation of the SRS protocol
+type Modulation uint8
+
+const (
+	ModulationAM Modulation = iota
+	ModulationFM
+	ModulationIntercom
+)
+
+// ChannelPlan decides when two frequencies are the same channel
+type ChannelPlan struct {
+	Spacing   uint32 // Frequencies are rounded to the nearest multiple in Hz, 0 disables rounding
+	Tolerance uint32 // Rounded frequencies that differ by at most this many Hz are the same channel
+}
+
+// Channel rounds the frequency to the nearest channel
+func (c ChannelPlan) Channel(f Frequency) Frequency {
+	if c.Spacing == 0 {
+		return f
+	}
+	spacing := uint64(c.Spacing)
+	return Frequency((uint64(f) + spacing/2) / spacing * spacing)
+}
+
+// Matches reports if both frequencies are on the same channel
+func (c ChannelPlan) Matches(a, b Frequency) bool {
+	a, b = c.Channel(a), c.Channel(b)
+	if a > b {
+		a, b = b, a
+	}
+	return uint32(b-a) <= c.Tolerance
+}
+
+// Contains reports if any of the frequencies is on the same channel as f
+func (c ChannelPlan) Contains(frequencies []Frequency, f Frequency) bool {
+	for _, frequency := range frequencies {
+		if c.Matches(frequency, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// span is how far apart two frequencies on the same channel can be before rounding
+func (c ChannelPlan) span() uint32 {
+	return c.Spacing + c.Tolerance
+}
@@ -0,0 +1,104 @@
+package state
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// IntercomSession is a multi-crew unit of a coalition whose crew members talk to each other on the intercom
+type IntercomSession struct {
+	Coalition string
+	UnitId    string
+	Members   []string // Guids of all crew members with an enabled intercom
+}
+
+// GetIntercomRadio returns the intercom of the client, preferring an enabled one
+func (s *ServerState) GetIntercomRadio(clientGuid uuid.UUID) (Radio, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	radioState, exists := s.RadioClients[clientGuid]
+	if !exists {
+		return Radio{}, false
+	}
+	var intercom Radio
+	var found bool
+	for _, radio := range radioState.Radios {
+		if !radio.IsIntercom {
+			continue
+		}
+		if radio.Enabled {
+			return radio, true
+		}
+		intercom, found = radio, true
+	}
+	return intercom, found
+}
+
+// GetIntercomListeners returns all clients, except the sender, of the same unit and coalition as the sender with an enabled intercom.
+// The frequency of the intercom does not matter.
+func (s *ServerState) GetIntercomListeners(senderId uuid.UUID) []uuid.UUID {
+	s.RLock()
+	defer s.RUnlock()
+	sender, exists := s.Clients[senderId]
+	if !exists || sender.UnitId == "" {
+		return nil
+	}
+	var listeners []uuid.UUID
+	for clientGuid, client := range s.Clients {
+		if clientGuid == senderId || client.UnitId != sender.UnitId || client.Coalition != sender.Coalition {
+			continue
+		}
+		if s.hasEnabledIntercom(clientGuid) {
+			listeners = append(listeners, clientGuid)
+		}
+	}
+	return listeners
+}
+
+// GetIntercomSessions returns all units with at least two crew members on the intercom, sorted by coalition and unit
+func (s *ServerState) GetIntercomSessions() []IntercomSession {
+	s.RLock()
+	defer s.RUnlock()
+	type unitKey struct{ coalition, unitId string }
+	crews := make(map[unitKey][]string)
+	for clientGuid, client := range s.Clients {
+		if client.UnitId == "" || !s.hasEnabledIntercom(clientGuid) {
+			continue
+		}
+		key := unitKey{client.Coalition, client.UnitId}
+		crews[key] = append(crews[key], clientGuid.String())
+	}
+
+	sessions := make([]IntercomSession, 0, len(crews))
+	for key, members := range crews {
+		if len(members) < 2 {
+			continue // A single crew member has nobody to talk to
+		}
+		slices.Sort(members)
+		sessions = append(sessions, IntercomSession{
+			Coalition: key.coalition,
+			UnitId:    key.unitId,
+			Members:   members,
+		})
+	}
+	slices.SortFunc(sessions, func(a, b IntercomSession) int {
+		if c := strings.Compare(a.Coalition, b.Coalition); c != 0 {
+			return c
+		}
+		return strings.Compare(a.UnitId, b.UnitId)
+	})
+	return sessions
+}
+
+// hasEnabledIntercom has to be called with the lock held
+func (s *ServerState) hasEnabledIntercom(clientGuid uuid.UUID) bool {
+	radioState, exists := s.RadioClients[clientGuid]
+	if !exists {
+		return false
+	}
+	return slices.ContainsFunc(radioState.Radios, func(radio Radio) bool {
+		return radio.IsIntercom && radio.Enabled
+	})
+}
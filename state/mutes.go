@@ -0,0 +1,230 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MuteActionMute    = "mute"
+	MuteActionUnmute  = "unmute"
+	MuteActionExpired = "expired"
+
+	MuteHistoryLimit = 1000 // Records kept in the mute history, the oldest are dropped first
+)
+
+// Mute is a mute of a client by an admin, the client cannot unmute itself
+type Mute struct {
+	MutedBy string
+	Reason  string
+	Since   time.Time
+	Until   time.Time // Zero if the mute lasts until the client is unmuted
+}
+
+func (m Mute) isActive(now time.Time) bool {
+	return m.Until.IsZero() || now.Before(m.Until)
+}
+
+// MuteRecord is an entry of the mute history, recording who muted or unmuted whom and why
+type MuteRecord struct {
+	ClientGuid string
+	ClientName string
+	Action     string // One of MuteActionMute, MuteActionUnmute or MuteActionExpired
+	By         string
+	Reason     string
+	Time       time.Time
+	Until      time.Time // End of the mute for MuteActionMute, zero if it has no duration
+}
+
+// MuteHistoryState is the mute history persisted to a JSON file, see GetMuteHistoryState and SaveMuteHistory
+type MuteHistoryState struct {
+	Records []MuteRecord
+	file    string
+	changes uint64 // Records added since loading, saved is the number written to the file
+	saved   uint64
+}
+
+// GetMuteHistoryState loads the mute history from the file, a missing file is an empty history
+func GetMuteHistoryState(muteHistoryFile string) (*MuteHistoryState, error) {
+	history := &MuteHistoryState{
+		Records: make([]MuteRecord, 0),
+		file:    muteHistoryFile,
+	}
+	data, err := os.ReadFile(muteHistoryFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &history.Records); err != nil {
+			return nil, err
+		}
+	}
+	history.trim()
+	return history, nil
+}
+
+// saveMuteRecords writes the mute history to its file, a history without a file is only kept in memory
+func saveMuteRecords(file string, records []MuteRecord) error {
+	if file == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0600)
+}
+
+func (h *MuteHistoryState) add(record MuteRecord) {
+	h.Records = append(h.Records, record)
+	h.changes++
+	h.trim()
+}
+
+// trim drops the oldest records above MuteHistoryLimit
+func (h *MuteHistoryState) trim() {
+	if over := len(h.Records) - MuteHistoryLimit; over > 0 {
+		h.Records = slices.Delete(h.Records, 0, over)
+	}
+}
+
+// MuteClient mutes the client for the duration, a duration of 0 mutes it until it is unmuted
+func (s *ServerState) MuteClient(clientGuid uuid.UUID, mutedBy, reason string, duration time.Duration) (MuteRecord, error) {
+	s.Lock()
+	defer s.Unlock()
+	client, exists := s.Clients[clientGuid]
+	radioState, existsRadio := s.RadioClients[clientGuid]
+	if !exists || !existsRadio {
+		return MuteRecord{}, fmt.Errorf("client %s not found", clientGuid)
+	}
+
+	now := time.Now()
+	mute := Mute{
+		MutedBy: mutedBy,
+		Reason:  reason,
+		Since:   now,
+	}
+	if duration > 0 {
+		mute.Until = now.Add(duration)
+	}
+	if s.mutes == nil {
+		s.mutes = make(map[uuid.UUID]Mute)
+	}
+	s.mutes[clientGuid] = mute
+	radioState.Muted = true
+
+	return s.recordMute(clientGuid, client.Name, MuteActionMute, mutedBy, reason, now, mute.Until), nil
+}
+
+// UnmuteClient lifts the mute of the client, including a mute the client set itself
+func (s *ServerState) UnmuteClient(clientGuid uuid.UUID, unmutedBy string) (MuteRecord, error) {
+	s.Lock()
+	defer s.Unlock()
+	client, exists := s.Clients[clientGuid]
+	radioState, existsRadio := s.RadioClients[clientGuid]
+	if !exists || !existsRadio {
+		return MuteRecord{}, fmt.Errorf("client %s not found", clientGuid)
+	}
+
+	delete(s.mutes, clientGuid)
+	radioState.Muted = false
+
+	return s.recordMute(clientGuid, client.Name, MuteActionUnmute, unmutedBy, "", time.Now(), time.Time{}), nil
+}
+
+// ExpireMutes lifts all mutes that ran out before now and returns the records of the expired mutes
+func (s *ServerState) ExpireMutes(now time.Time) []MuteRecord {
+	s.Lock()
+	defer s.Unlock()
+	var expired []MuteRecord
+	for clientGuid, mute := range s.mutes {
+		if mute.isActive(now) {
+			continue
+		}
+		delete(s.mutes, clientGuid)
+		var name string
+		if client, exists := s.Clients[clientGuid]; exists {
+			name = client.Name
+		}
+		if radioState, exists := s.RadioClients[clientGuid]; exists {
+			radioState.Muted = false
+		}
+		expired = append(expired, s.recordMute(clientGuid, name, MuteActionExpired, "", mute.Reason, now, mute.Until))
+	}
+	return expired
+}
+
+// IsMuted reports if the voice of the client has to be dropped, either because it muted itself or was muted by an admin
+func (s *ServerState) IsMuted(clientGuid uuid.UUID, now time.Time) bool {
+	s.RLock()
+	defer s.RUnlock()
+	if mute, exists := s.mutes[clientGuid]; exists && mute.isActive(now) {
+		return true
+	}
+	radioState, exists := s.RadioClients[clientGuid]
+	return exists && radioState.Muted
+}
+
+// GetMute returns the admin mute of the client
+func (s *ServerState) GetMute(clientGuid uuid.UUID) (Mute, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	mute, exists := s.mutes[clientGuid]
+	return mute, exists
+}
+
+// GetMuteHistory returns a copy of the last MuteHistoryLimit recorded mutes, unmutes and expired mutes
+func (s *ServerState) GetMuteHistory() []MuteRecord {
+	s.RLock()
+	defer s.RUnlock()
+	return slices.Clone(s.MuteHistory.Records)
+}
+
+// SaveMuteHistory persists the mute history if mutes were recorded since it was saved last. Only the copy of the
+// history is taken under the lock, so it does not hold up routing while writing the file.
+func (s *ServerState) SaveMuteHistory() error {
+	s.muteHistorySaveMu.Lock()
+	defer s.muteHistorySaveMu.Unlock()
+
+	s.RLock()
+	changes := s.MuteHistory.changes
+	if changes == s.MuteHistory.saved {
+		s.RUnlock()
+		return nil
+	}
+	file := s.MuteHistory.file
+	records := slices.Clone(s.MuteHistory.Records)
+	s.RUnlock()
+
+	if err := saveMuteRecords(file, records); err != nil {
+		return err
+	}
+	s.Lock()
+	s.MuteHistory.saved = changes
+	s.Unlock()
+	return nil
+}
+
+// recordMute has to be called with the lock held
+func (s *ServerState) recordMute(clientGuid uuid.UUID, name, action, by, reason string, now, until time.Time) MuteRecord {
+	record := MuteRecord{
+		ClientGuid: clientGuid.String(),
+		ClientName: name,
+		Action:     action,
+		By:         by,
+		Reason:     reason,
+		Time:       now,
+		Until:      until,
+	}
+	s.MuteHistory.add(record)
+	return record
+}
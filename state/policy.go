@@ -0,0 +1,129 @@
+package state
+
+import (
+	"fmt"
+	"slices"
+)
+
+// FrequencyPolicy restricts a range of frequencies, like a command net, to some coalitions, units and roles.
+// Roles are the numeric roles of the utils package, see utils.GuestRole.
+type FrequencyPolicy struct {
+	Name         string    `yaml:"name"`
+	From         Frequency `yaml:"from"`         // Lowest frequency of the range in MHz
+	To           Frequency `yaml:"to"`           // Highest frequency of the range in MHz, 0 for a single frequency
+	Coalitions   []string  `yaml:"coalitions"`   // Coalitions that may use the range, empty allows all
+	Units        []string  `yaml:"units"`        // Units that may use the range, empty allows all
+	ListenRole   uint8     `yaml:"listenRole"`   // Minimum role to tune a radio to the range and hear it
+	TransmitRole uint8     `yaml:"transmitRole"` // Minimum role to transmit on the range
+}
+
+// FrequencyAccess is what a client wants to do on a frequency
+type FrequencyAccess uint8
+
+const (
+	FrequencyAccessListen FrequencyAccess = iota
+	FrequencyAccessTransmit
+)
+
+func (a FrequencyAccess) String() string {
+	if a == FrequencyAccessTransmit {
+		return "transmit"
+	}
+	return "listen"
+}
+
+// upper returns the highest frequency of the range
+func (p FrequencyPolicy) upper() Frequency {
+	return max(p.From, p.To)
+}
+
+// Contains reports if the frequency is inside the range, frequencies on the same channel as its bounds included
+func (p FrequencyPolicy) Contains(plan ChannelPlan, freq Frequency) bool {
+	return (freq >= p.From && freq <= p.upper()) || plan.Matches(p.From, freq) || plan.Matches(p.upper(), freq)
+}
+
+// Check returns why the client may not use the range for the access, nil if it may. Transmitting requires the
+// listen role as well, since a client has to be tuned to the range to transmit on it.
+func (p FrequencyPolicy) Check(client ClientState, access FrequencyAccess) error {
+	if len(p.Coalitions) > 0 && !slices.Contains(p.Coalitions, client.Coalition) {
+		return fmt.Errorf("%s is reserved for other coalitions", p)
+	}
+	if len(p.Units) > 0 && !slices.Contains(p.Units, client.UnitId) {
+		return fmt.Errorf("%s is reserved for other units", p)
+	}
+	role := p.ListenRole
+	if access == FrequencyAccessTransmit {
+		role = max(role, p.TransmitRole)
+	}
+	if client.Role < role {
+		return fmt.Errorf("your role may not %s on %s", access, p)
+	}
+	return nil
+}
+
+func (p FrequencyPolicy) String() string {
+	frequencies := p.From.String()
+	if p.upper() != p.From {
+		frequencies += "-" + p.upper().String()
+	}
+	if p.Name == "" {
+		return frequencies + " MHz"
+	}
+	return fmt.Sprintf("%s (%s MHz)", p.Name, frequencies)
+}
+
+// GetFrequencyPolicies returns a copy of all frequency policies
+func (s *SettingsState) GetFrequencyPolicies() []FrequencyPolicy {
+	s.RLock()
+	defer s.RUnlock()
+	policies := make([]FrequencyPolicy, len(s.FrequencyPolicies))
+	for i, policy := range s.FrequencyPolicies {
+		policy.Coalitions = slices.Clone(policy.Coalitions)
+		policy.Units = slices.Clone(policy.Units)
+		policies[i] = policy
+	}
+	return policies
+}
+
+// SetFrequencyPolicies replaces all frequency policies and saves the settings
+func (s *SettingsState) SetFrequencyPolicies(policies []FrequencyPolicy) error {
+	for _, policy := range policies {
+		if policy.From == 0 {
+			return fmt.Errorf("frequency policy %s has no frequency", policy)
+		}
+		if policy.To != 0 && policy.To < policy.From {
+			return fmt.Errorf("frequency policy %s ends before it starts", policy)
+		}
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.FrequencyPolicies = policies
+	return s.Save()
+}
+
+// IsFrequencyRestricted reports if any frequency policy covers the frequency
+func (s *SettingsState) IsFrequencyRestricted(freq Frequency) bool {
+	s.RLock()
+	defer s.RUnlock()
+	plan := s.channelPlan()
+	return slices.ContainsFunc(s.FrequencyPolicies, func(policy FrequencyPolicy) bool {
+		return policy.Contains(plan, freq)
+	})
+}
+
+// CheckFrequencyAccess returns why the client may not use the frequency for the access, nil if every frequency policy
+// covering the frequency allows it
+func (s *SettingsState) CheckFrequencyAccess(freq Frequency, client ClientState, access FrequencyAccess) error {
+	s.RLock()
+	defer s.RUnlock()
+	plan := s.channelPlan()
+	for _, policy := range s.FrequencyPolicies {
+		if !policy.Contains(plan, freq) {
+			continue
+		}
+		if err := policy.Check(client, access); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package state
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+)
+
+// PriorityOverrideRank is the transmit rank of a client with a priority override, above the rank of every role
+const PriorityOverrideRank = math.MaxUint8 + 1
+
+// SetPriorityOverride lets the transmissions of the client pre-empt everybody on any frequency, for emergency broadcasts
+func (s *ServerState) SetPriorityOverride(clientGuid uuid.UUID, enabled bool) error {
+	s.Lock()
+	defer s.Unlock()
+	client, exists := s.Clients[clientGuid]
+	if !exists {
+		return fmt.Errorf("client %s not found", clientGuid)
+	}
+	client.PriorityOverride = enabled
+	return nil
+}
+
+// GetTransmitRank returns the rank the transmissions of the client have on priority frequencies,
+// which is its role or PriorityOverrideRank with a priority override
+func (s *ServerState) GetTransmitRank(clientGuid uuid.UUID) int {
+	s.RLock()
+	defer s.RUnlock()
+	client, exists := s.Clients[clientGuid]
+	if !exists {
+		return 0
+	}
+	if client.PriorityOverride {
+		return PriorityOverrideRank
+	}
+	return int(client.Role)
+}
@@ -0,0 +1,84 @@
+package state
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// Position of a client as reported with UpdatePosition
+type Position struct {
+	Latitude  float64 // Degrees, positive north
+	Longitude float64 // Degrees, positive east
+	Altitude  float64 // Meters above sea level
+}
+
+// Validate returns why the position is not on earth, nil if it is
+func (p Position) Validate() error {
+	switch {
+	case math.IsNaN(p.Latitude) || p.Latitude < -90 || p.Latitude > 90:
+		return fmt.Errorf("latitude %v is not between -90 and 90 degrees", p.Latitude)
+	case math.IsNaN(p.Longitude) || p.Longitude < -180 || p.Longitude > 180:
+		return fmt.Errorf("longitude %v is not between -180 and 180 degrees", p.Longitude)
+	case math.IsNaN(p.Altitude) || math.IsInf(p.Altitude, 0):
+		return fmt.Errorf("altitude %v is not a number", p.Altitude)
+	}
+	return nil
+}
+
+// PropagationSettings simulate the range of radios from the positions of the clients, like LOS_ENABLED and
+// DISTANCE_ENABLED of SRS. Transmissions between clients that did not report a position are always heard.
+type PropagationSettings struct {
+	Distance    bool        `yaml:"distance"`    // Receivers beyond the range of the band of the frequency do not hear a transmission
+	LineOfSight bool        `yaml:"lineOfSight"` // Receivers beyond the radio horizon do not hear a transmission
+	Bands       []RadioBand `yaml:"bands"`       // Ranges of the radio bands, frequencies outside every band have no range limit
+}
+
+// RadioBand is the free-space range of radios in a range of frequencies
+type RadioBand struct {
+	Name  string    `yaml:"name"`
+	From  Frequency `yaml:"from"`  // Lowest frequency of the band in MHz
+	To    Frequency `yaml:"to"`    // Highest frequency of the band in MHz
+	Range float64   `yaml:"range"` // Kilometers a transmission in the band is heard over
+}
+
+// DefaultRadioBands are the bands of new configs
+var DefaultRadioBands = []RadioBand{
+	{Name: "HF", From: 1_500 * KHz, To: 30 * MHz, Range: 1000},
+	{Name: "VHF", From: 30 * MHz, To: 300 * MHz, Range: 200},
+	{Name: "UHF", From: 300 * MHz, To: 3_000 * MHz, Range: 150},
+}
+
+// GetPropagationSettings returns a copy of the propagation settings
+func (s *SettingsState) GetPropagationSettings() PropagationSettings {
+	s.RLock()
+	defer s.RUnlock()
+	settings := s.Propagation
+	settings.Bands = slices.Clone(settings.Bands)
+	return settings
+}
+
+// SetPosition stores the position the client reported
+func (s *ServerState) SetPosition(clientGuid uuid.UUID, position Position) error {
+	s.Lock()
+	defer s.Unlock()
+	client, exists := s.Clients[clientGuid]
+	if !exists {
+		return fmt.Errorf("client %s not found", clientGuid)
+	}
+	client.Position = &position
+	return nil
+}
+
+// GetPosition returns the last position the client reported, ok is false if it never reported one
+func (s *ServerState) GetPosition(clientGuid uuid.UUID) (Position, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	client, exists := s.Clients[clientGuid]
+	if !exists || client.Position == nil {
+		return Position{}, false
+	}
+	return *client.Position, true
+}
@@ -0,0 +1,79 @@
+package state
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// RetransmissionSettings configure the retransmission of voice from one frequency onto another, like the
+// retransmission nodes of SRS
+type RetransmissionSettings struct {
+	NodeLimit int     `yaml:"nodeLimit"` // Relays a transmission may pass, 0 disables retransmission
+	Relays    []Relay `yaml:"relays"`    // Static relays of the server
+}
+
+// Relay retransmits all voice received on one frequency onto another
+type Relay struct {
+	Name string    `yaml:"name"`
+	From Frequency `yaml:"from"` // Frequency the relay receives on in MHz
+	To   Frequency `yaml:"to"`   // Frequency the relay transmits on in MHz
+}
+
+func (r Relay) String() string {
+	if r.Name == "" {
+		return fmt.Sprintf("%s-%s MHz", r.From, r.To)
+	}
+	return fmt.Sprintf("%s (%s-%s MHz)", r.Name, r.From, r.To)
+}
+
+// GetRetransmissionSettings returns a copy of the retransmission settings
+func (s *SettingsState) GetRetransmissionSettings() RetransmissionSettings {
+	s.RLock()
+	defer s.RUnlock()
+	settings := s.Retransmission
+	settings.Relays = slices.Clone(settings.Relays)
+	return settings
+}
+
+// SetRelays replaces the static relays and saves the settings
+func (s *SettingsState) SetRelays(relays []Relay) error {
+	for _, relay := range relays {
+		if relay.From == 0 || relay.To == 0 {
+			return fmt.Errorf("relay %s has no frequency", relay)
+		}
+		if relay.From == relay.To {
+			return fmt.Errorf("relay %s transmits on the frequency it receives on", relay)
+		}
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.Retransmission.Relays = relays
+	return s.Save()
+}
+
+// GetRetransmitFrequencies returns the frequencies the client retransmits voice received on the frequency onto.
+// A client is a retransmission node if it has an enabled radio with retransmit set tuned to the frequency, and it
+// retransmits onto the frequencies of all its other enabled radios with retransmit set.
+func (s *ServerState) GetRetransmitFrequencies(clientGuid uuid.UUID, frequency Frequency, plan ChannelPlan) []Frequency {
+	s.RLock()
+	defer s.RUnlock()
+	radioState, exists := s.RadioClients[clientGuid]
+	if !exists {
+		return nil
+	}
+	receiving := slices.ContainsFunc(radioState.Radios, func(radio Radio) bool {
+		return radio.Retransmit && radio.Enabled && !radio.IsIntercom && plan.Matches(radio.Frequency, frequency)
+	})
+	if !receiving {
+		return nil
+	}
+	var frequencies []Frequency
+	for _, radio := range radioState.Radios {
+		if radio.Retransmit && radio.Enabled && !radio.IsIntercom && !plan.Matches(radio.Frequency, frequency) {
+			frequencies = append(frequencies, radio.Frequency)
+		}
+	}
+	return frequencies
+}
@@ -15,14 +15,43 @@ type ServerState struct {
 	Clients      map[uuid.UUID]*ClientState
 	RadioClients map[uuid.UUID]*RadioState
 	BannedState  BannedState
+	MuteHistory  MuteHistoryState
+	// frequencyIndex maps a band of frequencyIndexBand Hz to all clients with an enabled radio in it
+	frequencyIndex map[uint32]map[uuid.UUID]struct{}
+	// guardIndex holds all clients with an enabled radio with a guard receiver
+	guardIndex map[uuid.UUID]struct{}
+	// mutes holds the clients muted by an admin, see mutes.go
+	mutes map[uuid.UUID]Mute
+	// muteHistorySaveMu keeps saves of the mute history in order, see SaveMuteHistory
+	muteHistorySaveMu sync.Mutex
 }
 
 type ClientState struct {
-	Name       string
-	UnitId     string
-	Coalition  string
-	Role       uint8
-	LastUpdate time.Time
+	Name        string
+	UnitId      string
+	Coalition   string
+	Role        uint8
+	LastUpdate  time.Time
+	VoiceSecret []byte `json:"-"` // Secret the client authenticates its voice packets with
+	VoiceKey    []byte `json:"-"` // Master key for voice encryption negotiated on login, nil if the client does not encrypt
+	// PriorityOverride is set by an admin for emergency broadcasts, see SetPriorityOverride
+	PriorityOverride bool
+	// Spectator clients only listen. They hear the coalition they monitor, all coalitions if it is empty, and have no unit.
+	Spectator bool
+	// VoiceQuality are the statistics of the voice the client sent, by frequency, see SetVoiceQuality
+	VoiceQuality []VoiceQuality
+	// Position is the last position the client reported, nil if it never reported one, see SetPosition
+	Position *Position
+}
+
+// VoiceQuality are the statistics of the voice a client sent on a single frequency, derived from the sequence numbers
+type VoiceQuality struct {
+	Frequency  Frequency
+	Received   uint64
+	Lost       uint64
+	Reordered  uint64
+	Duplicates uint64
+	JitterMs   float64 // Smoothed inter-arrival jitter as described in RFC 3550
 }
 
 type RadioState struct {
@@ -33,11 +62,20 @@ type RadioState struct {
 type Radio struct {
 	ID         uint32
 	Name       string
-	Frequency  float32
+	Frequency  Frequency
+	Modulation Modulation // Radios only hear each other with the same modulation, intercoms are ModulationIntercom
 	Enabled    bool
 	IsIntercom bool
+	Guard      bool // Guard receiver, hears the guard frequencies in parallel with the tuned frequency
+	// EncryptionKey is the key slot the radio encrypts and decrypts with, 0 is clear, see GeneralSettings.RadioEncryption
+	EncryptionKey uint8
+	// Retransmit makes the client a retransmission node between all its enabled radios with retransmit set, see
+	// GetRetransmitFrequencies
+	Retransmit bool
 }
 
+const frequencyIndexBand = 25_000 // Hz of the frequency bands the listeners are indexed by
+
 type BannedState struct {
 	BannedClients []BannedClient
 	file          string
@@ -115,10 +153,86 @@ func (s *ServerState) AddClient(clientGuid uuid.UUID, client *ClientState) {
 	if s.Clients == nil {
 		s.Clients = make(map[uuid.UUID]*ClientState)
 	}
+	if s.RadioClients == nil {
+		s.RadioClients = make(map[uuid.UUID]*RadioState)
+	}
+	if client.LastUpdate.IsZero() {
+		client.LastUpdate = time.Now()
+	}
 	s.Clients[clientGuid] = client
-	s.RadioClients[clientGuid] = &RadioState{
+	s.setRadioState(clientGuid, &RadioState{
 		Radios: []Radio{},
 		Muted:  false,
+	})
+}
+
+// SetRadioState replaces the radios of a client and keeps the frequency index up to date
+func (s *ServerState) SetRadioState(clientGuid uuid.UUID, radioState *RadioState) {
+	s.Lock()
+	defer s.Unlock()
+	s.setRadioState(clientGuid, radioState)
+}
+
+// RemoveClient removes the client and its radios from the state
+func (s *ServerState) RemoveClient(clientGuid uuid.UUID) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.Clients, clientGuid)
+	delete(s.mutes, clientGuid)
+	if radioState, exists := s.RadioClients[clientGuid]; exists {
+		s.unindexRadios(clientGuid, radioState.Radios)
+		delete(s.RadioClients, clientGuid)
+	}
+}
+
+func (s *ServerState) setRadioState(clientGuid uuid.UUID, radioState *RadioState) {
+	if s.RadioClients == nil {
+		s.RadioClients = make(map[uuid.UUID]*RadioState)
+	}
+	if previous, exists := s.RadioClients[clientGuid]; exists {
+		s.unindexRadios(clientGuid, previous.Radios)
+	}
+	if _, muted := s.mutes[clientGuid]; muted {
+		radioState.Muted = true // Clients cannot lift a mute of an admin by updating their radios
+	}
+	s.RadioClients[clientGuid] = radioState
+	s.indexRadios(clientGuid, radioState.Radios)
+}
+
+func (s *ServerState) indexRadios(clientGuid uuid.UUID, radios []Radio) {
+	if s.frequencyIndex == nil {
+		s.frequencyIndex = make(map[uint32]map[uuid.UUID]struct{})
+		s.guardIndex = make(map[uuid.UUID]struct{})
+	}
+	for _, radio := range radios {
+		if !radio.Enabled || radio.IsIntercom {
+			continue // Intercoms are not reachable by frequency, see GetIntercomListeners
+		}
+		if radio.Guard {
+			s.guardIndex[clientGuid] = struct{}{}
+		}
+		band := uint32(radio.Frequency) / frequencyIndexBand
+		listeners, exists := s.frequencyIndex[band]
+		if !exists {
+			listeners = make(map[uuid.UUID]struct{})
+			s.frequencyIndex[band] = listeners
+		}
+		listeners[clientGuid] = struct{}{}
+	}
+}
+
+func (s *ServerState) unindexRadios(clientGuid uuid.UUID, radios []Radio) {
+	delete(s.guardIndex, clientGuid)
+	for _, radio := range radios {
+		band := uint32(radio.Frequency) / frequencyIndexBand
+		listeners, exists := s.frequencyIndex[band]
+		if !exists {
+			continue
+		}
+		delete(listeners, clientGuid)
+		if len(listeners) == 0 {
+			delete(s.frequencyIndex, band)
+		}
 	}
 }
 
@@ -160,11 +274,11 @@ func (s *ServerState) GetAllRadios() []struct {
 	return radios
 }
 
-func (s *ServerState) GetAllEnabledFrequencies(clientGuid uuid.UUID) []float32 {
+func (s *ServerState) GetAllEnabledFrequencies(clientGuid uuid.UUID) []Frequency {
 	s.RLock()
 	defer s.RUnlock()
 	if clientState, exists := s.RadioClients[clientGuid]; exists {
-		var enabledFrequencies []float32
+		var enabledFrequencies []Frequency
 		for _, radio := range clientState.Radios {
 			if radio.Enabled {
 				enabledFrequencies = append(enabledFrequencies, radio.Frequency)
@@ -175,26 +289,183 @@ func (s *ServerState) GetAllEnabledFrequencies(clientGuid uuid.UUID) []float32 {
 	return nil
 }
 
-func (s *ServerState) IsListeningOnFrequency(clientGuid, senderId uuid.UUID, frequency float32, globalFreq bool) bool {
+// IsListeningOnFrequency reports if the client hears a transmission of the sender on the frequency. It needs an enabled
+// radio on the same channel with the modulation of the radio the sender transmits with, or an enabled guard receiver
+// if the frequency is a guard frequency. Its coalition has to hear the coalition of the sender unless the frequency is
+// global.
+func (s *ServerState) IsListeningOnFrequency(clientGuid, senderId uuid.UUID, frequency Frequency, globalFreq, guardFreq bool, plan ChannelPlan, coalitions CoalitionMatrix) bool {
 	s.RLock()
 	defer s.RUnlock()
-	if sender, exists := s.Clients[senderId]; exists {
-		if clientState, exists := s.RadioClients[clientGuid]; exists {
-			receiver := s.Clients[senderId]
-			if !globalFreq && sender.Coalition != receiver.Coalition {
-				return false // Different coalitions cannot listen to each other
+	senderRadio, tuned := s.radioOnFrequency(senderId, frequency, plan)
+	return s.isListeningOnFrequency(clientGuid, senderId, frequency, globalFreq, guardFreq, plan, coalitions, senderRadio, tuned)
+}
+
+// GetListeningClients returns all clients, except the sender, that hear a transmission of the sender on the frequency.
+// Only clients with an enabled radio in the bands around the frequency, or a guard receiver on guard frequencies, are
+// looked at, so this scales with the listeners and not all clients.
+func (s *ServerState) GetListeningClients(senderId uuid.UUID, frequency Frequency, globalFreq, guardFreq bool, plan ChannelPlan, coalitions CoalitionMatrix) []uuid.UUID {
+	s.RLock()
+	defer s.RUnlock()
+	senderRadio, tuned := s.radioOnFrequency(senderId, frequency, plan)
+	lowBand := (uint32(frequency) - min(uint32(frequency), plan.span())) / frequencyIndexBand
+	highBand := (uint32(frequency) + plan.span()) / frequencyIndexBand
+	clients := make([]uuid.UUID, 0, len(s.frequencyIndex[uint32(frequency)/frequencyIndexBand]))
+	var seen map[uuid.UUID]struct{} // Clients in multiple of the bands or the guard index, only needed if there are several
+	if highBand > lowBand || guardFreq {
+		seen = make(map[uuid.UUID]struct{})
+	}
+	check := func(candidates map[uuid.UUID]struct{}) {
+		for clientGuid := range candidates {
+			if clientGuid == senderId {
+				continue
 			}
-			for _, radio := range clientState.Radios {
-				if radio.Frequency == frequency {
-					return radio.Enabled
+			if seen != nil {
+				if _, exists := seen[clientGuid]; exists {
+					continue
 				}
+				seen[clientGuid] = struct{}{}
+			}
+			if s.isListeningOnFrequency(clientGuid, senderId, frequency, globalFreq, guardFreq, plan, coalitions, senderRadio, tuned) {
+				clients = append(clients, clientGuid)
 			}
 		}
 	}
+	for band := lowBand; band <= highBand; band++ {
+		check(s.frequencyIndex[band])
+	}
+	if guardFreq {
+		check(s.guardIndex)
+	}
+	return clients
+}
 
+// isListeningOnFrequency has to be called with the lock held. Without a tuned radio of the sender, which the lenient
+// transmit policy forwards, the modulation is not checked. Guard receivers hear guard frequencies regardless of modulation.
+func (s *ServerState) isListeningOnFrequency(clientGuid, senderId uuid.UUID, frequency Frequency, globalFreq, guardFreq bool, plan ChannelPlan, coalitions CoalitionMatrix, senderRadio Radio, tuned bool) bool {
+	sender, exists := s.Clients[senderId]
+	if !exists {
+		return false
+	}
+	receiver, exists := s.Clients[clientGuid]
+	if !exists {
+		return false
+	}
+	clientState, exists := s.RadioClients[clientGuid]
+	if !exists {
+		return false
+	}
+	if !globalFreq && !hearsCoalition(receiver, sender, coalitions) {
+		return false
+	}
+	for _, radio := range clientState.Radios {
+		if !radio.Enabled || radio.IsIntercom {
+			continue
+		}
+		if guardFreq && radio.Guard {
+			return true
+		}
+		if plan.Matches(radio.Frequency, frequency) && (!tuned || radio.Modulation == senderRadio.Modulation) {
+			return true
+		}
+	}
 	return false
 }
 
+// HearsCoalition reports if the client hears clients of the coalition, following the coalition relations and the
+// coalition a spectator monitors
+func (s *ServerState) HearsCoalition(clientGuid uuid.UUID, coalition string, coalitions CoalitionMatrix) bool {
+	s.RLock()
+	defer s.RUnlock()
+	client, exists := s.Clients[clientGuid]
+	return exists && hearsCoalition(client, &ClientState{Coalition: coalition}, coalitions)
+}
+
+// hearsCoalition reports if the receiver hears clients of the coalition of the sender
+func hearsCoalition(receiver, sender *ClientState, coalitions CoalitionMatrix) bool {
+	if receiver.Spectator {
+		return receiver.Coalition == "" || receiver.Coalition == sender.Coalition
+	}
+	return coalitions.Hears(receiver.Coalition, sender.Coalition)
+}
+
+// GetRadioOnFrequency returns the radio of the client tuned to the channel of the frequency, preferring an enabled one.
+// Intercoms are ignored, they are not tuned to a frequency.
+func (s *ServerState) GetRadioOnFrequency(clientGuid uuid.UUID, frequency Frequency, plan ChannelPlan) (Radio, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.radioOnFrequency(clientGuid, frequency, plan)
+}
+
+// radioOnFrequency has to be called with the lock held
+func (s *ServerState) radioOnFrequency(clientGuid uuid.UUID, frequency Frequency, plan ChannelPlan) (Radio, bool) {
+	radioState, exists := s.RadioClients[clientGuid]
+	if !exists {
+		return Radio{}, false
+	}
+	var tuned Radio
+	var found bool
+	for _, radio := range radioState.Radios {
+		if radio.IsIntercom || !plan.Matches(radio.Frequency, frequency) {
+			continue
+		}
+		if radio.Enabled {
+			return radio, true
+		}
+		tuned, found = radio, true
+	}
+	return tuned, found
+}
+
+// SetVoiceQuality replaces the voice statistics of the client, the slice must not be modified afterwards
+func (s *ServerState) SetVoiceQuality(clientGuid uuid.UUID, quality []VoiceQuality) {
+	s.Lock()
+	defer s.Unlock()
+	if client, exists := s.Clients[clientGuid]; exists {
+		client.VoiceQuality = quality
+	}
+}
+
+// IsSpectator reports if the client is a listen-only spectator
+func (s *ServerState) IsSpectator(clientGuid uuid.UUID) bool {
+	s.RLock()
+	defer s.RUnlock()
+	client, exists := s.Clients[clientGuid]
+	return exists && client.Spectator
+}
+
+// GetClient returns a copy of the state of the client
+func (s *ServerState) GetClient(clientGuid uuid.UUID) (ClientState, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	client, exists := s.Clients[clientGuid]
+	if !exists {
+		return ClientState{}, false
+	}
+	return *client, true
+}
+
+// GetVoiceSecret returns the secret the client authenticates its voice packets with
+func (s *ServerState) GetVoiceSecret(clientGuid uuid.UUID) ([]byte, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	client, exists := s.Clients[clientGuid]
+	if !exists || len(client.VoiceSecret) == 0 {
+		return nil, false
+	}
+	return client.VoiceSecret, true
+}
+
+// GetVoiceKey returns the voice encryption master key of the client
+func (s *ServerState) GetVoiceKey(clientGuid uuid.UUID) ([]byte, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	client, exists := s.Clients[clientGuid]
+	if !exists || len(client.VoiceKey) == 0 {
+		return nil, false
+	}
+	return client.VoiceKey, true
+}
+
 func (s *ServerState) DoesClientExist(clientGuid uuid.UUID) bool {
 	s.RLock()
 	defer s.RUnlock()
@@ -5,6 +5,7 @@ import (
 	"os"
 	"slices"
 	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -18,7 +19,25 @@ type SettingsState struct {
 	General      GeneralSettings      `yaml:"general"`
 	Security     SecuritySettings     `yaml:"security"`
 	VoiceControl VoiceControlSettings `yaml:"voiceControl"`
-	file         string               `yaml:"-"`
+	// TransmissionLog configures the log of who transmitted on which frequency and when
+	TransmissionLog TransmissionLogSettings `yaml:"transmissionLog"`
+	// Recording configures which frequencies are recorded to Ogg/Opus files
+	Recording RecordingSettings `yaml:"recording"`
+	// FloodProtection limits the packets the voice server accepts from a single address or session
+	FloodProtection FloodProtectionSettings `yaml:"floodProtection"`
+	// TransmissionLimit cuts off transmissions that are too long, like those of a stuck PTT
+	TransmissionLimit TransmissionLimitSettings `yaml:"transmissionLimit"`
+	// FrequencyPolicies restrict ranges of frequencies to some coalitions, units and roles
+	FrequencyPolicies []FrequencyPolicy `yaml:"frequencyPolicies"`
+	// CoalitionRelations decide which coalitions hear each other, pairs of coalitions without a relation are isolated
+	CoalitionRelations CoalitionMatrix `yaml:"coalitionRelations"`
+	// Spectators are listen-only clients, like streamers or instructors
+	Spectators SpectatorSettings `yaml:"spectators"`
+	// Propagation limits the range of radios by the positions of the clients
+	Propagation PropagationSettings `yaml:"propagation"`
+	// Retransmission relays voice from one frequency onto another
+	Retransmission RetransmissionSettings `yaml:"retransmission"`
+	file           string                 `yaml:"-"`
 }
 
 type ServerSettings struct {
@@ -43,20 +62,51 @@ type Coalition struct {
 
 type FrequencySettings struct {
 	// FrequencySettings holds the current settings of the frequency
-	TestFrequencies   []float32 `yaml:"testFrequencies"`
-	GlobalFrequencies []float32 `yaml:"globalFrequencies"`
+	TestFrequencies   []Frequency `yaml:"testFrequencies"`
+	GlobalFrequencies []Frequency `yaml:"globalFrequencies"`
+	// PriorityFrequencies are priority-controlled, a talker with a higher role pre-empts the transmissions of lower roles
+	PriorityFrequencies []Frequency `yaml:"priorityFrequencies"`
+	// GuardFrequencies are heard by every radio with a guard receiver, in parallel with its tuned frequency
+	GuardFrequencies []Frequency `yaml:"guardFrequencies"`
 }
 
 type GeneralSettings struct {
 	// GeneralSettings holds the current settings of the general settings
 	MaxRadiosPerUser int `yaml:"maxRadiosPerUser"`
+	// TransmitPolicy decides what happens to voice on frequencies the sender has no enabled radio on, see TransmitPolicyStrict
+	TransmitPolicy     string    `yaml:"transmitPolicy"`
+	MinFrequency       Frequency `yaml:"minFrequency"`       // Lowest frequency clients may transmit on in MHz, 0 disables the limit
+	MaxFrequency       Frequency `yaml:"maxFrequency"`       // Highest frequency clients may transmit on in MHz, 0 disables the limit
+	ChannelSpacing     uint32    `yaml:"channelSpacing"`     // Frequencies are rounded to channels this many Hz apart, 0 disables rounding
+	FrequencyTolerance uint32    `yaml:"frequencyTolerance"` // Hz two rounded frequencies may differ and still be the same channel
+	// TestFrequencyPlayback plays test frequencies on the speakers of the server instead of echoing them back, GUI only
+	TestFrequencyPlayback bool `yaml:"testFrequencyPlayback"`
+	// SimultaneousPolicy decides what happens when two clients transmit on the same frequency, see SimultaneousPolicyFirst
+	SimultaneousPolicy string `yaml:"simultaneousPolicy"`
+	// RadioEncryption lets radios encrypt with a key slot, receivers tuned with another key do not hear them in clear
+	RadioEncryption bool `yaml:"radioEncryption"`
+	// StrictRadioEncryption drops encrypted voice for receivers with another key instead of sending them noise
+	StrictRadioEncryption bool `yaml:"strictRadioEncryption"`
 }
 
+const (
+	TransmitPolicyStrict  = "strict"  // Unauthorized voice is dropped
+	TransmitPolicyLenient = "lenient" // Unauthorized voice is forwarded, but still counted and logged
+)
+
+const (
+	SimultaneousPolicyFirst = "first" // The first talker wins, later talkers are blocked until they release PTT
+	SimultaneousPolicyMix   = "mix"   // All talkers are forwarded and mixed by the receivers
+	SimultaneousPolicyFlag  = "flag"  // All talkers are forwarded, but flagged as overlapping to receivers and senders
+)
+
 type SecuritySettings struct {
 	Plugins          []PluginSettings `yaml:"plugins"`
 	EnablePluginAuth bool             `yaml:"enablePluginAuth"`
 	EnableGuestAuth  bool             `yaml:"enableGuestAuth"`
 	Token            TokenSettings    `yaml:"token"`
+	// RequireVoiceEncryption rejects clients that do not negotiate a voice key and all unencrypted voice
+	RequireVoiceEncryption bool `yaml:"requireVoiceEncryption"`
 }
 
 type PluginSettings struct {
@@ -74,6 +124,52 @@ type TokenSettings struct {
 	Subject        string `yaml:"subject"`
 }
 
+type TransmissionLogSettings struct {
+	Enabled   bool   `yaml:"enabled"`
+	Directory string `yaml:"directory"` // Directory the daily log files are written to
+	Retention int    `yaml:"retention"` // Days to keep log files, 0 keeps them forever
+}
+
+type RecordingSettings struct {
+	Directory   string      `yaml:"directory"`   // Directory the recordings are written to
+	Frequencies []Frequency `yaml:"frequencies"` // Frequencies that are recorded
+	MaxFileSize int64       `yaml:"maxFileSize"` // Bytes after which a recording is split into a new file, 0 disables the limit
+	MaxDuration int         `yaml:"maxDuration"` // Seconds after which a recording is split into a new file, 0 disables the limit
+}
+
+type FloodProtectionSettings struct {
+	Enabled           bool    `yaml:"enabled"`
+	AddressRate       float64 `yaml:"addressRate"`       // Packets per second accepted from a single IP address, 0 disables the limit
+	AddressBurst      int     `yaml:"addressBurst"`      // Packets an IP address may send at once above its rate
+	SessionRate       float64 `yaml:"sessionRate"`       // Packets per second accepted from a single voice session, 0 disables the limit
+	SessionBurst      int     `yaml:"sessionBurst"`      // Packets a voice session may send at once above its rate
+	MaxVoicePayload   int     `yaml:"maxVoicePayload"`   // Bytes of payload a voice packet may carry, 0 disables the limit
+	MaxControlPayload int     `yaml:"maxControlPayload"` // Bytes of payload a HELLO, KEEPALIVE or BYE may carry, 0 disables the limit
+	BlockThreshold    int     `yaml:"blockThreshold"`    // Dropped or malformed packets within the block window that block an address, 0 never blocks
+	BlockWindow       int     `yaml:"blockWindow"`       // Seconds the dropped and malformed packets of an address are counted over
+	BlockDuration     int     `yaml:"blockDuration"`     // Seconds an address stays blocked
+}
+
+type TransmissionLimitSettings struct {
+	MaxDuration      int                          `yaml:"maxDuration"`      // Seconds a client may transmit continuously, 0 disables the limit
+	Frequencies      []FrequencyTransmissionLimit `yaml:"frequencies"`      // Limits of single frequencies that replace maxDuration
+	AutoMuteCutOffs  int                          `yaml:"autoMuteCutOffs"`  // Cut off transmissions within the auto-mute window that mute the client, 0 never mutes
+	AutoMuteWindow   int                          `yaml:"autoMuteWindow"`   // Seconds the cut off transmissions of a client are counted over
+	AutoMuteDuration int                          `yaml:"autoMuteDuration"` // Seconds a repeat offender is muted for, 0 mutes it until it is unmuted
+}
+
+type FrequencyTransmissionLimit struct {
+	Frequency   Frequency `yaml:"frequency"`
+	MaxDuration int       `yaml:"maxDuration"` // Seconds a client may transmit continuously on the frequency, 0 disables the limit
+}
+
+type SpectatorSettings struct {
+	Enabled    bool   `yaml:"enabled"`    // Allows clients to log in as listen-only spectators
+	Password   string `yaml:"password"`   // Password of the spectator login, empty only allows spectators through a plugin
+	PluginRole uint8  `yaml:"pluginRole"` // Minimum role a plugin login needs to join as spectator
+	Coalition  string `yaml:"coalition"`  // Coalition spectators monitor, empty monitors all coalitions
+}
+
 type VoiceControlSettings struct {
 	Port            int    `yaml:"port"`
 	RemoteHost      string `yaml:"remoteHost"`
@@ -109,11 +205,19 @@ func GetSettingsState(file string) (*SettingsState, error) {
 				},
 				Coalitions: make([]Coalition, 0),
 				Frequencies: FrequencySettings{
-					TestFrequencies:   make([]float32, 0),
-					GlobalFrequencies: make([]float32, 0),
+					TestFrequencies:     make([]Frequency, 0),
+					GlobalFrequencies:   make([]Frequency, 0),
+					PriorityFrequencies: make([]Frequency, 0),
+					GuardFrequencies:    []Frequency{121_500 * KHz, 243 * MHz},
 				},
 				General: GeneralSettings{
-					MaxRadiosPerUser: 20,
+					MaxRadiosPerUser:   20,
+					TransmitPolicy:     TransmitPolicyStrict,
+					MinFrequency:       1 * KHz,
+					MaxFrequency:       999_999 * KHz,
+					FrequencyTolerance: 500,
+					SimultaneousPolicy: SimultaneousPolicyFirst,
+					RadioEncryption:    true,
 				},
 				Security: SecuritySettings{
 					Plugins:          make([]PluginSettings, 0),
@@ -134,6 +238,51 @@ func GetSettingsState(file string) (*SettingsState, error) {
 					CertificateFile: "/path/to/voicecontrol-cert.pem",
 					PrivateKeyFile:  "/path/to/voicecontrol-private-key.pem",
 				},
+				TransmissionLog: TransmissionLogSettings{
+					Enabled:   false,
+					Directory: "transmissions",
+					Retention: 2,
+				},
+				Recording: RecordingSettings{
+					Directory:   "recordings",
+					Frequencies: make([]Frequency, 0),
+					MaxFileSize: 64 * 1024 * 1024, // 64 MiB
+					MaxDuration: 3600,             // 1 hour
+				},
+				FloodProtection: FloodProtectionSettings{
+					Enabled:           true,
+					AddressRate:       200,
+					AddressBurst:      400,
+					SessionRate:       100,
+					SessionBurst:      200,
+					MaxVoicePayload:   512,
+					MaxControlPayload: 128,
+					BlockThreshold:    500,
+					BlockWindow:       10,
+					BlockDuration:     300, // 5 minutes
+				},
+				TransmissionLimit: TransmissionLimitSettings{
+					MaxDuration:      120,
+					Frequencies:      make([]FrequencyTransmissionLimit, 0),
+					AutoMuteCutOffs:  3,
+					AutoMuteWindow:   600,
+					AutoMuteDuration: 300,
+				},
+				FrequencyPolicies:  make([]FrequencyPolicy, 0),
+				CoalitionRelations: make(CoalitionMatrix, 0),
+				Spectators: SpectatorSettings{
+					Enabled:    false,
+					PluginRole: 1, // Members
+				},
+				Propagation: PropagationSettings{
+					Distance:    false,
+					LineOfSight: false,
+					Bands:       slices.Clone(DefaultRadioBands),
+				},
+				Retransmission: RetransmissionSettings{
+					NodeLimit: 2,
+					Relays:    make([]Relay, 0),
+				},
 			}
 			err = settings.Save()
 			if err != nil {
@@ -237,14 +386,163 @@ func (s *SettingsState) DoesCoalitionExist(coalitionName string) bool {
 	return false
 }
 
-func (s *SettingsState) IsFrequencyGlobal(freq float32) bool {
+func (s *SettingsState) IsFrequencyGlobal(freq Frequency) bool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.channelPlan().Contains(s.Frequencies.GlobalFrequencies, freq)
+}
+
+// GetChannelPlan returns when two frequencies count as the same channel
+func (s *SettingsState) GetChannelPlan() ChannelPlan {
+	s.RLock()
+	defer s.RUnlock()
+	return s.channelPlan()
+}
+
+// channelPlan has to be called with the lock held
+func (s *SettingsState) channelPlan() ChannelPlan {
+	return ChannelPlan{Spacing: s.General.ChannelSpacing, Tolerance: s.General.FrequencyTolerance}
+}
+
+func (s *SettingsState) IsVoiceEncryptionRequired() bool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.Security.RequireVoiceEncryption
+}
+
+func (s *SettingsState) GetTransmissionLogSettings() TransmissionLogSettings {
+	s.RLock()
+	defer s.RUnlock()
+	return s.TransmissionLog
+}
+
+func (s *SettingsState) GetRecordingSettings() RecordingSettings {
+	s.RLock()
+	defer s.RUnlock()
+	settings := s.Recording
+	settings.Frequencies = slices.Clone(s.Recording.Frequencies)
+	return settings
+}
+
+func (s *SettingsState) GetFloodProtectionSettings() FloodProtectionSettings {
+	s.RLock()
+	defer s.RUnlock()
+	return s.FloodProtection
+}
+
+func (s *SettingsState) GetSpectatorSettings() SpectatorSettings {
+	s.RLock()
+	defer s.RUnlock()
+	return s.Spectators
+}
+
+func (s *SettingsState) GetTransmissionLimitSettings() TransmissionLimitSettings {
+	s.RLock()
+	defer s.RUnlock()
+	settings := s.TransmissionLimit
+	settings.Frequencies = slices.Clone(s.TransmissionLimit.Frequencies)
+	return settings
+}
+
+// GetMaxTransmissionDuration returns how long a client may transmit continuously on the frequency, 0 if it is unlimited
+func (s *SettingsState) GetMaxTransmissionDuration(freq Frequency) time.Duration {
+	s.RLock()
+	defer s.RUnlock()
+	seconds := s.TransmissionLimit.MaxDuration
+	plan := s.channelPlan()
+	for _, limit := range s.TransmissionLimit.Frequencies {
+		if plan.Matches(limit.Frequency, freq) {
+			seconds = limit.MaxDuration
+			break
+		}
+	}
+	return time.Duration(max(seconds, 0)) * time.Second
+}
+
+func (s *SettingsState) IsFrequencyRecorded(freq Frequency) bool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.channelPlan().Contains(s.Recording.Frequencies, freq)
+}
+
+// SetFrequencyRecorded turns recording of a frequency on or off and saves the settings
+func (s *SettingsState) SetFrequencyRecorded(freq Frequency, recorded bool) error {
+	s.Lock()
+	defer s.Unlock()
+	plan := s.channelPlan()
+	index := slices.IndexFunc(s.Recording.Frequencies, func(recorded Frequency) bool {
+		return plan.Matches(recorded, freq)
+	})
+	switch {
+	case recorded && index < 0:
+		s.Recording.Frequencies = append(s.Recording.Frequencies, freq)
+	case !recorded && index >= 0:
+		s.Recording.Frequencies = slices.Delete(s.Recording.Frequencies, index, index+1)
+	default:
+		return nil
+	}
+	return s.Save()
+}
+
+// GetTransmitPolicy returns the transmit policy, anything but TransmitPolicyLenient is strict
+func (s *SettingsState) GetTransmitPolicy() string {
+	s.RLock()
+	defer s.RUnlock()
+	if s.General.TransmitPolicy == TransmitPolicyLenient {
+		return TransmitPolicyLenient
+	}
+	return TransmitPolicyStrict
+}
+
+// GetSimultaneousPolicy returns the policy for simultaneous transmissions, settings without one mix all talkers
+func (s *SettingsState) GetSimultaneousPolicy() string {
+	s.RLock()
+	defer s.RUnlock()
+	switch s.General.SimultaneousPolicy {
+	case SimultaneousPolicyFirst, SimultaneousPolicyFlag:
+		return s.General.SimultaneousPolicy
+	default:
+		return SimultaneousPolicyMix
+	}
+}
+
+// GetRadioEncryption reports if radios may encrypt, and if voice for receivers with another key is dropped
+func (s *SettingsState) GetRadioEncryption() (enabled, strict bool) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.General.RadioEncryption, s.General.StrictRadioEncryption
+}
+
+// IsFrequencyInRange checks the frequency against the allowed transmit range
+func (s *SettingsState) IsFrequencyInRange(freq Frequency) bool {
+	s.RLock()
+	defer s.RUnlock()
+	if s.General.MinFrequency > 0 && freq < s.General.MinFrequency {
+		return false
+	}
+	return s.General.MaxFrequency <= 0 || freq <= s.General.MaxFrequency
+}
+
+func (s *SettingsState) IsTestFrequencyPlaybackEnabled() bool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.General.TestFrequencyPlayback
+}
+
+func (s *SettingsState) IsFrequencyPriority(freq Frequency) bool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.channelPlan().Contains(s.Frequencies.PriorityFrequencies, freq)
+}
+
+func (s *SettingsState) IsFrequencyGuard(freq Frequency) bool {
 	s.RLock()
 	defer s.RUnlock()
-	return slices.Contains(s.Frequencies.GlobalFrequencies, freq)
+	return s.channelPlan().Contains(s.Frequencies.GuardFrequencies, freq)
 }
 
-func (s *SettingsState) IsFrequencyTest(freq float32) bool {
+func (s *SettingsState) IsFrequencyTest(freq Frequency) bool {
 	s.RLock()
 	defer s.RUnlock()
-	return slices.Contains(s.Frequencies.TestFrequencies, freq)
+	return s.channelPlan().Contains(s.Frequencies.TestFrequencies, freq)
 }
@@ -1 +1,522 @@
 package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestGetListeningClients(t *testing.T) {
+	s := &ServerState{}
+	sender := uuid.New()
+	listener := uuid.New()
+	other := uuid.New()
+
+	s.AddClient(sender, &ClientState{Name: "Sender", Coalition: "blue"})
+	s.AddClient(listener, &ClientState{Name: "Listener", Coalition: "blue"})
+	s.AddClient(other, &ClientState{Name: "Other", Coalition: "blue"})
+	s.SetRadioState(sender, &RadioState{Radios: []Radio{{ID: 1, Frequency: 251 * MHz, Enabled: true}}})
+	s.SetRadioState(listener, &RadioState{Radios: []Radio{{ID: 1, Frequency: 251 * MHz, Enabled: true}}})
+	s.SetRadioState(other, &RadioState{Radios: []Radio{{ID: 1, Frequency: 251 * MHz, Enabled: false}, {ID: 2, Frequency: 243 * MHz, Enabled: true}}})
+
+	if got := s.GetListeningClients(sender, 251*MHz, false, false, ChannelPlan{}, nil); !slices.Equal(got, []uuid.UUID{listener}) {
+		t.Errorf("GetListeningClients() = %v, want %v", got, []uuid.UUID{listener})
+	}
+
+	// Retuning must move the client in the index
+	s.SetRadioState(listener, &RadioState{Radios: []Radio{{ID: 1, Frequency: 243 * MHz, Enabled: true}}})
+	if got := s.GetListeningClients(sender, 251*MHz, false, false, ChannelPlan{}, nil); len(got) != 0 {
+		t.Errorf("GetListeningClients() after retune = %v, want none", got)
+	}
+	if got := s.GetListeningClients(sender, 243*MHz, false, false, ChannelPlan{}, nil); len(got) != 2 {
+		t.Errorf("GetListeningClients() on new frequency returned %d clients, want 2", len(got))
+	}
+
+	s.RemoveClient(other)
+	if got := s.GetListeningClients(sender, 243*MHz, false, false, ChannelPlan{}, nil); !slices.Equal(got, []uuid.UUID{listener}) {
+		t.Errorf("GetListeningClients() after remove = %v, want %v", got, []uuid.UUID{listener})
+	}
+	if _, exists := s.frequencyIndex[uint32(251*MHz)/frequencyIndexBand][other]; exists {
+		t.Errorf("frequency index still contains removed client %s", other)
+	}
+}
+
+func TestGetListeningClientsMatchesIsListeningOnFrequency(t *testing.T) {
+	s := &ServerState{}
+	sender := uuid.New()
+	s.AddClient(sender, &ClientState{Name: "Sender", Coalition: "blue"})
+	frequencies := []Frequency{243 * MHz, 251 * MHz, 30 * MHz}
+	for i := range 30 {
+		id := uuid.New()
+		coalition := "blue"
+		if i%3 == 0 {
+			coalition = "red"
+		}
+		s.AddClient(id, &ClientState{Name: id.String(), Coalition: coalition})
+		s.SetRadioState(id, &RadioState{Radios: []Radio{
+			{ID: 1, Frequency: frequencies[i%len(frequencies)], Enabled: i%4 != 0},
+			{ID: 2, Frequency: frequencies[(i+1)%len(frequencies)], Enabled: true, Guard: i%5 == 0},
+		}})
+	}
+
+	for _, frequency := range frequencies {
+		for _, global := range []bool{false, true} {
+			for _, guard := range []bool{false, true} {
+				var want []uuid.UUID
+				for _, client := range s.GetAllClients() {
+					if client.ID != sender && s.IsListeningOnFrequency(client.ID, sender, frequency, global, guard, ChannelPlan{}, nil) {
+						want = append(want, client.ID)
+					}
+				}
+				got := s.GetListeningClients(sender, frequency, global, guard, ChannelPlan{}, nil)
+				slices.SortFunc(got, uuidCompare)
+				slices.SortFunc(want, uuidCompare)
+				if !slices.Equal(got, want) {
+					t.Errorf("GetListeningClients(%v, %v, %v) = %v, want %v", frequency, global, guard, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestGuardReceiver(t *testing.T) {
+	s := &ServerState{}
+	clients := make(map[string]uuid.UUID)
+	for name, client := range map[string]struct {
+		coalition string
+		radio     Radio
+	}{
+		"sender":   {"blue", Radio{ID: 1, Frequency: 243 * MHz, Enabled: true}},
+		"guard":    {"blue", Radio{ID: 1, Frequency: 251 * MHz, Enabled: true, Guard: true}},
+		"fm guard": {"blue", Radio{ID: 1, Frequency: 30 * MHz, Modulation: ModulationFM, Enabled: true, Guard: true}},
+		"off":      {"blue", Radio{ID: 1, Frequency: 251 * MHz, Enabled: false, Guard: true}},
+		"no guard": {"blue", Radio{ID: 1, Frequency: 251 * MHz, Enabled: true}},
+		"red":      {"red", Radio{ID: 1, Frequency: 251 * MHz, Enabled: true, Guard: true}},
+	} {
+		id := uuid.New()
+		clients[name] = id
+		s.AddClient(id, &ClientState{Name: name, Coalition: client.coalition})
+		s.SetRadioState(id, &RadioState{Radios: []Radio{client.radio}})
+	}
+
+	got := s.GetListeningClients(clients["sender"], 243*MHz, false, true, ChannelPlan{}, nil)
+	want := []uuid.UUID{clients["guard"], clients["fm guard"]}
+	slices.SortFunc(got, uuidCompare)
+	slices.SortFunc(want, uuidCompare)
+	if !slices.Equal(got, want) {
+		t.Errorf("GetListeningClients() on guard = %v, want %v", got, want)
+	}
+	if got := s.GetListeningClients(clients["sender"], 243*MHz, false, false, ChannelPlan{}, nil); len(got) != 0 {
+		t.Errorf("GetListeningClients() on a frequency that is not guard = %v, want none", got)
+	}
+	if got := s.GetListeningClients(clients["sender"], 243*MHz, true, true, ChannelPlan{}, nil); !slices.Contains(got, clients["red"]) {
+		t.Errorf("GetListeningClients() on global guard = %v, want the red guard receiver", got)
+	}
+
+	// Turning the guard receiver off must remove the client from the guard index
+	s.SetRadioState(clients["guard"], &RadioState{Radios: []Radio{{ID: 1, Frequency: 251 * MHz, Enabled: true}}})
+	if got := s.GetListeningClients(clients["sender"], 243*MHz, false, true, ChannelPlan{}, nil); slices.Contains(got, clients["guard"]) {
+		t.Errorf("GetListeningClients() = %v, still contains the client without guard receiver", got)
+	}
+}
+
+func TestCoalitionMatrix(t *testing.T) {
+	matrix := CoalitionMatrix{
+		{Coalition: "blue", Other: "green", Policy: CoalitionPolicyAllied},
+		{Coalition: "spectators", Other: "blue", Policy: CoalitionPolicyOneWay},
+		{Coalition: "gci", Other: "red", Policy: CoalitionPolicyOneWay},
+		{Coalition: "red", Other: "green", Policy: CoalitionPolicyIsolated},
+	}
+
+	tests := []struct {
+		listener, speaker string
+		want              bool
+	}{
+		{"blue", "blue", true},
+		{"blue", "red", false},
+		{"red", "blue", false},
+		{"blue", "green", true},
+		{"green", "blue", true},
+		{"spectators", "blue", true},
+		{"blue", "spectators", false},
+		{"spectators", "red", false},
+		{"gci", "red", true},
+		{"red", "gci", false},
+		{"red", "green", false},
+		{"green", "red", false},
+	}
+	for _, tt := range tests {
+		if got := matrix.Hears(tt.listener, tt.speaker); got != tt.want {
+			t.Errorf("Hears(%q, %q) = %t, want %t", tt.listener, tt.speaker, got, tt.want)
+		}
+	}
+	shared := []struct {
+		coalition, other string
+		want             bool
+	}{
+		{"blue", "blue", true},
+		{"blue", "red", false},
+		{"blue", "green", true},
+		{"red", "gci", true},
+		{"green", "spectators", false},
+	}
+	for _, tt := range shared {
+		if got := matrix.SharesListeners(tt.coalition, tt.other); got != tt.want {
+			t.Errorf("SharesListeners(%q, %q) = %t, want %t", tt.coalition, tt.other, got, tt.want)
+		}
+	}
+}
+
+func TestIsListeningOnFrequencyCoalitions(t *testing.T) {
+	matrix := CoalitionMatrix{
+		{Coalition: "blue", Other: "green", Policy: CoalitionPolicyAllied},
+		{Coalition: "spectators", Other: "blue", Policy: CoalitionPolicyOneWay},
+	}
+	s := &ServerState{}
+	clients := make(map[string]uuid.UUID)
+	for name, coalition := range map[string]string{"blue": "blue", "wingman": "blue", "red": "red", "green": "green", "spectators": "spectators"} {
+		id := uuid.New()
+		clients[name] = id
+		s.AddClient(id, &ClientState{Name: name, Coalition: coalition})
+		s.SetRadioState(id, &RadioState{Radios: []Radio{{ID: 1, Frequency: 251 * MHz, Enabled: true}}})
+	}
+	for name, coalition := range map[string]string{"observer": "", "blue observer": "blue"} {
+		id := uuid.New()
+		clients[name] = id
+		s.AddClient(id, &ClientState{Name: name, Coalition: coalition, Spectator: true})
+		s.SetRadioState(id, &RadioState{Radios: []Radio{{ID: 1, Frequency: 251 * MHz, Enabled: true}}})
+	}
+
+	tests := []struct {
+		name     string
+		receiver string
+		sender   string
+		global   bool
+		want     bool
+	}{
+		{"same coalition", "wingman", "blue", false, true},
+		{"isolated receiver", "red", "blue", false, false},
+		{"isolated sender", "blue", "red", false, false},
+		{"isolated on global frequency", "red", "blue", true, true},
+		{"allied", "green", "blue", false, true},
+		{"allied reverse", "blue", "green", false, true},
+		{"one-way listener", "spectators", "blue", false, true},
+		{"one-way speaker", "blue", "spectators", false, false},
+		{"spectator of all coalitions", "observer", "red", false, true},
+		{"spectator of the coalition", "blue observer", "blue", false, true},
+		{"spectator of another coalition", "blue observer", "red", false, false},
+		{"spectator on global frequency", "blue observer", "red", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.IsListeningOnFrequency(clients[tt.receiver], clients[tt.sender], 251*MHz, tt.global, false, ChannelPlan{}, matrix)
+			if got != tt.want {
+				t.Errorf("IsListeningOnFrequency() = %t, want %t", got, tt.want)
+			}
+			listeners := s.GetListeningClients(clients[tt.sender], 251*MHz, tt.global, false, ChannelPlan{}, matrix)
+			if slices.Contains(listeners, clients[tt.receiver]) != tt.want {
+				t.Errorf("GetListeningClients() = %v, want receiver included %t", listeners, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelPlan(t *testing.T) {
+	if got := FrequencyFromMHz(247.2); got != 247_200_000 {
+		t.Errorf("FrequencyFromMHz(247.2) = %d, want 247200000", got)
+	}
+	if got := FrequencyFromKHz(247200); got != 247_200*KHz {
+		t.Errorf("FrequencyFromKHz(247200) = %d, want %d", got, 247_200*KHz)
+	}
+	if data, err := json.Marshal(247_200 * KHz); err != nil || string(data) != "247.2" {
+		t.Errorf("json.Marshal() = %s, %v, want 247.2", data, err)
+	}
+
+	tests := []struct {
+		plan ChannelPlan
+		a, b Frequency
+		want bool
+	}{
+		{ChannelPlan{}, FrequencyFromMHz(247.2), FrequencyFromKHz(247200), true},
+		{ChannelPlan{}, FrequencyFromMHz(247.1999), FrequencyFromKHz(247200), false},
+		{ChannelPlan{Tolerance: 500}, FrequencyFromMHz(247.1999), FrequencyFromKHz(247200), true},
+		{ChannelPlan{Spacing: 25_000}, 251_010 * KHz, 251 * MHz, true},
+		{ChannelPlan{Spacing: 25_000}, 251_013 * KHz, 251 * MHz, false},
+		{ChannelPlan{Spacing: 25_000, Tolerance: 25_000}, 251_013 * KHz, 251 * MHz, true},
+	}
+	for _, tt := range tests {
+		if got := tt.plan.Matches(tt.a, tt.b); got != tt.want {
+			t.Errorf("%+v.Matches(%v, %v) = %t, want %t", tt.plan, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestGetListeningClientsChannelAndModulation(t *testing.T) {
+	s := &ServerState{}
+	sender, am, fm, detuned := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{sender, am, fm, detuned} {
+		s.AddClient(id, &ClientState{Name: id.String(), Coalition: "blue"})
+	}
+	s.SetRadioState(sender, &RadioState{Radios: []Radio{{ID: 1, Frequency: FrequencyFromMHz(124.999), Modulation: ModulationAM, Enabled: true}}})
+	s.SetRadioState(am, &RadioState{Radios: []Radio{{ID: 1, Frequency: 125 * MHz, Modulation: ModulationAM, Enabled: true}}})
+	s.SetRadioState(fm, &RadioState{Radios: []Radio{{ID: 1, Frequency: 125 * MHz, Modulation: ModulationFM, Enabled: true}}})
+	s.SetRadioState(detuned, &RadioState{Radios: []Radio{{ID: 1, Frequency: 125_020 * KHz, Modulation: ModulationAM, Enabled: true}}})
+
+	// The spacing rounds 124.999 and 125.000 to the same channel across the bands of the index, FM does not hear AM
+	plan := ChannelPlan{Spacing: 25_000}
+	if got := s.GetListeningClients(sender, FrequencyFromMHz(124.999), false, false, plan, nil); !slices.Equal(got, []uuid.UUID{am}) {
+		t.Errorf("GetListeningClients() = %v, want %v", got, []uuid.UUID{am})
+	}
+	if got := s.GetListeningClients(sender, FrequencyFromMHz(124.999), false, false, ChannelPlan{}, nil); len(got) != 0 {
+		t.Errorf("GetListeningClients() without spacing = %v, want none", got)
+	}
+}
+
+func TestIntercom(t *testing.T) {
+	s := &ServerState{}
+	intercom := Radio{ID: 0, Frequency: 251 * MHz, Enabled: true, IsIntercom: true}
+	pilot, wso, other := uuid.New(), uuid.New(), uuid.New()
+	s.AddClient(pilot, &ClientState{Name: "Pilot", UnitId: "hornet-1", Coalition: "blue"})
+	s.AddClient(wso, &ClientState{Name: "WSO", UnitId: "hornet-1", Coalition: "blue"})
+	s.AddClient(other, &ClientState{Name: "Other", UnitId: "hornet-2", Coalition: "blue"})
+	s.SetRadioState(pilot, &RadioState{Radios: []Radio{intercom}})
+	s.SetRadioState(wso, &RadioState{Radios: []Radio{intercom}})
+	s.SetRadioState(other, &RadioState{Radios: []Radio{{ID: 1, Frequency: 251 * MHz, Enabled: true}}})
+
+	if got := s.GetIntercomListeners(pilot); !slices.Equal(got, []uuid.UUID{wso}) {
+		t.Errorf("GetIntercomListeners() = %v, want %v", got, []uuid.UUID{wso})
+	}
+	if got := s.GetListeningClients(other, 251*MHz, false, false, ChannelPlan{}, nil); len(got) != 0 {
+		t.Errorf("GetListeningClients() = %v, intercoms must not be reachable by frequency", got)
+	}
+	sessions := s.GetIntercomSessions()
+	if len(sessions) != 1 || sessions[0].UnitId != "hornet-1" || len(sessions[0].Members) != 2 {
+		t.Errorf("GetIntercomSessions() = %+v, want one session of hornet-1 with 2 members", sessions)
+	}
+
+	s.SetRadioState(wso, &RadioState{Radios: []Radio{{ID: 0, Frequency: 251 * MHz, IsIntercom: true}}})
+	if got := s.GetIntercomListeners(pilot); len(got) != 0 {
+		t.Errorf("GetIntercomListeners() = %v, want none after the intercom was turned off", got)
+	}
+	if sessions := s.GetIntercomSessions(); len(sessions) != 0 {
+		t.Errorf("GetIntercomSessions() = %+v, want none with a single crew member", sessions)
+	}
+}
+
+func uuidCompare(a, b uuid.UUID) int {
+	return slices.Compare(a[:], b[:])
+}
+
+func TestCheckFrequencyAccess(t *testing.T) {
+	settings := &SettingsState{
+		General: GeneralSettings{FrequencyTolerance: 500},
+		FrequencyPolicies: []FrequencyPolicy{
+			{Name: "Command", From: 251 * MHz, To: 252 * MHz, Coalitions: []string{"blue"}, ListenRole: 1, TransmitRole: 2},
+			{Name: "Wing", From: 251_500 * KHz, Units: []string{"wing-1"}},
+		},
+	}
+	officer := ClientState{Coalition: "blue", UnitId: "wing-2", Role: 2}
+	member := ClientState{Coalition: "blue", UnitId: "wing-1", Role: 1}
+	red := ClientState{Coalition: "red", Role: 3}
+
+	tests := []struct {
+		name      string
+		frequency Frequency
+		client    ClientState
+		access    FrequencyAccess
+		allowed   bool
+	}{
+		{"unrestricted frequency", 305 * MHz, red, FrequencyAccessTransmit, true},
+		{"listen role", 251_200 * KHz, member, FrequencyAccessListen, true},
+		{"transmit role", 251_200 * KHz, member, FrequencyAccessTransmit, false},
+		{"other coalition", 251_200 * KHz, red, FrequencyAccessListen, false},
+		{"bound within tolerance", FrequencyFromMHz(250.9999), red, FrequencyAccessListen, false},
+		{"overlapping policies", 251_500 * KHz, member, FrequencyAccessListen, true},
+		{"unit of overlapping policy", 251_500 * KHz, officer, FrequencyAccessListen, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := settings.CheckFrequencyAccess(tt.frequency, tt.client, tt.access)
+			if (err == nil) != tt.allowed {
+				t.Errorf("CheckFrequencyAccess() = %v, want allowed %t", err, tt.allowed)
+			}
+		})
+	}
+
+	if err := settings.SetFrequencyPolicies([]FrequencyPolicy{{From: 252 * MHz, To: 251 * MHz}}); err == nil {
+		t.Errorf("SetFrequencyPolicies() accepted a reversed range")
+	}
+}
+
+func TestSetFrequencyRecorded(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	settings, err := GetSettingsState(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []struct {
+		frequency Frequency
+		recorded  bool
+		want      []Frequency
+	}{
+		{251 * MHz, true, []Frequency{251 * MHz}},
+		{251 * MHz, true, []Frequency{251 * MHz}},
+		{305 * MHz, true, []Frequency{251 * MHz, 305 * MHz}},
+		{251 * MHz, false, []Frequency{305 * MHz}},
+		{120 * MHz, false, []Frequency{305 * MHz}},
+	} {
+		if err := settings.SetFrequencyRecorded(step.frequency, step.recorded); err != nil {
+			t.Fatal(err)
+		}
+		if got := settings.GetRecordingSettings().Frequencies; !slices.Equal(got, step.want) {
+			t.Errorf("after setting %v to %v: expected %v, got %v", step.frequency, step.recorded, step.want, got)
+		}
+	}
+
+	saved, err := GetSettingsState(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !saved.IsFrequencyRecorded(305*MHz) || saved.IsFrequencyRecorded(251*MHz) {
+		t.Errorf("recorded frequencies were not saved: %v", saved.Recording.Frequencies)
+	}
+}
+
+func TestMuteClient(t *testing.T) {
+	s := &ServerState{}
+	id := uuid.New()
+	s.AddClient(id, &ClientState{Name: "Pilot"})
+
+	if _, err := s.MuteClient(uuid.New(), "Admin", "spam", 0); err == nil {
+		t.Errorf("expected muting an unknown client to fail")
+	}
+
+	record, err := s.MuteClient(id, "Admin", "hot mic", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Action != MuteActionMute || record.By != "Admin" || record.Reason != "hot mic" || record.ClientName != "Pilot" {
+		t.Errorf("unexpected mute record: %+v", record)
+	}
+	now := time.Now()
+	if !s.IsMuted(id, now) {
+		t.Errorf("expected the client to be muted")
+	}
+
+	// Updating the radios must not lift the mute
+	s.SetRadioState(id, &RadioState{Radios: []Radio{{ID: 1, Frequency: 251 * MHz, Enabled: true}}, Muted: false})
+	if !s.IsMuted(id, now) || !s.RadioClients[id].Muted {
+		t.Errorf("expected the client to stay muted after updating its radios")
+	}
+
+	if expired := s.ExpireMutes(now); len(expired) != 0 {
+		t.Errorf("expected no mute to expire yet, got %+v", expired)
+	}
+	if !s.IsMuted(id, record.Until.Add(-time.Millisecond)) {
+		t.Errorf("expected the client to be muted until %v", record.Until)
+	}
+	expired := s.ExpireMutes(record.Until)
+	if len(expired) != 1 || expired[0].Action != MuteActionExpired {
+		t.Fatalf("expected the mute to expire, got %+v", expired)
+	}
+	if s.IsMuted(id, record.Until) {
+		t.Errorf("expected the client to be unmuted after the mute expired")
+	}
+
+	// A mute without duration lasts until the client is unmuted
+	if _, err := s.MuteClient(id, "Admin", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.ExpireMutes(now.Add(24*time.Hour))) != 0 || !s.IsMuted(id, now.Add(24*time.Hour)) {
+		t.Errorf("expected a mute without duration not to expire")
+	}
+	if _, err := s.UnmuteClient(id, "Officer"); err != nil {
+		t.Fatal(err)
+	}
+	if s.IsMuted(id, now) {
+		t.Errorf("expected the client to be unmuted")
+	}
+
+	var actions []string
+	for _, record := range s.GetMuteHistory() {
+		actions = append(actions, record.Action)
+	}
+	if want := []string{MuteActionMute, MuteActionExpired, MuteActionMute, MuteActionUnmute}; !slices.Equal(actions, want) {
+		t.Errorf("expected history %v, got %v", want, actions)
+	}
+}
+
+func TestMuteHistoryState(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "mute_history.json")
+	history, err := GetMuteHistoryState(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &ServerState{MuteHistory: *history}
+	id := uuid.New()
+	s.AddClient(id, &ClientState{Name: "Pilot"})
+
+	// Above the limit the oldest records are dropped
+	for i := 0; i <= MuteHistoryLimit; i++ {
+		if _, err := s.MuteClient(id, "Admin", strconv.Itoa(i), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SaveMuteHistory(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := GetMuteHistoryState(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Records) != MuteHistoryLimit {
+		t.Fatalf("expected %d records, got %d", MuteHistoryLimit, len(loaded.Records))
+	}
+	if first, last := loaded.Records[0], loaded.Records[MuteHistoryLimit-1]; first.Reason != "1" || last.Reason != strconv.Itoa(MuteHistoryLimit) {
+		t.Errorf("expected the oldest record to be dropped, got %q to %q", first.Reason, last.Reason)
+	}
+
+	// Without new records there is nothing to save
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveMuteHistory(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("expected an unchanged mute history not to be written, got %v", err)
+	}
+}
+
+func TestGetRetransmitFrequencies(t *testing.T) {
+	s := &ServerState{}
+	node := uuid.New()
+	s.AddClient(node, &ClientState{Name: "node", Coalition: "blue"})
+	s.SetRadioState(node, &RadioState{Radios: []Radio{
+		{ID: 1, Frequency: 251 * MHz, Enabled: true, Retransmit: true},
+		{ID: 2, Frequency: 124 * MHz, Enabled: true, Retransmit: true},
+		{ID: 3, Frequency: 30 * MHz, Enabled: true},
+		{ID: 4, Frequency: 40 * MHz, Enabled: false, Retransmit: true},
+	}})
+
+	if got, want := s.GetRetransmitFrequencies(node, 251*MHz, ChannelPlan{}), []Frequency{124 * MHz}; !slices.Equal(got, want) {
+		t.Errorf("GetRetransmitFrequencies() = %v, want %v", got, want)
+	}
+	if got := s.GetRetransmitFrequencies(node, 30*MHz, ChannelPlan{}); len(got) != 0 {
+		t.Errorf("GetRetransmitFrequencies() from a radio without retransmit = %v, want none", got)
+	}
+	if got := s.GetRetransmitFrequencies(node, 40*MHz, ChannelPlan{}); len(got) != 0 {
+		t.Errorf("GetRetransmitFrequencies() from a disabled radio = %v, want none", got)
+	}
+	if got := s.GetRetransmitFrequencies(uuid.New(), 251*MHz, ChannelPlan{}); len(got) != 0 {
+		t.Errorf("GetRetransmitFrequencies() of an unknown client = %v, want none", got)
+	}
+}
@@ -0,0 +1,300 @@
+package transmissions
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/state"
+)
+
+const (
+	defaultDirectory = "transmissions"
+	filePrefix       = "transmissions-"
+	fileSuffix       = ".jsonl"
+	fileDateLayout   = "2006-01-02"
+	pruneInterval    = time.Hour
+)
+
+// Record is a single finished transmission of a client on a frequency
+type Record struct {
+	ClientGuid string          `json:"clientGuid"`
+	Name       string          `json:"name"`
+	UnitId     string          `json:"unitId"`
+	Coalition  string          `json:"coalition"`
+	Frequency  state.Frequency `json:"frequency"` // Written as MHz
+	Start      time.Time       `json:"start"`
+	DurationMs int64           `json:"durationMs"`
+}
+
+// End returns the time the transmission ended
+func (r Record) End() time.Time {
+	return r.Start.Add(time.Duration(r.DurationMs) * time.Millisecond)
+}
+
+// Conflict is a client keying up on a frequency another client is already transmitting on, or a client whose
+// transmission was pre-empted by a talker with a higher priority
+type Conflict struct {
+	ClientGuid       string          `json:"clientGuid"`       // Client that keyed up while the frequency was in use, or was pre-empted
+	ActiveClientGuid string          `json:"activeClientGuid"` // Client that was already transmitting, or pre-empted the client
+	Frequency        state.Frequency `json:"frequency"`        // Written as MHz
+	Blocked          bool            `json:"blocked"`          // The transmission of the client is dropped, otherwise both are forwarded flagged as overlapping
+	Preempted        bool            `json:"preempted"`        // The transmission of the client was cut off by a talker with a higher priority
+	Time             time.Time       `json:"time"`
+}
+
+// CutOff is a transmission the voice server stopped forwarding because it exceeded the maximum transmission length
+type CutOff struct {
+	ClientGuid    string          `json:"clientGuid"`
+	Name          string          `json:"name"`
+	Frequency     state.Frequency `json:"frequency"` // Written as MHz
+	Start         time.Time       `json:"start"`
+	MaxDurationMs int64           `json:"maxDurationMs"` // Maximum transmission length on the frequency
+	Time          time.Time       `json:"time"`
+}
+
+// Filter narrows down a query of the transmission log, zero values match everything
+type Filter struct {
+	From       time.Time
+	To         time.Time
+	Frequency  state.Frequency // Matches every record on the same channel
+	ClientGuid string
+	Limit      int // Only the latest Limit records are returned
+}
+
+func (f Filter) matches(record Record, plan state.ChannelPlan) bool {
+	if !f.From.IsZero() && record.End().Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && record.Start.After(f.To) {
+		return false
+	}
+	if f.Frequency != 0 && !plan.Matches(record.Frequency, f.Frequency) {
+		return false
+	}
+	if f.ClientGuid != "" && record.ClientGuid != f.ClientGuid {
+		return false
+	}
+	return true
+}
+
+// Log writes finished transmissions to one JSONL file per day and prunes files older than the retention
+type Log struct {
+	mu            sync.Mutex
+	settingsState *state.SettingsState
+	logger        *slog.Logger
+	// queue holds the records added but not written yet, it is unbounded so Add neither blocks nor drops records
+	queueMu sync.Mutex
+	queue   []Record
+	queued  chan struct{}
+}
+
+func NewLog(settingsState *state.SettingsState, logger *slog.Logger) *Log {
+	return &Log{
+		settingsState: settingsState,
+		logger:        logger,
+		queued:        make(chan struct{}, 1),
+	}
+}
+
+// Add queues a finished transmission for Run to write. It never blocks, so it is safe to call on the voice path.
+func (l *Log) Add(record Record) {
+	l.queueMu.Lock()
+	l.queue = append(l.queue, record)
+	l.queueMu.Unlock()
+	select {
+	case l.queued <- struct{}{}:
+	default: // Run is already woken up
+	}
+}
+
+// Run writes every transmission queued with Add and prunes old files until stopChan is closed. Queued records are
+// written before it returns.
+func (l *Log) Run(stopChan <-chan struct{}) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	l.prune(time.Now())
+	for {
+		select {
+		case <-stopChan:
+			l.flush()
+			return
+		case <-l.queued:
+			l.flush()
+		case now := <-ticker.C:
+			l.prune(now)
+		}
+	}
+}
+
+// flush writes all queued records
+func (l *Log) flush() {
+	l.queueMu.Lock()
+	records := l.queue
+	l.queue = nil
+	l.queueMu.Unlock()
+	for _, record := range records {
+		if err := l.Write(record); err != nil {
+			l.logger.Error("Failed to write transmission log", "error", err)
+		}
+	}
+}
+
+func (l *Log) directory() (string, bool) {
+	settings := l.settingsState.GetTransmissionLogSettings()
+	if settings.Directory == "" {
+		return defaultDirectory, settings.Enabled
+	}
+	return settings.Directory, settings.Enabled
+}
+
+// Write appends the record to the file of the day the transmission started, if the log is enabled
+func (l *Log) Write(record Record) error {
+	directory, enabled := l.directory()
+	if !enabled {
+		return nil
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(directory, fileName(record.Start)), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Query returns all recorded transmissions matching the filter, ordered by start
+func (l *Log) Query(filter Filter) ([]Record, error) {
+	directory, _ := l.directory()
+	plan := l.settingsState.GetChannelPlan()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Record{}, nil
+		}
+		return nil, err
+	}
+
+	records := make([]Record, 0)
+	for _, entry := range entries {
+		day, ok := fileDay(entry.Name())
+		if !ok || !dayInRange(day, filter) {
+			continue
+		}
+		fileRecords, err := readFile(filepath.Join(directory, entry.Name()), filter, plan)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, fileRecords...)
+	}
+
+	slices.SortFunc(records, func(a, b Record) int {
+		return a.Start.Compare(b.Start)
+	})
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[len(records)-filter.Limit:]
+	}
+	return records, nil
+}
+
+// prune removes all log files older than the retention
+func (l *Log) prune(now time.Time) {
+	directory, _ := l.directory()
+	retention := l.settingsState.GetTransmissionLogSettings().Retention
+	if retention <= 0 {
+		return
+	}
+	oldest := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -retention)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			l.logger.Error("Failed to read transmission log directory", "directory", directory, "error", err)
+		}
+		return
+	}
+	for _, entry := range entries {
+		day, ok := fileDay(entry.Name())
+		if !ok || !day.Before(oldest) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(directory, entry.Name())); err != nil {
+			l.logger.Error("Failed to remove old transmission log", "file", entry.Name(), "error", err)
+			continue
+		}
+		l.logger.Info("Removed old transmission log", "file", entry.Name())
+	}
+}
+
+func fileName(start time.Time) string {
+	return filePrefix + start.UTC().Format(fileDateLayout) + fileSuffix
+}
+
+func fileDay(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+		return time.Time{}, false
+	}
+	day, err := time.Parse(fileDateLayout, strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return day, true
+}
+
+// dayInRange checks if a daily file can contain transmissions of the filtered time range.
+// Transmissions are filed under the day they started, so one may reach into the next day.
+func dayInRange(day time.Time, filter Filter) bool {
+	if !filter.From.IsZero() && day.AddDate(0, 0, 2).Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && day.After(filter.To) {
+		return false
+	}
+	return true
+}
+
+func readFile(path string, filter Filter, plan state.ChannelPlan) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue // Skip lines broken by a crash while writing
+		}
+		if filter.matches(record, plan) {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
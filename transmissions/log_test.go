@@ -0,0 +1,140 @@
+package transmissions
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/state"
+)
+
+func newTestLog(t *testing.T, retention int) (*Log, string) {
+	directory := t.TempDir()
+	settingsState := &state.SettingsState{
+		TransmissionLog: state.TransmissionLogSettings{
+			Enabled:   true,
+			Directory: directory,
+			Retention: retention,
+		},
+	}
+	return NewLog(settingsState, slog.New(slog.NewTextHandler(io.Discard, nil))), directory
+}
+
+func TestWriteQuery(t *testing.T) {
+	log, _ := newTestLog(t, 0)
+	log.settingsState.General.FrequencyTolerance = uint32(5 * state.KHz)
+	day := time.Date(2025, 6, 1, 23, 59, 0, 0, time.UTC)
+	records := []Record{
+		{ClientGuid: "a", Name: "Alpha", Frequency: 251 * state.MHz, Start: day.Add(-time.Hour), DurationMs: 2000},
+		{ClientGuid: "b", Name: "Bravo", Frequency: 251*state.MHz + 2*state.KHz, Start: day, DurationMs: 120000}, // Reaches into the next day, same channel
+		{ClientGuid: "a", Name: "Alpha", Frequency: 305 * state.MHz, Start: day.Add(2 * time.Hour), DurationMs: 1500},
+	}
+	// Written out of order, queries have to be sorted by start
+	for _, i := range []int{2, 0, 1} {
+		if err := log.Write(records[i]); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"everything", Filter{}, []string{"Alpha", "Bravo", "Alpha"}},
+		{"channel", Filter{Frequency: 251 * state.MHz}, []string{"Alpha", "Bravo"}},
+		{"client", Filter{ClientGuid: "a"}, []string{"Alpha", "Alpha"}},
+		{"limit keeps latest", Filter{Limit: 1}, []string{"Alpha"}},
+		{"overlapping from", Filter{From: day.Add(time.Minute)}, []string{"Bravo", "Alpha"}},
+		{"to", Filter{To: day.Add(-time.Minute)}, []string{"Alpha"}},
+		{"next day only", Filter{From: day.Add(time.Hour + time.Minute)}, []string{"Alpha"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := log.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d records, got %d: %+v", len(tt.want), len(got), got)
+			}
+			for i, record := range got {
+				if record.Name != tt.want[i] {
+					t.Errorf("record %d: expected %s, got %s", i, tt.want[i], record.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestRunBurst(t *testing.T) {
+	log, _ := newTestLog(t, 0)
+	start := time.Now().Add(-time.Minute)
+	stopChan := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		log.Run(stopChan)
+		close(done)
+	}()
+
+	// A burst of records, none of them may be lost
+	count := 2048
+	for i := 0; i < count; i++ {
+		log.Add(Record{ClientGuid: "a", Frequency: 251 * state.MHz, Start: start})
+	}
+	close(stopChan)
+	<-done
+
+	got, err := log.Query(Filter{})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(got) != count {
+		t.Errorf("expected %d records, got %d", count, len(got))
+	}
+}
+
+func TestWriteDisabled(t *testing.T) {
+	log, directory := newTestLog(t, 0)
+	log.settingsState.TransmissionLog.Enabled = false
+
+	if err := log.Write(Record{ClientGuid: "a", Start: time.Now()}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no log files while disabled, got %d", len(entries))
+	}
+}
+
+func TestPrune(t *testing.T) {
+	log, directory := newTestLog(t, 2)
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	for days := range 5 {
+		if err := log.Write(Record{ClientGuid: "a", Start: now.AddDate(0, 0, -days)}); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+	unrelated := filepath.Join(directory, "notes.txt")
+	if err := os.WriteFile(unrelated, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	log.prune(now)
+
+	for days := range 5 {
+		_, err := os.Stat(filepath.Join(directory, fileName(now.AddDate(0, 0, -days))))
+		if kept := err == nil; kept != (days <= 2) {
+			t.Errorf("file of %d days ago: kept = %v", days, kept)
+		}
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Errorf("unrelated file was removed: %v", err)
+	}
+}
@@ -1,9 +1,12 @@
 package utils
 
 import (
+	"crypto/ecdh"
 	"crypto/ecdsa"
 	"crypto/elliptic"
+	"crypto/hkdf"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
@@ -26,14 +29,23 @@ const (
 	AdminRole
 )
 
+const (
+	VoiceSecretSize = 32 // Size of the secret used to authenticate voice packets
+	VoiceKeySize    = 32 // Size of the voice encryption master key
+	voiceKeyInfo    = "vcs voice master key"
+)
+
 var (
 	SrsServiceMinimumRoleMap = map[string]uint8{
-		"UpdateClientInfo":   GuestRole,
-		"UpdateRadioInfo":    GuestRole,
-		"SyncClient":         GuestRole,
-		"Disconnect":         GuestRole,
-		"GetServerSettings":  GuestRole,
-		"SubscribeToUpdates": GuestRole,
+		"UpdateClientInfo":    GuestRole,
+		"UpdateRadioInfo":     GuestRole,
+		"SyncClient":          GuestRole,
+		"Disconnect":          GuestRole,
+		"GetServerSettings":   GuestRole,
+		"SubscribeToUpdates":  GuestRole,
+		"GetTransmissionLog":  AdminRole,
+		"SetPriorityOverride": AdminRole,
+		"UpdatePosition":      GuestRole,
 	}
 	SrsRoleNameMap = map[uint8]string{
 		GuestRole:   "Guest",
@@ -170,6 +182,37 @@ func GenerateToken(clientGuid string, roleId uint8, issuer, subject string, expi
 	return tokenS, nil
 }
 
+// DeriveVoiceKey completes the X25519 key agreement with the public key of a client.
+// It returns the public key the client needs to derive the same master key, and the master key itself.
+func DeriveVoiceKey(clientPublicKey []byte, clientGuid string) ([]byte, []byte, error) {
+	peerKey, err := ecdh.X25519().NewPublicKey(clientPublicKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid voice public key: %v", err)
+	}
+	serverKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	shared, err := serverKey.ECDH(peerKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid voice public key: %v", err)
+	}
+	key, err := hkdf.Key(sha256.New, shared, []byte(clientGuid), voiceKeyInfo, VoiceKeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	return serverKey.PublicKey().Bytes(), key, nil
+}
+
+// GenerateVoiceSecret generates the random secret a client uses to authenticate its voice packets
+func GenerateVoiceSecret() ([]byte, error) {
+	secret := make([]byte, VoiceSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
 func getJWTClaims(tokenString, privateKeyFile, publicKeyFile string) (*TokenClaims, error) {
 	_, publicKey, err := getKeys(privateKeyFile, publicKeyFile)
 	if err != nil {
@@ -4,8 +4,8 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword TODO: Test this function and make sure the client can do this as well
 // HashPassword hashes the given password using bcrypt and returns the hashed password as a string.
+// Clients send passwords hashed like this, see CheckPassword.
 func HashPassword(password string) string {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -14,3 +14,9 @@ func HashPassword(password string) string {
 	}
 	return string(hash)
 }
+
+// CheckPassword reports if the bcrypt hash a client sent was made from the password. bcrypt salts every hash, so hashes
+// can not be compared with each other.
+func CheckPassword(password, hash string) bool {
+	return password != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -1 +1,47 @@
 package utils
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestDeriveVoiceKey(t *testing.T) {
+	clientKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	clientGuid := "2b1c9a53-4f0e-4c56-9a43-7c3c9c6f2f11"
+
+	serverPublicKey, key, err := DeriveVoiceKey(clientKey.PublicKey().Bytes(), clientGuid)
+	if err != nil {
+		t.Fatalf("DeriveVoiceKey() error = %v", err)
+	}
+	if len(key) != VoiceKeySize {
+		t.Errorf("DeriveVoiceKey() key length = %d, want %d", len(key), VoiceKeySize)
+	}
+
+	// The client derives the same key from the public key of the server
+	peerKey, err := ecdh.X25519().NewPublicKey(serverPublicKey)
+	if err != nil {
+		t.Fatalf("NewPublicKey() error = %v", err)
+	}
+	shared, err := clientKey.ECDH(peerKey)
+	if err != nil {
+		t.Fatalf("ECDH() error = %v", err)
+	}
+	clientSide, err := hkdf.Key(sha256.New, shared, []byte(clientGuid), voiceKeyInfo, VoiceKeySize)
+	if err != nil {
+		t.Fatalf("hkdf.Key() error = %v", err)
+	}
+	if !bytes.Equal(clientSide, key) {
+		t.Errorf("client derived key %x, want %x", clientSide, key)
+	}
+
+	if _, _, err := DeriveVoiceKey([]byte{0x01, 0x02}, clientGuid); err == nil {
+		t.Errorf("DeriveVoiceKey() with invalid public key did not return an error")
+	}
+}
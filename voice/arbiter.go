@@ -0,0 +1,251 @@
+package voice
+
+import (
+	"sync"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/FPGSchiba/vcs-srs-server/transmissions"
+	"github.com/google/uuid"
+)
+
+// talker is a client holding PTT on a frequency
+type talker struct {
+	frequency state.Frequency // Channel of the transmission, see state.ChannelPlan.Channel
+	keyed     time.Time
+	lastSeen  time.Time
+	rank      int  // Transmit rank of the client when it keyed up, see state.ServerState.GetTransmitRank
+	blocked   bool // Keyed up while the frequency was in use or got pre-empted, stays blocked until it releases
+	coalition string
+}
+
+// audience decides which talkers of a channel compete with each other. Talkers only compete if a coalition hears both of
+// them, or on global frequencies every coalition hears. Spectators only listen and never hold up a talker.
+type audience struct {
+	coalitions state.CoalitionMatrix
+	global     bool
+}
+
+func (a audience) competes(coalition, other string) bool {
+	return a.global || a.coalitions.SharesListeners(coalition, other)
+}
+
+// transmissionArbiter tracks the active talkers of every frequency from the PTT flag, to decide who is heard when
+// multiple clients transmit at the same time
+type transmissionArbiter struct {
+	mu          sync.Mutex
+	talkers     map[uuid.UUID]*talker
+	frequencies map[state.Frequency]map[uuid.UUID]*talker // Talkers by channel
+}
+
+// arbitration is the decision for a single voice packet
+type arbitration struct {
+	forward     bool
+	overlapping bool                     // Another client transmits on the frequency as well
+	conflicts   []transmissions.Conflict // Set if the packet keyed up on a frequency that was in use or pre-empted talkers
+}
+
+func newTransmissionArbiter() *transmissionArbiter {
+	return &transmissionArbiter{
+		talkers:     make(map[uuid.UUID]*talker),
+		frequencies: make(map[state.Frequency]map[uuid.UUID]*talker),
+	}
+}
+
+// arbitrate accounts the packet of the sender on the channel and decides if it is forwarded. Packets on frequencies of
+// the same channel compete with each other if the audience has listeners of both. The first talker of a frequency owns it;
+// later talkers are blocked, forwarded or flagged as overlapping depending on the policy. On priority frequencies,
+// and for senders with a priority override on any frequency, a talker with a higher rank pre-empts the active talkers
+// with a lower rank and blocks lower ranks keying up while it transmits.
+func (a *transmissionArbiter) arbitrate(packet *VCSPacket, channel state.Frequency, coalition string, listeners audience, policy string, rank int, priorityFrequency bool, now time.Time) arbitration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	current, exists := a.talkers[packet.SenderID]
+	if exists && (current.frequency != channel || now.Sub(current.lastSeen) > TransmissionTimeout) {
+		a.release(packet.SenderID, current) // Switched frequency or lost the release of the last transmission
+		exists = false
+	}
+
+	if !packet.IsPTTActive() {
+		if !exists {
+			return arbitration{forward: true, overlapping: a.othersActive(channel, packet.SenderID, coalition, listeners, now)}
+		}
+		a.release(packet.SenderID, current)
+		return arbitration{forward: !current.blocked, overlapping: a.othersActive(channel, packet.SenderID, coalition, listeners, now)}
+	}
+
+	var conflicts []transmissions.Conflict
+	if !exists {
+		current = &talker{frequency: channel, keyed: now, rank: rank, coalition: coalition}
+		conflicts = a.preempt(packet, channel, current, listeners, priorityFrequency, now)
+		if owner, busy := a.activeTalker(channel, coalition, listeners, now); !current.blocked && busy && policy != state.SimultaneousPolicyMix {
+			current.blocked = policy == state.SimultaneousPolicyFirst
+			conflicts = append(conflicts, transmissions.Conflict{
+				ClientGuid:       packet.SenderID.String(),
+				ActiveClientGuid: owner.String(),
+				Frequency:        packet.RadioFrequency(),
+				Blocked:          current.blocked,
+				Time:             now,
+			})
+		}
+		a.talkers[packet.SenderID] = current
+		talkers, exists := a.frequencies[channel]
+		if !exists {
+			talkers = make(map[uuid.UUID]*talker)
+			a.frequencies[channel] = talkers
+		}
+		talkers[packet.SenderID] = current
+	}
+	current.lastSeen = now
+
+	return arbitration{
+		forward:     !current.blocked,
+		overlapping: a.othersActive(channel, packet.SenderID, coalition, listeners, now),
+		conflicts:   conflicts,
+	}
+}
+
+// preempt compares the rank of a talker keying up with the active talkers of the frequency it competes with. Active
+// talkers it outranks are blocked, and the talker itself is blocked if an active talker outranks it. Has to be called
+// with mu held.
+func (a *transmissionArbiter) preempt(packet *VCSPacket, channel state.Frequency, current *talker, listeners audience, priorityFrequency bool, now time.Time) []transmissions.Conflict {
+	outranks := func(rank, other int) bool {
+		return rank > other && (priorityFrequency || rank == state.PriorityOverrideRank)
+	}
+
+	var conflicts []transmissions.Conflict
+	for clientID, other := range a.frequencies[channel] {
+		if other.blocked || now.Sub(other.lastSeen) > TransmissionTimeout || !listeners.competes(current.coalition, other.coalition) {
+			continue
+		}
+		switch {
+		case outranks(current.rank, other.rank):
+			other.blocked = true
+			conflicts = append(conflicts, transmissions.Conflict{
+				ClientGuid:       clientID.String(),
+				ActiveClientGuid: packet.SenderID.String(),
+				Frequency:        packet.RadioFrequency(),
+				Blocked:          true,
+				Preempted:        true,
+				Time:             now,
+			})
+		case outranks(other.rank, current.rank) && !current.blocked:
+			current.blocked = true
+			conflicts = append(conflicts, transmissions.Conflict{
+				ClientGuid:       packet.SenderID.String(),
+				ActiveClientGuid: clientID.String(),
+				Frequency:        packet.RadioFrequency(),
+				Blocked:          true,
+				Time:             now,
+			})
+		}
+	}
+	return conflicts
+}
+
+// remove releases the talker of a client that left
+func (a *transmissionArbiter) remove(clientID uuid.UUID) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if current, exists := a.talkers[clientID]; exists {
+		a.release(clientID, current)
+	}
+}
+
+// block stops forwarding the transmission of a talker until it releases, so it no longer holds up other talkers
+func (a *transmissionArbiter) block(clientID uuid.UUID) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if current, exists := a.talkers[clientID]; exists {
+		current.blocked = true
+	}
+}
+
+// expire releases all talkers whose release was lost
+func (a *transmissionArbiter) expire(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for clientID, current := range a.talkers {
+		if now.Sub(current.lastSeen) > TransmissionTimeout {
+			a.release(clientID, current)
+		}
+	}
+}
+
+// activeTalker returns the longest talking client on the frequency that is not blocked and competes with a talker of
+// the coalition, has to be called with mu held
+func (a *transmissionArbiter) activeTalker(frequency state.Frequency, coalition string, listeners audience, now time.Time) (uuid.UUID, bool) {
+	var owner uuid.UUID
+	var ownerKeyed time.Time
+	for clientID, current := range a.frequencies[frequency] {
+		if current.blocked || now.Sub(current.lastSeen) > TransmissionTimeout || !listeners.competes(coalition, current.coalition) {
+			continue
+		}
+		if ownerKeyed.IsZero() || current.keyed.Before(ownerKeyed) {
+			owner, ownerKeyed = clientID, current.keyed
+		}
+	}
+	return owner, !ownerKeyed.IsZero()
+}
+
+// othersActive reports if any client but the sender is heard on the frequency by listeners of the sender, has to be
+// called with mu held
+func (a *transmissionArbiter) othersActive(frequency state.Frequency, senderID uuid.UUID, coalition string, listeners audience, now time.Time) bool {
+	for clientID, current := range a.frequencies[frequency] {
+		if clientID != senderID && !current.blocked && now.Sub(current.lastSeen) <= TransmissionTimeout && listeners.competes(coalition, current.coalition) {
+			return true
+		}
+	}
+	return false
+}
+
+// release has to be called with mu held
+func (a *transmissionArbiter) release(clientID uuid.UUID, current *talker) {
+	delete(a.talkers, clientID)
+	talkers := a.frequencies[current.frequency]
+	delete(talkers, clientID)
+	if len(talkers) == 0 {
+		delete(a.frequencies, current.frequency)
+	}
+}
+
+// arbitrate decides if a voice packet is forwarded when other clients transmit on its frequency at the same time.
+// Under SimultaneousPolicyFlag forwarded packets are flagged as overlapping, and conflicts and pre-emptions are
+// published on the event bus.
+func (v *Server) arbitrate(packet *VCSPacket, now time.Time) bool {
+	if v.settingsState.IsFrequencyTest(packet.RadioFrequency()) {
+		return true // Test frequencies are only echoed back to the sender, nobody else is on them
+	}
+	policy := v.settingsState.GetSimultaneousPolicy()
+	rank := v.serverState.GetTransmitRank(packet.SenderID)
+	channel := v.settingsState.GetChannelPlan().Channel(packet.RadioFrequency())
+	var coalition string
+	if client, exists := v.serverState.GetClient(packet.SenderID); exists {
+		coalition = client.Coalition
+	}
+	listeners := audience{
+		coalitions: v.settingsState.GetCoalitionMatrix(),
+		global:     v.settingsState.IsFrequencyGlobal(packet.RadioFrequency()),
+	}
+	decision := v.arbiter.arbitrate(packet, channel, coalition, listeners, policy, rank, v.settingsState.IsFrequencyPriority(packet.RadioFrequency()), now)
+	for _, conflict := range decision.conflicts {
+		v.logger.Debug("Simultaneous transmission",
+			"client_id", conflict.ClientGuid,
+			"active_id", conflict.ActiveClientGuid,
+			"frequency", conflict.Frequency,
+			"blocked", conflict.Blocked,
+			"preempted", conflict.Preempted)
+		v.eventBus.Publish(events.Event{
+			Name: events.TransmissionConflict,
+			Data: conflict,
+		})
+	}
+	if policy == state.SimultaneousPolicyFlag {
+		packet.SetOverlapping(decision.overlapping)
+	} else {
+		packet.SetOverlapping(false)
+	}
+	return decision.forward
+}
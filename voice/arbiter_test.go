@@ -0,0 +1,266 @@
+package voice
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/FPGSchiba/vcs-srs-server/transmissions"
+	"github.com/FPGSchiba/vcs-srs-server/utils"
+	"github.com/google/uuid"
+)
+
+func TestArbitrate(t *testing.T) {
+	first, second := uuid.New(), uuid.New()
+	// Both key up, the first releases while the second still talks, then the second releases
+	steps := []struct {
+		sender uuid.UUID
+		ptt    bool
+	}{{first, true}, {second, true}, {first, true}, {second, true}, {first, false}, {second, true}, {second, false}}
+
+	tests := []struct {
+		policy      string
+		forward     []bool
+		overlapping []bool
+		blocked     bool
+	}{
+		{state.SimultaneousPolicyFirst, []bool{true, false, true, false, true, false, false}, []bool{false, false, false, false, false, false, false}, true},
+		{state.SimultaneousPolicyMix, []bool{true, true, true, true, true, true, true}, nil, false},
+		{state.SimultaneousPolicyFlag, []bool{true, true, true, true, true, true, true}, []bool{false, true, true, true, true, false, false}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			eventBus := events.NewEventBus()
+			conflicts := eventBus.Subscribe(events.TransmissionConflict)
+			settingsState := &state.SettingsState{General: state.GeneralSettings{SimultaneousPolicy: tt.policy}}
+			server := NewServer(&state.ServerState{}, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, settingsState, eventBus)
+			now := time.Now()
+			for i, step := range steps {
+				packet := NewVCSVoicePacket(step.sender, uint32(i), 251000, []byte{31 << 3})
+				packet.SetPTT(step.ptt)
+				if got := server.arbitrate(packet, now.Add(time.Duration(i)*20*time.Millisecond)); got != tt.forward[i] {
+					t.Errorf("step %d: forward = %t, want %t", i, got, tt.forward[i])
+				}
+				if tt.overlapping != nil && packet.IsOverlapping() != tt.overlapping[i] {
+					t.Errorf("step %d: overlapping = %t, want %t", i, packet.IsOverlapping(), tt.overlapping[i])
+				}
+			}
+
+			select {
+			case event := <-conflicts:
+				if tt.policy == state.SimultaneousPolicyMix {
+					t.Fatalf("mixing published a conflict")
+				}
+				conflict := event.Data.(transmissions.Conflict)
+				if conflict.ClientGuid != second.String() || conflict.ActiveClientGuid != first.String() || conflict.Blocked != tt.blocked || conflict.Frequency != 251*state.MHz {
+					t.Errorf("unexpected conflict %+v", conflict)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.policy != state.SimultaneousPolicyMix {
+					t.Errorf("no conflict was published")
+				}
+			}
+			if len(server.arbiter.talkers) != 0 || len(server.arbiter.frequencies) != 0 {
+				t.Errorf("talkers were not released")
+			}
+		})
+	}
+}
+
+func TestArbitrateTimeout(t *testing.T) {
+	settingsState := &state.SettingsState{General: state.GeneralSettings{SimultaneousPolicy: state.SimultaneousPolicyFirst}}
+	server := NewServer(&state.ServerState{}, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, settingsState, events.NewEventBus())
+	first, second := uuid.New(), uuid.New()
+	now := time.Now()
+
+	packet := NewVCSVoicePacket(first, 1, 251000, []byte{31 << 3})
+	packet.SetPTT(true)
+	server.arbitrate(packet, now)
+
+	// The release of the first talker was lost, so the frequency is free again after the timeout
+	packet = NewVCSVoicePacket(second, 1, 251000, []byte{31 << 3})
+	packet.SetPTT(true)
+	if !server.arbitrate(packet, now.Add(TransmissionTimeout+time.Millisecond)) {
+		t.Errorf("frequency was still blocked by a timed out talker")
+	}
+	server.arbiter.expire(now.Add(TransmissionTimeout + time.Millisecond))
+	if _, exists := server.arbiter.talkers[first]; exists {
+		t.Errorf("timed out talker was not released")
+	}
+
+	// Switching frequency releases the talker on the previous one
+	packet = NewVCSVoicePacket(second, 2, 243000, []byte{31 << 3})
+	packet.SetPTT(true)
+	server.arbitrate(packet, now.Add(TransmissionTimeout+2*time.Millisecond))
+	if _, exists := server.arbiter.frequencies[251*state.MHz]; exists {
+		t.Errorf("talker was not released from its previous frequency")
+	}
+}
+
+func TestArbitrateChannel(t *testing.T) {
+	settingsState := &state.SettingsState{General: state.GeneralSettings{
+		SimultaneousPolicy: state.SimultaneousPolicyFirst,
+		ChannelSpacing:     uint32(25 * state.KHz),
+	}}
+	server := NewServer(&state.ServerState{}, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, settingsState, events.NewEventBus())
+	now := time.Now()
+
+	// 251.005 MHz is on the 251.000 MHz channel, so the second talker competes with the first one
+	first := NewVCSVoicePacket(uuid.New(), 1, 251000, []byte{31 << 3})
+	first.SetPTT(true)
+	second := NewVCSVoicePacket(uuid.New(), 1, 251005, []byte{31 << 3})
+	second.SetPTT(true)
+	if !server.arbitrate(first, now) {
+		t.Fatalf("first talker was not forwarded")
+	}
+	if server.arbitrate(second, now.Add(20*time.Millisecond)) {
+		t.Errorf("second talker on the same channel was forwarded, want it blocked")
+	}
+	// A cut off talker no longer holds the channel
+	server.arbiter.block(first.SenderID)
+	third := NewVCSVoicePacket(uuid.New(), 1, 251000, []byte{31 << 3})
+	third.SetPTT(true)
+	if !server.arbitrate(third, now.Add(40*time.Millisecond)) {
+		t.Errorf("talker was blocked by a cut off talker")
+	}
+}
+
+func TestArbitrateCoalitions(t *testing.T) {
+	eventBus := events.NewEventBus()
+	conflicts := eventBus.Subscribe(events.TransmissionConflict)
+	serverState := &state.ServerState{}
+	red, blue, wingman := uuid.New(), uuid.New(), uuid.New()
+	serverState.AddClient(red, &state.ClientState{Coalition: "red"})
+	serverState.AddClient(blue, &state.ClientState{Coalition: "blue"})
+	serverState.AddClient(wingman, &state.ClientState{Coalition: "blue"})
+	settingsState := &state.SettingsState{
+		General:     state.GeneralSettings{SimultaneousPolicy: state.SimultaneousPolicyFirst},
+		Frequencies: state.FrequencySettings{GlobalFrequencies: []state.Frequency{243 * state.MHz}},
+	}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, settingsState, eventBus)
+	now := time.Now()
+
+	transmit := func(sender uuid.UUID, frequency uint32, step int) bool {
+		packet := NewVCSVoicePacket(sender, uint32(step), frequency, []byte{31 << 3})
+		packet.SetPTT(true)
+		return server.arbitrate(packet, now.Add(time.Duration(step)*20*time.Millisecond))
+	}
+
+	// Isolated coalitions do not block each other on a shared frequency, and nobody learns about the other side
+	if !transmit(red, 251000, 0) || !transmit(blue, 251000, 1) {
+		t.Fatalf("isolated coalitions blocked each other")
+	}
+	select {
+	case event := <-conflicts:
+		t.Errorf("isolated coalitions published a conflict %+v", event.Data)
+	case <-time.After(50 * time.Millisecond):
+	}
+	// The own coalition still competes
+	if transmit(wingman, 251000, 2) {
+		t.Errorf("wingman was forwarded while blue was talking")
+	}
+	conflict := (<-conflicts).Data.(transmissions.Conflict)
+	if conflict.ClientGuid != wingman.String() || conflict.ActiveClientGuid != blue.String() {
+		t.Errorf("unexpected conflict %+v", conflict)
+	}
+
+	// Every coalition hears global frequencies, so the coalitions compete on them
+	if !transmit(red, 243000, 3) || transmit(blue, 243000, 4) {
+		t.Errorf("isolated coalitions did not compete on a global frequency")
+	}
+}
+
+func TestArbitratePriority(t *testing.T) {
+	eventBus := events.NewEventBus()
+	conflicts := eventBus.Subscribe(events.TransmissionConflict)
+	serverState := &state.ServerState{}
+	member, officer, guest := uuid.New(), uuid.New(), uuid.New()
+	serverState.AddClient(member, &state.ClientState{Role: utils.MemberRole})
+	serverState.AddClient(officer, &state.ClientState{Role: utils.OfficerRole})
+	serverState.AddClient(guest, &state.ClientState{Role: utils.GuestRole})
+	settingsState := &state.SettingsState{
+		General:     state.GeneralSettings{SimultaneousPolicy: state.SimultaneousPolicyMix},
+		Frequencies: state.FrequencySettings{PriorityFrequencies: []state.Frequency{251 * state.MHz}},
+	}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, settingsState, eventBus)
+	now := time.Now()
+
+	transmit := func(sender uuid.UUID, frequency uint32, ptt bool, step int) bool {
+		packet := NewVCSVoicePacket(sender, uint32(step), frequency, []byte{31 << 3})
+		packet.SetPTT(ptt)
+		return server.arbitrate(packet, now.Add(time.Duration(step)*20*time.Millisecond))
+	}
+	expectConflict := func(clientID, activeID uuid.UUID, preempted bool) {
+		t.Helper()
+		select {
+		case event := <-conflicts:
+			conflict := event.Data.(transmissions.Conflict)
+			if conflict.ClientGuid != clientID.String() || conflict.ActiveClientGuid != activeID.String() || !conflict.Blocked || conflict.Preempted != preempted {
+				t.Errorf("unexpected conflict %+v", conflict)
+			}
+		case <-time.After(100 * time.Millisecond):
+			t.Errorf("no conflict was published")
+		}
+	}
+
+	// The officer pre-empts the member on the priority frequency, and the member cannot key up again while it talks
+	if !transmit(member, 251000, true, 0) || !transmit(officer, 251000, true, 1) {
+		t.Fatalf("transmissions were not forwarded")
+	}
+	expectConflict(member, officer, true)
+	if transmit(member, 251000, true, 2) || transmit(member, 251000, false, 3) {
+		t.Errorf("pre-empted transmission was forwarded")
+	}
+	if transmit(member, 251000, true, 4) {
+		t.Errorf("lower role keyed up over the officer")
+	}
+	expectConflict(member, officer, false)
+	transmit(member, 251000, false, 5)
+	transmit(officer, 251000, false, 6)
+
+	// Roles do not matter on other frequencies, both are mixed
+	if !transmit(officer, 243000, true, 7) || !transmit(member, 243000, true, 8) {
+		t.Errorf("transmissions on a frequency without priority control were not mixed")
+	}
+
+	// A priority override pre-empts every role on any frequency
+	if err := serverState.SetPriorityOverride(guest, true); err != nil {
+		t.Fatalf("SetPriorityOverride() error = %v", err)
+	}
+	if !transmit(guest, 243000, true, 9) {
+		t.Errorf("priority override was not forwarded")
+	}
+	preempted := map[string]bool{}
+	for range 2 {
+		select {
+		case event := <-conflicts:
+			conflict := event.Data.(transmissions.Conflict)
+			if conflict.ActiveClientGuid != guest.String() || !conflict.Preempted {
+				t.Errorf("unexpected conflict %+v", conflict)
+			}
+			preempted[conflict.ClientGuid] = true
+		case <-time.After(100 * time.Millisecond):
+			t.Fatalf("no conflict was published")
+		}
+	}
+	if !preempted[officer.String()] || !preempted[member.String()] {
+		t.Errorf("pre-empted = %v, want the officer and the member", preempted)
+	}
+	if transmit(officer, 243000, true, 10) || transmit(member, 243000, true, 11) {
+		t.Errorf("pre-empted transmissions were forwarded")
+	}
+	// A higher role of an isolated coalition neither pre-empts nor blocks a talker it is not heard by
+	enemy := uuid.New()
+	serverState.AddClient(enemy, &state.ClientState{Role: utils.OfficerRole, Coalition: "red"})
+	if !transmit(member, 251000, true, 12) || !transmit(enemy, 251000, true, 13) || !transmit(member, 251000, true, 14) {
+		t.Errorf("isolated coalitions pre-empted each other")
+	}
+	select {
+	case event := <-conflicts:
+		t.Errorf("isolated coalitions published a conflict %+v", event.Data)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,102 @@
+package voice
+
+import (
+	"sync/atomic"
+
+	"github.com/FPGSchiba/vcs-srs-server/state"
+)
+
+// TransmitRejection is the reason a voice packet was not authorized
+type TransmitRejection int
+
+const (
+	TransmitAuthorized    TransmitRejection = iota
+	TransmitOutOfRange                      // The frequency is outside the allowed range
+	TransmitNoRadio                         // The sender has no radio tuned to the frequency, or no intercom
+	TransmitRadioDisabled                   // The radio of the sender on the frequency, or its intercom, is turned off
+	TransmitForbidden                       // A frequency policy does not allow the sender to transmit on the frequency
+	TransmitSpectator                       // The sender is a listen-only spectator
+	transmitRejectionCount
+)
+
+func (r TransmitRejection) String() string {
+	switch r {
+	case TransmitAuthorized:
+		return "authorized"
+	case TransmitOutOfRange:
+		return "out of range"
+	case TransmitNoRadio:
+		return "no radio"
+	case TransmitRadioDisabled:
+		return "radio disabled"
+	case TransmitForbidden:
+		return "forbidden"
+	case TransmitSpectator:
+		return "spectator"
+	default:
+		return "unknown"
+	}
+}
+
+// transmitRejections counts rejected voice packets by reason
+type transmitRejections [transmitRejectionCount]atomic.Uint64
+
+// authorizeTransmit checks that the sender has an enabled radio tuned to the frequency of the packet, that the
+// frequency is inside the allowed range and that the frequency policies allow the sender to transmit on it.
+// Intercom packets only need an enabled intercom. Spectators may never transmit.
+func (v *Server) authorizeTransmit(packet *VCSPacket) TransmitRejection {
+	if v.serverState.IsSpectator(packet.SenderID) {
+		return TransmitSpectator
+	}
+	if packet.IsIntercom() {
+		return authorizeRadio(v.serverState.GetIntercomRadio(packet.SenderID))
+	}
+	frequency := packet.RadioFrequency()
+	if !v.settingsState.IsFrequencyInRange(frequency) {
+		return TransmitOutOfRange
+	}
+	if v.settingsState.IsFrequencyRestricted(frequency) {
+		client, exists := v.serverState.GetClient(packet.SenderID)
+		if !exists || v.settingsState.CheckFrequencyAccess(frequency, client, state.FrequencyAccessTransmit) != nil {
+			return TransmitForbidden
+		}
+	}
+	return authorizeRadio(v.serverState.GetRadioOnFrequency(packet.SenderID, frequency, v.settingsState.GetChannelPlan()))
+}
+
+func authorizeRadio(radio state.Radio, exists bool) TransmitRejection {
+	if !exists {
+		return TransmitNoRadio
+	}
+	if !radio.Enabled {
+		return TransmitRadioDisabled
+	}
+	return TransmitAuthorized
+}
+
+// checkTransmit counts unauthorized voice packets and reports if the packet may be forwarded under the transmit policy.
+// Packets of spectators and packets forbidden by a frequency policy are dropped under either transmit policy.
+func (v *Server) checkTransmit(packet *VCSPacket) bool {
+	rejection := v.authorizeTransmit(packet)
+	if rejection == TransmitAuthorized {
+		return true
+	}
+	v.rejections[rejection].Add(1)
+	enforced := rejection == TransmitForbidden || rejection == TransmitSpectator
+	lenient := !enforced && v.settingsState.GetTransmitPolicy() == state.TransmitPolicyLenient
+	v.logger.Debug("Unauthorized voice packet",
+		"sender_id", packet.SenderID,
+		"frequency", packet.FrequencyAsFloat32(),
+		"reason", rejection.String(),
+		"forwarded", lenient)
+	return lenient
+}
+
+// GetTransmitRejections returns the number of unauthorized voice packets by reason since the server was created
+func (v *Server) GetTransmitRejections() map[TransmitRejection]uint64 {
+	rejections := make(map[TransmitRejection]uint64, transmitRejectionCount-1)
+	for reason := TransmitOutOfRange; reason < transmitRejectionCount; reason++ {
+		rejections[reason] = v.rejections[reason].Load()
+	}
+	return rejections
+}
@@ -0,0 +1,151 @@
+package voice
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/google/uuid"
+)
+
+func TestAuthorizeTransmit(t *testing.T) {
+	serverState := &state.ServerState{}
+	settingsState := &state.SettingsState{General: state.GeneralSettings{MinFrequency: 100 * state.MHz, MaxFrequency: 400 * state.MHz}}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, settingsState, events.NewEventBus())
+
+	sender := uuid.New()
+	serverState.AddClient(sender, &state.ClientState{Name: "Pilot", Coalition: "blue"})
+	serverState.SetRadioState(sender, &state.RadioState{Radios: []state.Radio{
+		{ID: 1, Frequency: 251 * state.MHz, Enabled: true},
+		{ID: 2, Frequency: 305 * state.MHz, Enabled: false},
+		{ID: 3, Frequency: 450 * state.MHz, Enabled: true},
+		{ID: 4, Frequency: 305 * state.MHz, Enabled: true}, // A second radio on the same frequency that is turned on
+		{ID: 5, Frequency: 120 * state.MHz, Enabled: false},
+	}})
+
+	tests := []struct {
+		name      string
+		sender    uuid.UUID
+		frequency uint32
+		want      TransmitRejection
+	}{
+		{"enabled radio", sender, 251000, TransmitAuthorized},
+		{"one of two radios enabled", sender, 305000, TransmitAuthorized},
+		{"radio disabled", sender, 120000, TransmitRadioDisabled},
+		{"no radio", sender, 260000, TransmitNoRadio},
+		{"above range", sender, 450000, TransmitOutOfRange},
+		{"below range", sender, 50000, TransmitOutOfRange},
+		{"unknown sender", uuid.New(), 251000, TransmitNoRadio},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packet := NewVCSVoicePacket(tt.sender, 1, tt.frequency, []byte{0xF8})
+			if got := server.authorizeTransmit(packet); got != tt.want {
+				t.Errorf("authorizeTransmit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransmitPolicy(t *testing.T) {
+	serverState := &state.ServerState{}
+	settingsState := &state.SettingsState{}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, settingsState, events.NewEventBus())
+	sender := uuid.New()
+	serverState.AddClient(sender, &state.ClientState{Name: "Pilot", Coalition: "blue"})
+	packet := NewVCSVoicePacket(sender, 1, 251000, []byte{0xF8})
+
+	for _, policy := range []string{"", state.TransmitPolicyStrict} {
+		settingsState.General.TransmitPolicy = policy
+		if server.checkTransmit(packet) {
+			t.Errorf("policy %q forwarded an unauthorized packet", policy)
+		}
+	}
+	settingsState.General.TransmitPolicy = state.TransmitPolicyLenient
+	if !server.checkTransmit(packet) {
+		t.Errorf("lenient policy dropped an unauthorized packet")
+	}
+
+	serverState.SetRadioState(sender, &state.RadioState{Radios: []state.Radio{{ID: 1, Frequency: 251 * state.MHz, Enabled: true}}})
+	settingsState.General.TransmitPolicy = state.TransmitPolicyStrict
+	if !server.checkTransmit(packet) {
+		t.Errorf("strict policy dropped an authorized packet")
+	}
+
+	rejections := server.GetTransmitRejections()
+	if rejections[TransmitNoRadio] != 3 || rejections[TransmitOutOfRange] != 0 || rejections[TransmitRadioDisabled] != 0 {
+		t.Errorf("unexpected rejection counts: %v", rejections)
+	}
+
+	status, _ := server.buildServerStatus()
+	if got := status.TransmitRejections[TransmitNoRadio.String()]; got != 3 {
+		t.Errorf("heartbeat reports %d packets without radio, want 3", got)
+	}
+}
+
+func TestFrequencyPolicy(t *testing.T) {
+	serverState := &state.ServerState{}
+	settingsState := &state.SettingsState{
+		General: state.GeneralSettings{TransmitPolicy: state.TransmitPolicyLenient},
+		FrequencyPolicies: []state.FrequencyPolicy{
+			{Name: "Command", From: 251 * state.MHz, To: 252 * state.MHz, Coalitions: []string{"blue"}, ListenRole: 1, TransmitRole: 2},
+		},
+	}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, settingsState, events.NewEventBus())
+
+	clients := map[string]*state.ClientState{
+		"officer": {Name: "Officer", Coalition: "blue", Role: 2},
+		"member":  {Name: "Member", Coalition: "blue", Role: 1},
+		"guest":   {Name: "Guest", Coalition: "blue", Role: 0},
+		"red":     {Name: "Red Officer", Coalition: "red", Role: 2},
+	}
+	ids := make(map[string]uuid.UUID, len(clients))
+	for name, client := range clients {
+		id := uuid.New()
+		ids[name] = id
+		serverState.AddClient(id, client)
+		serverState.SetRadioState(id, &state.RadioState{Radios: []state.Radio{{ID: 1, Frequency: 251_500 * state.KHz, Enabled: true}}})
+		server.clients[id] = &Client{Addr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 10000 + len(ids)}}
+	}
+
+	if !server.checkTransmit(NewVCSVoicePacket(ids["officer"], 1, 251500, []byte{0xF8})) {
+		t.Errorf("officer may not transmit on the command net")
+	}
+	if server.checkTransmit(NewVCSVoicePacket(ids["member"], 1, 251500, []byte{0xF8})) {
+		t.Errorf("member transmitted on the command net under the lenient policy")
+	}
+	if rejections := server.GetTransmitRejections(); rejections[TransmitForbidden] != 1 {
+		t.Errorf("TransmitForbidden = %d, want 1", rejections[TransmitForbidden])
+	}
+
+	listeners := server.GetListeningClients(NewVCSVoicePacket(ids["officer"], 1, 251500, []byte{0xF8}), ids["officer"])
+	if len(listeners) != 1 || listeners[0] != server.clients[ids["member"]] {
+		t.Errorf("GetListeningClients() returned %d clients, want only the member", len(listeners))
+	}
+}
+
+func TestSpectatorTransmit(t *testing.T) {
+	serverState := &state.ServerState{}
+	settingsState := &state.SettingsState{General: state.GeneralSettings{TransmitPolicy: state.TransmitPolicyLenient}}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, settingsState, events.NewEventBus())
+	spectator := uuid.New()
+	serverState.AddClient(spectator, &state.ClientState{Name: "Streamer", Spectator: true})
+	serverState.SetRadioState(spectator, &state.RadioState{Radios: []state.Radio{
+		{ID: 1, Frequency: 251 * state.MHz, Enabled: true},
+		{ID: 2, IsIntercom: true, Enabled: true},
+	}})
+
+	intercom := NewVCSVoicePacket(spectator, 2, 0, []byte{0xF8})
+	intercom.SetIntercom(true)
+	for _, packet := range []*VCSPacket{NewVCSVoicePacket(spectator, 1, 251000, []byte{0xF8}), intercom} {
+		if got := server.authorizeTransmit(packet); got != TransmitSpectator {
+			t.Errorf("authorizeTransmit() = %v, want %v", got, TransmitSpectator)
+		}
+		if server.checkTransmit(packet) {
+			t.Errorf("spectator transmitted under the lenient policy")
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package voice
+
+import "net"
+
+// newBatchWriter returns nil, x/net fails every batch outside of Linux, so writeBatch sends the datagrams one by one
+func newBatchWriter(*net.UDPConn) batchWriter {
+	return nil
+}
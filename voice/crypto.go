@@ -0,0 +1,80 @@
+package voice
+
+import (
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	KeySaltSize  = 16                                                      // Size of the salt a HELLO carries to derive a fresh session key
+	NonceSize    = chacha20poly1305.NonceSizeX                             // Random nonce prepended to every sealed payload
+	SealOverhead = chacha20poly1305.NonceSizeX + chacha20poly1305.Overhead // Bytes a sealed payload is longer than the plain one
+
+	sessionKeyInfo = "vcs voice session key"
+)
+
+// NewSessionCipher derives the session key from the voice master key negotiated on login and the salt of a HELLO.
+// Every HELLO carries a new salt, so every HELLO rotates the session key.
+func NewSessionCipher(masterKey []byte, salt []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, masterKey, salt, sessionKeyInfo, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.NewX(key)
+}
+
+// SealedSize returns the length of the serialized packet with a sealed payload in bytes
+func (p *VCSPacket) SealedSize() int {
+	return p.Size() + SealOverhead
+}
+
+// SealInto writes the packet into data with the payload sealed by aead and returns the number of bytes written.
+// The Encrypted flag is only set in data, not on the packet, so the packet can still be serialized in plain for other receivers.
+// The header, including the header extension, is authenticated as additional data.
+func (p *VCSPacket) SealInto(data []byte, aead cipher.AEAD) (int, error) {
+	size := p.SealedSize()
+	if len(data) < size {
+		return 0, fmt.Errorf("buffer too small: need %d bytes, got %d", size, len(data))
+	}
+
+	if len(p.Extension) > MaxExtensionSize {
+		return 0, fmt.Errorf("header extension too long: %d bytes", len(p.Extension))
+	}
+
+	p.writeHeader(data)
+	data[4] |= 0x08 // Encrypted flag
+
+	headerLength := p.HeaderLength()
+	nonce := data[headerLength : headerLength+NonceSize]
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, err
+	}
+	aead.Seal(data[headerLength+NonceSize:headerLength+NonceSize], nonce, p.Payload, data[:headerLength])
+
+	return size, nil
+}
+
+// Open decrypts the sealed payload of the packet in place. header is the header as it was received, including the
+// header extension, which is authenticated as additional data. Afterwards the payload is plain and the Encrypted flag is cleared.
+func (p *VCSPacket) Open(header []byte, aead cipher.AEAD) error {
+	if len(p.Payload) < SealOverhead {
+		return errors.New("sealed payload too short")
+	}
+
+	nonce := p.Payload[:NonceSize]
+	sealed := p.Payload[NonceSize:]
+	plain, err := aead.Open(sealed[:0], nonce, sealed, header)
+	if err != nil {
+		return err
+	}
+
+	p.Payload = plain
+	p.SetEncrypted(false)
+	return nil
+}
@@ -0,0 +1,72 @@
+package voice
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSealOpen(t *testing.T) {
+	masterKey := bytes.Repeat([]byte{0x42}, 32)
+	salt := bytes.Repeat([]byte{0x01}, KeySaltSize)
+	aead, err := NewSessionCipher(masterKey, salt)
+	if err != nil {
+		t.Fatalf("NewSessionCipher() error = %v", err)
+	}
+
+	payload := []byte{0xF8, 0x01, 0x02, 0x03}
+	packet := NewVCSVoicePacket(uuid.New(), 7, 251000, payload)
+	data := make([]byte, packet.SealedSize())
+	n, err := packet.SealInto(data, aead)
+	if err != nil {
+		t.Fatalf("SealInto() error = %v", err)
+	}
+	if packet.IsEncrypted() {
+		t.Errorf("SealInto() set the Encrypted flag on the packet")
+	}
+	if bytes.Contains(data[:n], payload) {
+		t.Errorf("SealInto() left the payload in plain text")
+	}
+
+	var received VCSPacket
+	if err := ParsePacketInto(data[:n], &received); err != nil {
+		t.Fatalf("ParsePacketInto() error = %v", err)
+	}
+	if !received.IsEncrypted() {
+		t.Fatalf("sealed packet has no Encrypted flag")
+	}
+	if err := received.Open(data[:HeaderSize], aead); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if !bytes.Equal(received.Payload, payload) || received.IsEncrypted() {
+		t.Errorf("Open() = %v (encrypted %t), want %v", received.Payload, received.IsEncrypted(), payload)
+	}
+
+	// A rotated key must not open packets sealed with the previous one
+	rotated, err := NewSessionCipher(masterKey, bytes.Repeat([]byte{0x02}, KeySaltSize))
+	if err != nil {
+		t.Fatalf("NewSessionCipher() error = %v", err)
+	}
+	if _, err := packet.SealInto(data, aead); err != nil {
+		t.Fatalf("SealInto() error = %v", err)
+	}
+	if err := ParsePacketInto(data[:n], &received); err != nil {
+		t.Fatalf("ParsePacketInto() error = %v", err)
+	}
+	if err := received.Open(data[:HeaderSize], rotated); err == nil {
+		t.Errorf("Open() with rotated key succeeded")
+	}
+
+	// The header is authenticated, so changing the frequency breaks the packet
+	if _, err := packet.SealInto(data, aead); err != nil {
+		t.Fatalf("SealInto() error = %v", err)
+	}
+	data[10] ^= 0x01
+	if err := ParsePacketInto(data[:n], &received); err != nil {
+		t.Fatalf("ParsePacketInto() error = %v", err)
+	}
+	if err := received.Open(data[:HeaderSize], aead); err == nil {
+		t.Errorf("Open() with tampered header succeeded")
+	}
+}
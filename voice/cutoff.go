@@ -0,0 +1,78 @@
+package voice
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	"github.com/FPGSchiba/vcs-srs-server/transmissions"
+	"github.com/google/uuid"
+)
+
+const autoMuteName = "Stuck PTT detection" // Recorded as the admin for automatic mutes of repeat offenders
+
+// cutOff stops forwarding a transmission that exceeded the maximum transmission length, notifies the sender and the
+// GUI and mutes repeat offenders. Has to be called with transmissionsMu held.
+func (v *Server) cutOff(clientID uuid.UUID, active *activeTransmission, limit time.Duration, now time.Time) {
+	active.cutOff = true
+	v.arbiter.block(clientID) // The rest of the transmission is dropped, so it must not hold up other talkers
+	cutOff := transmissions.CutOff{
+		ClientGuid:    clientID.String(),
+		Name:          active.record.Name,
+		Frequency:     active.record.Frequency,
+		Start:         active.record.Start,
+		MaxDurationMs: limit.Milliseconds(),
+		Time:          now,
+	}
+	v.logger.Warn("Cut off transmission", "sender_id", clientID, "frequency", cutOff.Frequency, "max_duration", limit)
+	v.eventBus.Publish(events.Event{
+		Name: events.TransmissionCutOff,
+		Data: cutOff,
+	})
+	v.eventBus.Publish(events.Event{
+		Name: events.NotificationEvent,
+		Data: events.NewNotification("Transmission cut off",
+			fmt.Sprintf("%s transmitted on %s MHz for longer than %s", cutOff.Name, cutOff.Frequency, limit), "warning"),
+	})
+	v.muteRepeatOffender(clientID, active.record.Name, now)
+}
+
+// muteRepeatOffender counts the cut off transmissions of the client and mutes it once it reached the configured
+// number within the auto-mute window. Has to be called with transmissionsMu held.
+func (v *Server) muteRepeatOffender(clientID uuid.UUID, name string, now time.Time) {
+	settings := v.settingsState.GetTransmissionLimitSettings()
+	if settings.AutoMuteCutOffs <= 0 {
+		return
+	}
+	window := time.Duration(settings.AutoMuteWindow) * time.Second
+	cutOffs := slices.DeleteFunc(v.cutOffs[clientID], func(at time.Time) bool {
+		return now.Sub(at) > window
+	})
+	cutOffs = append(cutOffs, now)
+	if len(cutOffs) < settings.AutoMuteCutOffs {
+		v.cutOffs[clientID] = cutOffs
+		return
+	}
+	delete(v.cutOffs, clientID)
+
+	reason := fmt.Sprintf("%d transmissions cut off within %s", len(cutOffs), window)
+	record, err := v.serverState.MuteClient(clientID, autoMuteName, reason, time.Duration(settings.AutoMuteDuration)*time.Second)
+	if err != nil {
+		v.logger.Error("Failed to mute repeat offender", "sender_id", clientID, "error", err)
+		return
+	}
+	v.logger.Warn("Muted repeat offender", "sender_id", clientID, "reason", reason, "until", record.Until)
+	v.eventBus.Publish(events.Event{
+		Name: events.ClientMuteChanged,
+		Data: record,
+	})
+	v.eventBus.Publish(events.Event{
+		Name: events.RadioClientsChanged,
+		Data: v.serverState.RadioClients,
+	})
+	v.eventBus.Publish(events.Event{
+		Name: events.NotificationEvent,
+		Data: events.NewNotification("Client muted", fmt.Sprintf("%s was muted after %s", name, reason), "warning"),
+	})
+}
@@ -0,0 +1,84 @@
+package voice
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/FPGSchiba/vcs-srs-server/transmissions"
+	"github.com/google/uuid"
+)
+
+func TestCutOff(t *testing.T) {
+	serverState := &state.ServerState{}
+	settingsState := &state.SettingsState{TransmissionLimit: state.TransmissionLimitSettings{
+		MaxDuration:      1,
+		Frequencies:      []state.FrequencyTransmissionLimit{{Frequency: 243 * state.MHz, MaxDuration: 0}},
+		AutoMuteCutOffs:  2,
+		AutoMuteWindow:   60,
+		AutoMuteDuration: 30,
+	}}
+	eventBus := events.NewEventBus()
+	cutOffs := eventBus.Subscribe(events.TransmissionCutOff)
+	mutes := eventBus.Subscribe(events.ClientMuteChanged)
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, settingsState, eventBus)
+
+	id := uuid.New()
+	serverState.AddClient(id, &state.ClientState{Name: "Pilot"})
+	start := time.Now()
+	sequence := uint32(0)
+	// transmit sends voice every 20ms for the duration and returns the offset of the first dropped packet
+	transmit := func(frequency uint32, from, duration time.Duration) time.Duration {
+		dropped := time.Duration(-1)
+		for at := from; at <= from+duration; at += 20 * time.Millisecond {
+			sequence++
+			packet := NewVCSVoicePacket(id, sequence, frequency, nil)
+			packet.SetPTT(true)
+			if !server.trackTransmission(packet, start.Add(at)) && dropped < 0 {
+				dropped = at - from
+			}
+		}
+		sequence++
+		server.trackTransmission(NewVCSVoicePacket(id, sequence, frequency, nil), start.Add(from+duration))
+		return dropped
+	}
+
+	if dropped := transmit(251000, 0, 3*time.Second); dropped <= time.Second || dropped > time.Second+20*time.Millisecond {
+		t.Errorf("transmission was cut off after %v, want just after 1s", dropped)
+	}
+	select {
+	case event := <-cutOffs:
+		cutOff := event.Data.(transmissions.CutOff)
+		if cutOff.ClientGuid != id.String() || cutOff.Frequency != 251*state.MHz || cutOff.MaxDurationMs != 1000 {
+			t.Errorf("unexpected cut off %+v", cutOff)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("no cut off was published")
+	}
+
+	// The limit of the frequency replaces the global one
+	if dropped := transmit(243000, 4*time.Second, 3*time.Second); dropped >= 0 {
+		t.Errorf("transmission on a frequency without limit was cut off after %v", dropped)
+	}
+	if serverState.IsMuted(id, start.Add(8*time.Second)) {
+		t.Fatalf("client was muted after a single cut off")
+	}
+
+	// The second cut off within the window mutes the client
+	transmit(251000, 8*time.Second, 2*time.Second)
+	select {
+	case event := <-mutes:
+		record := event.Data.(state.MuteRecord)
+		if record.ClientGuid != id.String() || record.By != autoMuteName {
+			t.Errorf("unexpected mute %+v", record)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("repeat offender was not muted")
+	}
+	if !serverState.IsMuted(id, time.Now()) {
+		t.Errorf("repeat offender is not muted")
+	}
+}
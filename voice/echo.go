@@ -0,0 +1,154 @@
+package voice
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/google/uuid"
+)
+
+const (
+	MaxEchoDuration = 30 * time.Second       // Longer transmissions on a test frequency are cut off
+	EchoDelay       = 250 * time.Millisecond // Pause between the end of a transmission and its echo
+)
+
+// echoRecording buffers a transmission on a test frequency until the sender releases PTT
+type echoRecording struct {
+	frequency uint32
+	frames    [][]byte
+	durations []time.Duration
+	total     time.Duration
+	lastSeen  time.Time
+}
+
+// handleTestVoice echoes voice on a test frequency back to its sender, or plays it on the speakers of the server if enabled
+func (v *Server) handleTestVoice(packet *VCSPacket, now time.Time) {
+	if v.isLocalPlaybackEnabled() {
+		// The payload belongs to the receive buffer, so it has to be copied for the playback goroutine
+		go v.playback.Play(bytes.Clone(packet.Payload))
+		return
+	}
+	v.captureEcho(packet, now)
+}
+
+// isLocalPlaybackEnabled reports if test frequencies are played locally, which is only possible with the GUI
+func (v *Server) isLocalPlaybackEnabled() bool {
+	v.distributionState.RLock()
+	gui := v.distributionState.RuntimeMode == state.RuntimeModeGUI
+	v.distributionState.RUnlock()
+	return gui && v.settingsState.IsTestFrequencyPlaybackEnabled()
+}
+
+// captureEcho buffers the voice of the packet and starts the echo once the sender releases PTT or changes frequency
+func (v *Server) captureEcho(packet *VCSPacket, now time.Time) {
+	v.echoMu.Lock()
+	defer v.echoMu.Unlock()
+
+	recording, exists := v.echoes[packet.SenderID]
+	if exists && recording.frequency != packet.Frequency {
+		v.finishEcho(packet.SenderID, recording)
+		exists = false
+	}
+	if !exists {
+		if !packet.IsPTTActive() {
+			return
+		}
+		recording = &echoRecording{frequency: packet.Frequency}
+		v.echoes[packet.SenderID] = recording
+	}
+	recording.lastSeen = now
+
+	if samples, err := opusPacketSamples(packet.Payload); err == nil {
+		duration := time.Duration(samples) * time.Second / OpusSampleRate
+		if recording.total+duration <= MaxEchoDuration {
+			recording.frames = append(recording.frames, bytes.Clone(packet.Payload))
+			recording.durations = append(recording.durations, duration)
+			recording.total += duration
+		}
+	}
+
+	if !packet.IsPTTActive() {
+		v.finishEcho(packet.SenderID, recording)
+	}
+}
+
+// finishEcho has to be called with echoMu held
+func (v *Server) finishEcho(clientID uuid.UUID, recording *echoRecording) {
+	delete(v.echoes, clientID)
+	if len(recording.frames) == 0 {
+		return
+	}
+	go v.playEcho(clientID, recording)
+}
+
+// endTimedOutEchoes starts the echo of all transmissions whose release was lost
+func (v *Server) endTimedOutEchoes(now time.Time) {
+	v.echoMu.Lock()
+	defer v.echoMu.Unlock()
+	for clientID, recording := range v.echoes {
+		if now.Sub(recording.lastSeen) > TransmissionTimeout {
+			v.finishEcho(clientID, recording)
+		}
+	}
+}
+
+// playEcho sends the buffered transmission back to its sender, paced like the original frames.
+// The echo has its own sequence starting at 0 and releases PTT on its last packet.
+func (v *Server) playEcho(clientID uuid.UUID, recording *echoRecording) {
+	timer := time.NewTimer(EchoDelay)
+	defer timer.Stop()
+	select {
+	case <-v.stopChan:
+		return
+	case <-timer.C:
+	}
+
+	packet := NewVCSVoicePacket(clientID, 0, recording.frequency, nil)
+	start := time.Now()
+	var elapsed time.Duration
+	for i, frame := range recording.frames {
+		if wait := time.Until(start.Add(elapsed)); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-v.stopChan:
+				return
+			case <-timer.C:
+			}
+		}
+		packet.Sequence = uint32(i) & sequenceMask
+		packet.Payload = frame
+		packet.SetPTT(i < len(recording.frames)-1)
+		if !v.sendToClient(clientID, packet) {
+			return
+		}
+		elapsed += recording.durations[i]
+	}
+}
+
+// sendToClient sends the packet to a single client, sealed with its session key if it has one
+func (v *Server) sendToClient(clientID uuid.UUID, packet *VCSPacket) bool {
+	v.RLock()
+	client, exists := v.clients[clientID]
+	conn := v.conn
+	v.RUnlock()
+	if !exists || conn == nil {
+		return false
+	}
+
+	data := packet.SerializePacket()
+	if client.aead != nil {
+		data = make([]byte, packet.SealedSize())
+		n, err := packet.SealInto(data, client.aead)
+		if err != nil {
+			v.logger.Error("Failed to seal voice packet", "to", client.Addr.String(), "error", err)
+			return false
+		}
+		data = data[:n]
+	}
+	if _, err := conn.WriteToUDP(data, client.Addr); err != nil {
+		v.logger.Error("Failed to send voice packet", "to", client.Addr.String(), "error", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package voice
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/google/uuid"
+)
+
+func TestTestFrequencyEcho(t *testing.T) {
+	serverState := &state.ServerState{}
+	settingsState := &state.SettingsState{Frequencies: state.FrequencySettings{TestFrequencies: []state.Frequency{247_200 * state.KHz}}}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, settingsState, events.NewEventBus())
+	attachLoopback(t, server)
+	server.running = true
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP() error = %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	addr := conn.LocalAddr().(*net.UDPAddr).AddrPort()
+	sender, listener := uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{sender, listener} {
+		serverState.AddClient(id, &state.ClientState{Name: id.String(), Coalition: "blue"})
+		serverState.SetRadioState(id, &state.RadioState{Radios: []state.Radio{{ID: 1, Frequency: 247_200 * state.KHz, Enabled: true}}})
+	}
+	hello := &VCSPacket{Magic: [3]byte{'V', 'C', 'S'}, Version: currentVersion, Type: PacketTypeHello, SenderID: sender}
+	server.handlePacket(newInbound(hello, addr, nil))
+	buf := make([]byte, BufferSize)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(buf); err != nil {
+		t.Fatalf("Read(hello ack) error = %v", err)
+	}
+
+	// Three 20 ms frames with PTT and a release without audio, sent with gaps in the sequence
+	for i, sequence := range []uint32{100, 101, 105} {
+		packet := NewVCSVoicePacket(sender, sequence, 247200, []byte{31 << 3, byte(i)})
+		packet.SetPTT(true)
+		server.handlePacket(newInbound(packet, addr, nil))
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(EchoDelay / 2))
+	if _, err := conn.Read(buf); err == nil {
+		t.Fatalf("echo started before PTT was released")
+	}
+	released := time.Now()
+	server.handlePacket(newInbound(NewVCSVoicePacket(sender, 106, 247200, nil), addr, nil))
+
+	var arrivals []time.Time
+	for i := range 3 {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("Read(echo %d) error = %v", i, err)
+		}
+		arrivals = append(arrivals, time.Now())
+		packet, err := ParsePacket(buf[:n])
+		if err != nil {
+			t.Fatalf("ParsePacket() error = %v", err)
+		}
+		if packet.SenderID != sender || packet.Frequency != 247200 || packet.Sequence != uint32(i) {
+			t.Errorf("echo %d: sender %v, frequency %d, sequence %d", i, packet.SenderID, packet.Frequency, packet.Sequence)
+		}
+		if !bytes.Equal(packet.Payload, []byte{31 << 3, byte(i)}) {
+			t.Errorf("echo %d: payload %v", i, packet.Payload)
+		}
+		if packet.IsPTTActive() != (i < 2) {
+			t.Errorf("echo %d: PTT %t", i, packet.IsPTTActive())
+		}
+	}
+	if delay := arrivals[0].Sub(released); delay < EchoDelay {
+		t.Errorf("echo started %v after release, expected at least %v", delay, EchoDelay)
+	}
+	if paced := arrivals[2].Sub(arrivals[0]); paced < 35*time.Millisecond {
+		t.Errorf("echo frames were sent within %v, expected them to be paced 20 ms apart", paced)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := conn.Read(buf); err == nil {
+		t.Errorf("received more than the echoed frames")
+	}
+}
+
+func TestEchoTimeout(t *testing.T) {
+	server := NewServer(&state.ServerState{}, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, &state.SettingsState{}, events.NewEventBus())
+	sender := uuid.New()
+	now := time.Now()
+
+	packet := NewVCSVoicePacket(sender, 1, 247200, []byte{31 << 3})
+	packet.SetPTT(true)
+	server.captureEcho(packet, now)
+	server.endTimedOutEchoes(now.Add(TransmissionTimeout))
+	if len(server.echoes) != 1 {
+		t.Fatalf("echo ended before the timeout")
+	}
+	server.endTimedOutEchoes(now.Add(TransmissionTimeout + time.Millisecond))
+	if len(server.echoes) != 0 {
+		t.Errorf("echo did not end after the timeout")
+	}
+
+	// Released without a transmission does not start an echo
+	server.captureEcho(NewVCSVoicePacket(sender, 2, 247200, []byte{31 << 3}), now)
+	if len(server.echoes) != 0 {
+		t.Errorf("a packet without PTT started an echo")
+	}
+}
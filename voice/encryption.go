@@ -0,0 +1,55 @@
+package voice
+
+import (
+	"crypto/rand"
+
+	"github.com/FPGSchiba/vcs-srs-server/state"
+)
+
+// Radio encryption simulates the encrypted radios of SRS, it does not protect the voice. Voice is protected on the
+// wire by the session keys, see NewSessionCipher.
+
+// transmissionKey returns the key slot the transmission is encrypted with, 0 for clear voice or while radio encryption
+// is disabled
+func (v *Server) transmissionKey(packet *VCSPacket) uint8 {
+	if enabled, _ := v.settingsState.GetRadioEncryption(); !enabled || packet.IsIntercom() {
+		return 0
+	}
+	key, _ := packet.EncryptionKey()
+	return key
+}
+
+// decrypt decides which receivers hear a transmission encrypted with the key. Receivers tuned with the same key hear
+// it, receivers tuned with another key or without one get noise, or nothing in strict mode. Clear transmissions are heard
+// by everybody.
+func (v *Server) decrypt(receptions []reception, key uint8, frequency state.Frequency) []reception {
+	if key == 0 {
+		return receptions
+	}
+	_, strict := v.settingsState.GetRadioEncryption()
+	plan := v.settingsState.GetChannelPlan()
+	decrypted := receptions[:0]
+	for _, reception := range receptions {
+		radio, _ := v.serverState.GetRadioOnFrequency(reception.clientID, frequency, plan) // Guard receivers have no key
+		if radio.EncryptionKey != key {
+			if strict {
+				continue
+			}
+			reception.garbled = true
+		}
+		decrypted = append(decrypted, reception)
+	}
+	return decrypted
+}
+
+// garble returns noise as long as the payload for receivers that can not decrypt a transmission
+func garble(payload []byte) []byte {
+	noise := make([]byte, len(payload))
+	_, _ = rand.Read(noise) // Never fails
+	return noise
+}
+
+// isReceiverSpecific reports if the reception needs its own copy of the packet
+func (r reception) isReceiverSpecific() bool {
+	return r.simulated || r.garbled || r.client.aead != nil
+}
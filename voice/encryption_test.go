@@ -0,0 +1,100 @@
+package voice
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/google/uuid"
+)
+
+func TestRadioEncryption(t *testing.T) {
+	serverState := &state.ServerState{}
+	settingsState := &state.SettingsState{General: state.GeneralSettings{RadioEncryption: true}}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, settingsState, events.NewEventBus())
+	attachLoopback(t, server)
+
+	conns := make(map[string]*net.UDPConn)
+	addClient := func(name string, key uint8) uuid.UUID {
+		id := uuid.New()
+		serverState.AddClient(id, &state.ClientState{Name: name, Coalition: "blue"})
+		serverState.SetRadioState(id, &state.RadioState{Radios: []state.Radio{{ID: 1, Frequency: 251 * state.MHz, Enabled: true, EncryptionKey: key}}})
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("ListenUDP() error = %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		conns[name] = conn
+		server.clients[id] = &Client{Addr: conn.LocalAddr().(*net.UDPAddr), LastSeen: time.Now()}
+		return id
+	}
+	sender := addClient("sender", 5)
+	addClient("same key", 5)
+	addClient("other key", 6)
+	addClient("clear", 0)
+
+	receive := func(name string) (*VCSPacket, bool) {
+		buf := make([]byte, BufferSize)
+		_ = conns[name].SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, err := conns[name].Read(buf)
+		if err != nil {
+			return nil, false
+		}
+		packet, err := ParsePacket(buf[:n])
+		if err != nil {
+			t.Fatalf("ParsePacket() error = %v", err)
+		}
+		return packet, true
+	}
+	payload := []byte{0xF8, 0x01, 0x02, 0x03}
+	transmit := func(key uint8) {
+		packet := NewVCSVoicePacket(sender, 1, 251000, bytes.Clone(payload))
+		if key != 0 {
+			packet.SetEncryptionKey(key)
+		}
+		server.broadcastVoice(packet, sender)
+	}
+
+	transmit(5)
+	if got, ok := receive("same key"); !ok || got.IsGarbled() || !bytes.Equal(got.Payload, payload) {
+		t.Errorf("receiver with the same key got %v, want the voice in clear", got)
+	} else if key, ok := got.EncryptionKey(); !ok || key != 5 {
+		t.Errorf("receiver with the same key got encryption key %d, %t, want 5", key, ok)
+	}
+	for _, name := range []string{"other key", "clear"} {
+		if got, ok := receive(name); !ok || !got.IsGarbled() || bytes.Equal(got.Payload, payload) || len(got.Payload) != len(payload) {
+			t.Errorf("receiver %s got %v, want noise with the Garbled flag", name, got)
+		}
+	}
+
+	transmit(0)
+	for _, name := range []string{"same key", "other key", "clear"} {
+		if got, ok := receive(name); !ok || got.IsGarbled() || !bytes.Equal(got.Payload, payload) {
+			t.Errorf("receiver %s got %v for a clear transmission, want the voice in clear", name, got)
+		}
+	}
+
+	settingsState.General.StrictRadioEncryption = true
+	transmit(5)
+	if _, ok := receive("same key"); !ok {
+		t.Errorf("receiver with the same key did not hear the transmission in strict mode")
+	}
+	for _, name := range []string{"other key", "clear"} {
+		if _, ok := receive(name); ok {
+			t.Errorf("receiver %s heard an encrypted transmission in strict mode", name)
+		}
+	}
+
+	settingsState.General.RadioEncryption = false
+	transmit(5)
+	for _, name := range []string{"same key", "other key", "clear"} {
+		if got, ok := receive(name); !ok || got.IsGarbled() || got.IsExtended() {
+			t.Errorf("receiver %s got %v with radio encryption disabled, want the voice in clear without key", name, got)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package voice
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/google/uuid"
+)
+
+const (
+	floodStateIdle        = time.Minute      // Rate limit state of an address or session unused for this long is dropped
+	parseErrorLogInterval = 10 * time.Second // Parse failures are logged at most once per interval
+)
+
+// AddressBlock is an IP address the voice server ignores for a while, because it flooded the socket or sent garbage
+type AddressBlock struct {
+	Address string
+	Reason  string
+	Since   time.Time
+	Until   time.Time
+}
+
+// tokenBucket allows rate packets per second on average and burst packets at once
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) take(now time.Time, rate float64, burst int) bool {
+	if b.last.IsZero() {
+		b.tokens = float64(burst)
+	} else {
+		b.tokens = min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// addressGuard is the rate limit and violation count of a single IP address
+type addressGuard struct {
+	bucket      tokenBucket
+	violations  int
+	windowStart time.Time
+	block       *AddressBlock
+}
+
+// floodGuard rate limits addresses and sessions and blocks addresses that keep violating the limits
+type floodGuard struct {
+	mu        sync.Mutex
+	addresses map[netip.Addr]*addressGuard
+	sessions  map[uuid.UUID]*tokenBucket
+
+	lastParseLog     time.Time
+	suppressedParses int
+}
+
+func newFloodGuard() *floodGuard {
+	return &floodGuard{
+		addresses: make(map[netip.Addr]*addressGuard),
+		sessions:  make(map[uuid.UUID]*tokenBucket),
+	}
+}
+
+// allowAddress reports if a packet of the address may be handled. Packets above the rate count as violations,
+// so the returned block is set if the packet got the address blocked.
+func (g *floodGuard) allowAddress(addr netip.Addr, now time.Time, settings state.FloodProtectionSettings) (bool, *AddressBlock) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	guard := g.address(addr)
+	if guard.block != nil {
+		return false, nil
+	}
+	if settings.AddressRate <= 0 || guard.bucket.take(now, settings.AddressRate, settings.AddressBurst) {
+		return true, nil
+	}
+	return false, g.violate(addr, guard, "rate limit exceeded", now, settings)
+}
+
+// allowSession reports if a packet of the session may be handled
+func (g *floodGuard) allowSession(clientID uuid.UUID, now time.Time, settings state.FloodProtectionSettings) bool {
+	if settings.SessionRate <= 0 {
+		return true
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	bucket, exists := g.sessions[clientID]
+	if !exists {
+		bucket = &tokenBucket{}
+		g.sessions[clientID] = bucket
+	}
+	return bucket.take(now, settings.SessionRate, settings.SessionBurst)
+}
+
+// reportViolation counts a dropped or malformed packet of the address and returns the block if it got the address blocked
+func (g *floodGuard) reportViolation(addr netip.Addr, reason string, now time.Time, settings state.FloodProtectionSettings) *AddressBlock {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	guard := g.address(addr)
+	if guard.block != nil {
+		return nil
+	}
+	return g.violate(addr, guard, reason, now, settings)
+}
+
+// sampleParseError reports if a parse failure should be logged and how many were suppressed since the last one
+func (g *floodGuard) sampleParseError(now time.Time) (bool, int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if now.Sub(g.lastParseLog) < parseErrorLogInterval {
+		g.suppressedParses++
+		return false, 0
+	}
+	suppressed := g.suppressedParses
+	g.lastParseLog = now
+	g.suppressedParses = 0
+	return true, suppressed
+}
+
+// expire lifts all blocks that ran out before now, drops idle rate limit state and returns the lifted blocks
+func (g *floodGuard) expire(now time.Time) []AddressBlock {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var lifted []AddressBlock
+	for addr, guard := range g.addresses {
+		if guard.block != nil {
+			if now.Before(guard.block.Until) {
+				continue
+			}
+			lifted = append(lifted, *guard.block)
+			guard.block = nil
+			guard.violations = 0
+		}
+		if now.Sub(guard.bucket.last) > floodStateIdle && now.Sub(guard.windowStart) > floodStateIdle {
+			delete(g.addresses, addr)
+		}
+	}
+	for clientID, bucket := range g.sessions {
+		if now.Sub(bucket.last) > floodStateIdle {
+			delete(g.sessions, clientID)
+		}
+	}
+	return lifted
+}
+
+// blocks returns all addresses that are currently blocked
+func (g *floodGuard) blocks() []AddressBlock {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var blocks []AddressBlock
+	for _, guard := range g.addresses {
+		if guard.block != nil {
+			blocks = append(blocks, *guard.block)
+		}
+	}
+	return blocks
+}
+
+// address has to be called with mu held
+func (g *floodGuard) address(addr netip.Addr) *addressGuard {
+	guard, exists := g.addresses[addr]
+	if !exists {
+		guard = &addressGuard{}
+		g.addresses[addr] = guard
+	}
+	return guard
+}
+
+// violate has to be called with mu held
+func (g *floodGuard) violate(addr netip.Addr, guard *addressGuard, reason string, now time.Time, settings state.FloodProtectionSettings) *AddressBlock {
+	if settings.BlockThreshold <= 0 {
+		return nil
+	}
+	if now.Sub(guard.windowStart) > time.Duration(settings.BlockWindow)*time.Second {
+		guard.windowStart = now
+		guard.violations = 0
+	}
+	guard.violations++
+	if guard.violations < settings.BlockThreshold {
+		return nil
+	}
+	guard.block = &AddressBlock{
+		Address: addr.String(),
+		Reason:  reason,
+		Since:   now,
+		Until:   now.Add(time.Duration(settings.BlockDuration) * time.Second),
+	}
+	return guard.block
+}
+
+// admitAddress is called by the receive loop for every datagram, before it is handed to a worker
+func (v *Server) admitAddress(addr netip.Addr, now time.Time) bool {
+	settings := v.settingsState.GetFloodProtectionSettings()
+	if !settings.Enabled {
+		return true
+	}
+	allowed, block := v.flood.allowAddress(addr, now, settings)
+	if block != nil {
+		v.publishBlock(*block)
+	}
+	return allowed
+}
+
+// admitPacket checks the payload size of a parsed packet and the rate of the session it belongs to.
+// The session rate is only applied to packets from the address the session is bound to, so spoofed packets
+// cannot use up the rate of another client.
+func (v *Server) admitPacket(packet *VCSPacket, addr netip.AddrPort, now time.Time) bool {
+	settings := v.settingsState.GetFloodProtectionSettings()
+	if !settings.Enabled {
+		return true
+	}
+	maxPayload := settings.MaxControlPayload
+	if packet.Type == PacketTypeVoice {
+		maxPayload = settings.MaxVoicePayload
+	}
+	if maxPayload > 0 && len(packet.Payload) > maxPayload {
+		v.logger.Debug("Dropping oversized packet", "type", packet.Type, "size", len(packet.Payload), "addr", addr.String())
+		v.reportViolation(addr.Addr(), "oversized "+packet.Type.String()+" packet", now, settings)
+		return false
+	}
+
+	v.RLock()
+	client, bound := v.clients[packet.SenderID]
+	bound = bound && client.Addr.AddrPort() == addr
+	v.RUnlock()
+	if bound && !v.flood.allowSession(packet.SenderID, now, settings) {
+		v.reportViolation(addr.Addr(), "session rate limit exceeded", now, settings)
+		return false
+	}
+	return true
+}
+
+// reportParseError counts a malformed datagram against its address and logs a sample of the failures
+func (v *Server) reportParseError(addr netip.AddrPort, err error, now time.Time) {
+	if log, suppressed := v.flood.sampleParseError(now); log {
+		v.logger.Warn("Failed to parse voice packet", "addr", addr.String(), "error", err, "suppressed", suppressed)
+	}
+	if settings := v.settingsState.GetFloodProtectionSettings(); settings.Enabled {
+		v.reportViolation(addr.Addr(), "malformed packets", now, settings)
+	}
+}
+
+func (v *Server) reportViolation(addr netip.Addr, reason string, now time.Time, settings state.FloodProtectionSettings) {
+	if block := v.flood.reportViolation(addr, reason, now, settings); block != nil {
+		v.publishBlock(*block)
+	}
+}
+
+func (v *Server) publishBlock(block AddressBlock) {
+	v.logger.Warn("Blocked voice address", "addr", block.Address, "reason", block.Reason, "until", block.Until)
+	v.eventBus.Publish(events.Event{
+		Name: events.VoiceAddressBlocked,
+		Data: block,
+	})
+}
+
+// GetBlockedAddresses returns all addresses the voice server currently ignores
+func (v *Server) GetBlockedAddresses() []AddressBlock {
+	return v.flood.blocks()
+}
+
+// floodRoutine lifts expired blocks and forgets idle addresses and sessions
+func (v *Server) floodRoutine() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-v.stopChan:
+			return
+		case now := <-ticker.C:
+			for _, block := range v.flood.expire(now) {
+				v.logger.Info("Unblocked voice address", "addr", block.Address)
+				v.eventBus.Publish(events.Event{
+					Name: events.VoiceAddressUnblocked,
+					Data: block,
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package voice
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/google/uuid"
+)
+
+func TestTokenBucket(t *testing.T) {
+	var bucket tokenBucket
+	now := time.Now()
+	for i := range 3 {
+		if !bucket.take(now, 10, 3) {
+			t.Fatalf("packet %d of the burst was limited", i)
+		}
+	}
+	if bucket.take(now, 10, 3) {
+		t.Errorf("packet above the burst was allowed")
+	}
+	if !bucket.take(now.Add(100*time.Millisecond), 10, 3) {
+		t.Errorf("packet after refilling a token was limited")
+	}
+	if bucket.take(now.Add(100*time.Millisecond), 10, 3) {
+		t.Errorf("bucket refilled more than the rate")
+	}
+}
+
+func TestAddressBlock(t *testing.T) {
+	eventBus := events.NewEventBus()
+	blocked := eventBus.Subscribe(events.VoiceAddressBlocked)
+	unblocked := eventBus.Subscribe(events.VoiceAddressUnblocked)
+	settingsState := &state.SettingsState{FloodProtection: state.FloodProtectionSettings{
+		Enabled:        true,
+		AddressRate:    10,
+		AddressBurst:   5,
+		BlockThreshold: 3,
+		BlockWindow:    10,
+		BlockDuration:  60,
+	}}
+	server := NewServer(&state.ServerState{}, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, settingsState, eventBus)
+	addr := netip.MustParseAddr("192.0.2.1")
+	now := time.Now()
+
+	admitted := 0
+	for range 20 {
+		if server.admitAddress(addr, now) {
+			admitted++
+		}
+	}
+	if admitted != 5 {
+		t.Errorf("admitted %d packets, want the burst of 5", admitted)
+	}
+	select {
+	case event := <-blocked:
+		if block := event.Data.(AddressBlock); block.Address != "192.0.2.1" || !block.Until.Equal(now.Add(time.Minute)) {
+			t.Errorf("unexpected block %+v", block)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no block was published")
+	}
+	if server.admitAddress(addr, now.Add(30*time.Second)) {
+		t.Errorf("blocked address was admitted")
+	}
+	if !server.admitAddress(netip.MustParseAddr("192.0.2.2"), now) {
+		t.Errorf("other address was not admitted")
+	}
+
+	if lifted := server.flood.expire(now.Add(59 * time.Second)); len(lifted) != 0 {
+		t.Errorf("block was lifted early")
+	}
+	if lifted := server.flood.expire(now.Add(time.Minute)); len(lifted) != 1 {
+		t.Fatalf("expected the block to be lifted, got %v", lifted)
+	}
+	if !server.admitAddress(addr, now.Add(time.Minute)) {
+		t.Errorf("address was not admitted after the block ran out")
+	}
+	if len(server.GetBlockedAddresses()) != 0 {
+		t.Errorf("GetBlockedAddresses() = %v, want none", server.GetBlockedAddresses())
+	}
+	select {
+	case <-unblocked:
+		t.Errorf("expire published an event, only the flood routine publishes unblocks")
+	default:
+	}
+}
+
+func TestAdmitPacket(t *testing.T) {
+	settingsState := &state.SettingsState{FloodProtection: state.FloodProtectionSettings{
+		Enabled:           true,
+		SessionRate:       1,
+		SessionBurst:      2,
+		MaxVoicePayload:   16,
+		MaxControlPayload: 4,
+	}}
+	server := NewServer(&state.ServerState{}, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, settingsState, events.NewEventBus())
+	client := uuid.New()
+	addr := netip.MustParseAddrPort("127.0.0.1:40000")
+	server.clients[client] = &Client{Addr: net.UDPAddrFromAddrPort(addr)}
+	now := time.Now()
+
+	if server.admitPacket(NewVCSVoicePacket(client, 1, 251000, make([]byte, 17)), addr, now) {
+		t.Errorf("oversized voice packet was admitted")
+	}
+	if server.admitPacket(&VCSPacket{Type: PacketTypeKeepalive, SenderID: client, Payload: make([]byte, 5)}, addr, now) {
+		t.Errorf("oversized keepalive was admitted")
+	}
+
+	// Spoofed packets from another address do not use up the rate of the session
+	spoofed := netip.MustParseAddrPort("127.0.0.1:40001")
+	for range 5 {
+		if !server.admitPacket(NewVCSVoicePacket(client, 1, 251000, make([]byte, 16)), spoofed, now) {
+			t.Fatalf("packet from an unbound address was limited by the session rate")
+		}
+	}
+	for i := range 2 {
+		if !server.admitPacket(NewVCSVoicePacket(client, uint32(i), 251000, make([]byte, 16)), addr, now) {
+			t.Fatalf("packet %d of the session burst was limited", i)
+		}
+	}
+	if server.admitPacket(NewVCSVoicePacket(client, 3, 251000, make([]byte, 16)), addr, now) {
+		t.Errorf("packet above the session rate was admitted")
+	}
+}
+
+func TestSampleParseError(t *testing.T) {
+	guard := newFloodGuard()
+	now := time.Now()
+	if log, _ := guard.sampleParseError(now); !log {
+		t.Fatalf("first parse error was not logged")
+	}
+	for range 5 {
+		if log, _ := guard.sampleParseError(now.Add(time.Second)); log {
+			t.Fatalf("parse error within the log interval was logged")
+		}
+	}
+	if log, suppressed := guard.sampleParseError(now.Add(parseErrorLogInterval)); !log || suppressed != 5 {
+		t.Errorf("sampleParseError() = %t, %d, want true, 5", log, suppressed)
+	}
+}
@@ -0,0 +1,66 @@
+package voice
+
+import (
+	"time"
+
+	pb "github.com/FPGSchiba/vcs-srs-server/voicecontrolpb"
+)
+
+const (
+	HeartbeatInterval = 10 * time.Second // Interval a distributed voice server reports its status to the control server with
+)
+
+// buildServerStatus collects the status reported with the heartbeat, including the voice quality of all sessions and the
+// unauthorized voice packets
+func (v *Server) buildServerStatus() (*pb.ServerStatus, []*pb.ClientInfo) {
+	v.RLock()
+	clients := make([]*pb.ClientInfo, 0, len(v.clients))
+	for id, client := range v.clients {
+		clients = append(clients, &pb.ClientInfo{
+			ClientId:      id.String(),
+			ClientAddress: client.Addr.IP.String(),
+			ClientPort:    int32(client.Addr.Port),
+		})
+	}
+	v.RUnlock()
+
+	status := &pb.ServerStatus{
+		ActiveConnections:  int32(len(clients)),
+		IsHealthy:          v.isRunning(),
+		TransmitRejections: make(map[string]uint64),
+	}
+	for reason, count := range v.GetTransmitRejections() {
+		status.TransmitRejections[reason.String()] = count
+	}
+	for clientID, streams := range v.quality.all() {
+		for _, quality := range streams {
+			status.SessionQuality = append(status.SessionQuality, &pb.SessionQuality{
+				ClientId:          clientID.String(),
+				Frequency:         float64(quality.Frequency.MHz()),
+				PacketsReceived:   quality.Received,
+				PacketsLost:       quality.Lost,
+				PacketsReordered:  quality.Reordered,
+				PacketsDuplicated: quality.Duplicates,
+				JitterMs:          quality.JitterMs,
+			})
+		}
+	}
+	return status, clients
+}
+
+func (v *Server) heartbeatRoutine() {
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-v.stopChan:
+			return
+		case <-ticker.C:
+			status, clients := v.buildServerStatus()
+			if err := v.controlClient.SendHeartbeat(status, clients); err != nil {
+				v.logger.Warn("Failed to send heartbeat to control server", "error", err)
+			}
+		}
+	}
+}
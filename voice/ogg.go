@@ -0,0 +1,209 @@
+package voice
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	OpusSampleRate = 48000 // Granule positions of Ogg/Opus streams always count 48 kHz samples
+
+	oggPageHeaderSize = 27
+	oggMaxSegments    = 255
+	oggFlagBOS        = 0x02 // First page of a logical stream
+	oggFlagEOS        = 0x04 // Last page of a logical stream
+
+	opusMaxPacketSamples = 5760 // 120 ms, the longest duration an Opus packet may have
+)
+
+var (
+	errEmptyOpusPacket   = errors.New("empty opus packet")
+	errInvalidOpusPacket = errors.New("invalid opus packet")
+)
+
+// oggCRCTable is the CRC-32 of the Ogg framing: polynomial 0x04c11db7, not reflected, no final xor
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		crc := uint32(i) << 24
+		for range 8 {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+func oggChecksum(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// oggOpusWriter writes a mono Ogg/Opus stream (RFC 7845) with one audio packet per page.
+// The latest packet is held back, so the stream can be ended with the EOS flag on its last page.
+type oggOpusWriter struct {
+	w        io.Writer
+	serial   uint32
+	sequence uint32
+	granule  uint64 // Samples of all packets written so far, including the held back one
+	pending  []byte // Audio packet that is written once the next one arrives or the stream is closed
+	page     []byte // Reused page buffer
+	size     int64  // Bytes written to w
+}
+
+// newOggOpusWriter writes the OpusHead and OpusTags header pages. The comments are added to OpusTags as KEY=value.
+func newOggOpusWriter(w io.Writer, serial uint32, comments ...string) (*oggOpusWriter, error) {
+	o := &oggOpusWriter{w: w, serial: serial}
+
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1                                              // Version
+	head[9] = 1                                              // Channels
+	binary.LittleEndian.PutUint16(head[10:], 0)              // Pre-skip, frames are recorded mid-stream so nothing is skipped
+	binary.LittleEndian.PutUint32(head[12:], OpusSampleRate) // Input sample rate
+	binary.LittleEndian.PutUint16(head[16:], 0)              // Output gain
+	head[18] = 0                                             // Channel mapping family
+	if err := o.writePage(head, oggFlagBOS, 0); err != nil {
+		return nil, err
+	}
+
+	const vendor = "vcs-srs-server"
+	tags := make([]byte, 0, 64)
+	tags = append(tags, "OpusTags"...)
+	tags = binary.LittleEndian.AppendUint32(tags, uint32(len(vendor)))
+	tags = append(tags, vendor...)
+	tags = binary.LittleEndian.AppendUint32(tags, uint32(len(comments)))
+	for _, comment := range comments {
+		tags = binary.LittleEndian.AppendUint32(tags, uint32(len(comment)))
+		tags = append(tags, comment...)
+	}
+	if err := o.writePage(tags, 0, 0); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// WritePacket adds an Opus packet lasting the given number of 48 kHz samples to the stream
+func (o *oggOpusWriter) WritePacket(packet []byte, samples int) error {
+	if len(packet)/oggMaxSegments >= oggMaxSegments {
+		return fmt.Errorf("opus packet of %d bytes does not fit into an ogg page", len(packet))
+	}
+	if o.pending != nil {
+		if err := o.writePage(o.pending, 0, o.granule); err != nil {
+			return err
+		}
+	}
+	o.pending = append(o.pending[:0], packet...)
+	o.granule += uint64(samples)
+	return nil
+}
+
+// Size returns the size of the stream once closed
+func (o *oggOpusWriter) Size() int64 {
+	if o.pending == nil {
+		return o.size
+	}
+	return o.size + int64(oggPageHeaderSize+len(o.pending)/oggMaxSegments+1+len(o.pending))
+}
+
+// Duration returns the number of samples in the stream
+func (o *oggOpusWriter) Duration() uint64 {
+	return o.granule
+}
+
+// Close writes the held back packet on the last page of the stream, it does not close the underlying writer
+func (o *oggOpusWriter) Close() error {
+	if o.pending == nil {
+		return nil
+	}
+	err := o.writePage(o.pending, oggFlagEOS, o.granule)
+	o.pending = nil
+	return err
+}
+
+// writePage writes a page holding exactly one packet
+func (o *oggOpusWriter) writePage(packet []byte, flags byte, granule uint64) error {
+	segments := len(packet)/oggMaxSegments + 1
+	page := o.page[:0]
+	page = append(page, "OggS"...)
+	page = append(page, 0, flags) // Version, header type
+	page = binary.LittleEndian.AppendUint64(page, granule)
+	page = binary.LittleEndian.AppendUint32(page, o.serial)
+	page = binary.LittleEndian.AppendUint32(page, o.sequence)
+	page = binary.LittleEndian.AppendUint32(page, 0) // Checksum, filled in below
+	page = append(page, byte(segments))
+	for range segments - 1 {
+		page = append(page, oggMaxSegments)
+	}
+	page = append(page, byte(len(packet)%oggMaxSegments))
+	page = append(page, packet...)
+	binary.LittleEndian.PutUint32(page[22:], oggChecksum(page))
+	o.page = page
+
+	n, err := o.w.Write(page)
+	o.size += int64(n)
+	if err != nil {
+		return err
+	}
+	o.sequence++
+	return nil
+}
+
+// opusPacketSamples returns the number of 48 kHz samples in an Opus packet from its TOC byte (RFC 6716 section 3.1)
+func opusPacketSamples(packet []byte) (int, error) {
+	if len(packet) == 0 {
+		return 0, errEmptyOpusPacket
+	}
+	toc := packet[0]
+	var frames int
+	switch toc & 0x03 {
+	case 0:
+		frames = 1
+	case 1, 2:
+		frames = 2
+	default:
+		if len(packet) < 2 {
+			return 0, errInvalidOpusPacket
+		}
+		frames = int(packet[1] & 0x3F)
+	}
+	samples := frames * opusFrameSamples(toc>>3)
+	if samples == 0 || samples > opusMaxPacketSamples {
+		return 0, errInvalidOpusPacket
+	}
+	return samples, nil
+}
+
+// opusFrameSamples returns the number of 48 kHz samples of a single frame of the TOC configuration
+func opusFrameSamples(config byte) int {
+	switch {
+	case config < 12: // SILK: 10, 20, 40 and 60 ms
+		return [4]int{480, 960, 1920, 2880}[config%4]
+	case config < 16: // Hybrid: 10 and 20 ms
+		return [2]int{480, 960}[config%2]
+	default: // CELT: 2.5, 5, 10 and 20 ms
+		return [4]int{120, 240, 480, 960}[config%4]
+	}
+}
+
+// opusSilence returns mono CELT silence packets lasting the given number of samples, rounded down to 2.5 ms.
+// Each packet is a full-band CELT TOC followed by the silence flag.
+func opusSilence(samples int) [][]byte {
+	var packets [][]byte
+	for config := byte(31); config >= 28; config-- { // 20, 10, 5 and 2.5 ms
+		frameSamples := opusFrameSamples(config)
+		for ; samples >= frameSamples; samples -= frameSamples {
+			packets = append(packets, []byte{config << 3, 0xFF, 0xFE})
+		}
+	}
+	return packets
+}
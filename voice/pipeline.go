@@ -0,0 +1,124 @@
+package voice
+
+import (
+	"net/netip"
+	"runtime"
+	"sync"
+
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	PacketQueueSize = 1024 // Received packets waiting for a worker, further packets are dropped
+)
+
+// inboundPacket is a pooled receive buffer together with the packet parsed from it
+type inboundPacket struct {
+	buf    [BufferSize]byte
+	n      int
+	addr   netip.AddrPort
+	packet VCSPacket
+}
+
+var inboundPool = sync.Pool{
+	New: func() any {
+		return &inboundPacket{}
+	},
+}
+
+// sendBatch is a pooled send buffer with reusable messages for a single fan-out
+type sendBatch struct {
+	buf      [BufferSize]byte
+	copies   []byte // Per receiver copies of the packet for receivers with a session key, a signal strength or noise
+	messages []ipv4.Message
+}
+
+var sendBatchPool = sync.Pool{
+	New: func() any {
+		return &sendBatch{}
+	},
+}
+
+// messagesFor returns n messages, each with a single buffer slot
+func (b *sendBatch) messagesFor(n int) []ipv4.Message {
+	for len(b.messages) < n {
+		b.messages = append(b.messages, ipv4.Message{Buffers: make([][]byte, 1)})
+	}
+	return b.messages[:n]
+}
+
+// copiesFor returns a buffer large enough for a copy of the packet of size bytes for every receiver that gets its own,
+// see reception.isReceiverSpecific
+func (b *sendBatch) copiesFor(receptions []reception, size int) []byte {
+	own := 0
+	for _, reception := range receptions {
+		if reception.isReceiverSpecific() {
+			own++
+		}
+	}
+	if cap(b.copies) < own*size {
+		b.copies = make([]byte, own*size)
+	}
+	return b.copies[:own*size]
+}
+
+// release drops all references to sent data and addresses and returns the batch to the pool
+func (b *sendBatch) release(messages []ipv4.Message) {
+	for i := range messages {
+		messages[i].Buffers[0] = nil
+		messages[i].Addr = nil
+	}
+	sendBatchPool.Put(b)
+}
+
+// batchWriter sends multiple datagrams with a single syscall where the platform supports it (sendmmsg on Linux).
+// ipv4.Message and ipv6.Message are the same type, so both packet connections satisfy it. newBatchWriter returns nil
+// on other platforms, where datagrams are sent one by one.
+type batchWriter interface {
+	WriteBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+// startWorkers starts a bounded number of packet handlers reading from queue.
+// The returned WaitGroup is done once the queue is closed and drained.
+func (v *Server) startWorkers(queue <-chan *inboundPacket) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Go(func() {
+			for in := range queue {
+				v.handlePacket(in)
+				inboundPool.Put(in)
+			}
+		})
+	}
+	return &wg
+}
+
+// writeBatch sends all messages. If batching is not supported or fails, the rest is sent one by one, so a single
+// failing receiver does not cost the others their voice.
+func (v *Server) writeBatch(messages []ipv4.Message) {
+	if v.batchConn == nil {
+		v.writeEach(messages)
+		return
+	}
+	for len(messages) > 0 {
+		n, err := v.batchConn.WriteBatch(messages, 0)
+		if err != nil || n <= 0 {
+			// x/net returns n == -1 on errors, so the failed message is unknown
+			v.logger.Debug("Batch send failed, sending one by one", "messages", len(messages), "error", err)
+			v.writeEach(messages[max(n, 0):])
+			return
+		}
+		messages = messages[n:]
+	}
+}
+
+// writeEach sends the messages one datagram at a time, skipping messages that fail to send
+func (v *Server) writeEach(messages []ipv4.Message) {
+	for _, message := range messages {
+		if _, err := v.conn.WriteTo(message.Buffers[0], message.Addr); err != nil {
+			v.logger.Error("Failed to send voice packet",
+				"to", message.Addr.String(),
+				"error", err)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+//go:build !headless
+
+package voice
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+	"github.com/pion/opus"
+)
+
+const (
+	minCELTConfig     = 16
+	maxOpusFrameSize  = 11520
+	samplesPerChannel = 960 // 20ms at 48kHz
+)
+
+// localPlayback plays voice on the speakers of the server
+type localPlayback struct {
+	logger *slog.Logger
+
+	// Playback/decoder state
+	playOnce   sync.Once
+	opusDec    opus.Decoder
+	pipeW      *io.PipeWriter
+	playFormat beep.Format
+	playErr    error
+
+	// Optional: gate concurrent writes to the pipe if Play is called from multiple goroutines
+	playMu sync.Mutex
+}
+
+func newLocalPlayback(logger *slog.Logger) *localPlayback {
+	return &localPlayback{logger: logger}
+}
+
+// Play decodes the payload and plays it directly, without dejitter buffer - for clean local test playback
+func (p *localPlayback) Play(payload []byte) {
+	if len(payload) == 0 {
+		return
+	}
+	toc := payload[0]
+	config := toc >> 3
+	if config < minCELTConfig {
+		// Not CELT-only (likely SILK/hybrid). Drop to avoid Pion error.
+		p.logger.Warn("Dropping non-CELT Opus packet", "config", config, "len_payload", len(payload))
+		return
+	}
+
+	// Lazy-initialize decoder and speaker once
+	p.playOnce.Do(func() {
+		var err error
+		p.opusDec = opus.NewDecoder() // mono 48kHz
+
+		p.playFormat = beep.Format{
+			SampleRate:  beep.SampleRate(48000),
+			NumChannels: 1,
+			Precision:   2,
+		}
+
+		// Larger buffer for smoother playback
+		err = speaker.Init(p.playFormat.SampleRate, p.playFormat.SampleRate.N(300*time.Millisecond))
+		if err != nil {
+			p.playErr = err
+			p.logger.Error("Failed to init Speaker", "error", err)
+			return
+		}
+
+		pr, pw := io.Pipe()
+		p.pipeW = pw
+
+		stream := &pcmStream{
+			r:   pr,
+			f:   p.playFormat,
+			buf: make([]byte, 8192*p.playFormat.Width()),
+		}
+		speaker.Play(stream)
+	})
+
+	if p.playErr != nil || p.pipeW == nil {
+		p.logger.Error("Audio playback not initialized", "error", p.playErr)
+		return
+	}
+
+	// Decode directly - buffer for up to 60ms stereo @ 48kHz
+	out := make([]byte, maxOpusFrameSize)
+
+	// Serialize opus decoder access and pipe writes; opus.Decoder is not goroutine-safe
+	p.playMu.Lock()
+	// Protect against library panics on corrupt/non-Opus payloads
+	var (
+		bw       opus.Bandwidth
+		isStereo bool
+		err      error
+	)
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("opus decode panic: %v", r)
+			}
+		}()
+		bw, isStereo, err = p.opusDec.Decode(payload, out)
+	}()
+	if err != nil {
+		p.playMu.Unlock()
+		p.logger.Error("Failed to decode Opus data", "error", err)
+		return
+	}
+
+	// Get sample rate and assume 20ms frame
+	sr := bw.SampleRate()
+	if sr == 0 {
+		sr = 48000
+	}
+	samplesPerCh := sr / 50 // 20ms
+	if samplesPerCh <= 0 {
+		samplesPerCh = samplesPerChannel
+	}
+
+	// Calculate actual bytes to use
+	channels := 1
+	if isStereo {
+		channels = 2
+	}
+	bytesToUse := samplesPerCh * 2 * channels
+	if bytesToUse > len(out) {
+		bytesToUse = len(out)
+	}
+
+	var pcmData []byte
+	if isStereo {
+		// Downmix to mono
+		pcmData = downmixStereoS16LEToMono(out[:bytesToUse], samplesPerCh)
+	} else {
+		pcmData = out[:bytesToUse]
+	}
+
+	// Write directly to pipe while still holding the lock (serialize with decoder)
+	_, err = p.pipeW.Write(pcmData)
+	p.playMu.Unlock()
+
+	if err != nil {
+		p.logger.Error("Failed to write PCM to speaker pipe", "error", err)
+	}
+}
+
+// downmixStereoS16LEToMono averages L/R channels into mono S16LE.
+func downmixStereoS16LEToMono(in []byte, samplesPerCh int) []byte {
+	out := make([]byte, samplesPerCh*2)
+	for i := 0; i < samplesPerCh; i++ {
+		li := 4 * i
+		ri := li + 2
+		l := int16(uint16(in[li]) | uint16(in[li+1])<<8)
+		r := int16(uint16(in[ri]) | uint16(in[ri+1])<<8)
+		m := int16((int32(l) + int32(r)) / 2)
+		oi := 2 * i
+		out[oi] = byte(uint16(m))
+		out[oi+1] = byte(uint16(m) >> 8)
+	}
+	return out
+}
+
+// pcmStream allows faiface to play raw S16LE PCM directly.
+// This is adapted from the example you pasted.
+type pcmStream struct {
+	r   io.Reader
+	f   beep.Format
+	buf []byte
+	len int
+	pos int
+	err error
+}
+
+func (s *pcmStream) Err() error { return s.err }
+
+func (s *pcmStream) Stream(samples [][2]float64) (n int, ok bool) {
+	width := s.f.Width()
+
+	// If there's not enough data for a full sample, get more
+	if size := s.len - s.pos; size < width {
+		// If there's a partial sample, move it to the beginning of the buffer
+		if size != 0 {
+			copy(s.buf, s.buf[s.pos:s.len])
+		}
+		s.len = size
+		s.pos = 0
+
+		// Refill the buffer
+		nbytes, err := s.r.Read(s.buf[s.len:])
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+			return n, false
+		}
+		s.len += nbytes
+	}
+
+	// Decode as many samples as we can
+	for n < len(samples) && s.len-s.pos >= width {
+		samples[n], _ = s.f.DecodeSigned(s.buf[s.pos:])
+		n++
+		s.pos += width
+	}
+	return n, true
+}
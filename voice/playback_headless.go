@@ -0,0 +1,24 @@
+//go:build headless
+
+package voice
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// localPlayback is not available in headless builds, as they do not link an audio backend
+type localPlayback struct {
+	logger   *slog.Logger
+	warnOnce sync.Once
+}
+
+func newLocalPlayback(logger *slog.Logger) *localPlayback {
+	return &localPlayback{logger: logger}
+}
+
+func (p *localPlayback) Play(_ []byte) {
+	p.warnOnce.Do(func() {
+		p.logger.Warn("Local playback of test frequencies is not available in headless builds")
+	})
+}
@@ -0,0 +1,145 @@
+package voice
+
+import (
+	"math"
+
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/google/uuid"
+)
+
+const (
+	earthRadius    = 6371.0 // Mean radius of the earth in km
+	horizonFactor  = 4.12   // km to the radio horizon per square root of the antenna height in meters, with 4/3 earth radius refraction
+	referenceRange = 1.0    // km up to which a transmission is heard at full strength
+)
+
+// PropagationModel decides if a receiver hears a transmission on a frequency between two positions, and how well
+type PropagationModel interface {
+	// Propagate returns the signal strength from 0 to 1 the receiver hears the transmission with, ok is false if the
+	// receiver does not hear it at all
+	Propagate(frequency state.Frequency, transmitter, receiver state.Position) (strength float64, ok bool)
+}
+
+// FreeSpaceModel limits transmissions to the range of the band of their frequency. The strength falls with the free-space
+// path loss: it is the share of the loss between referenceRange and the range of the band that is left at the receiver.
+// With Horizon set, receivers below the radio horizon of the transmitter over a smooth earth do not hear it either.
+type FreeSpaceModel struct {
+	Bands   []state.RadioBand
+	Horizon bool
+}
+
+func (m FreeSpaceModel) Propagate(frequency state.Frequency, transmitter, receiver state.Position) (float64, bool) {
+	ground := groundDistance(transmitter, receiver)
+	if m.Horizon && ground > radioHorizon(transmitter.Altitude, receiver.Altitude) {
+		return 0, false
+	}
+
+	band, exists := m.band(frequency)
+	if !exists {
+		return 1, true // No band, no range limit
+	}
+	height := transmitter.Altitude - receiver.Altitude
+	distance := math.Hypot(ground, height/1000)
+	if distance > band.Range {
+		return 0, false
+	}
+	if distance <= referenceRange || band.Range <= referenceRange {
+		return 1, true
+	}
+	// Free-space path loss grows with 20*log10(distance), the common factor cancels out
+	return 1 - math.Log10(distance/referenceRange)/math.Log10(band.Range/referenceRange), true
+}
+
+// band returns the first band containing the frequency
+func (m FreeSpaceModel) band(frequency state.Frequency) (state.RadioBand, bool) {
+	for _, band := range m.Bands {
+		if frequency >= band.From && frequency <= band.To {
+			return band, true
+		}
+	}
+	return state.RadioBand{}, false
+}
+
+// groundDistance returns the great-circle distance between two positions in km
+func groundDistance(a, b state.Position) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(min(h, 1)))
+}
+
+// radioHorizon returns the distance in km up to which two antennas at the altitudes in meters see each other
+func radioHorizon(a, b float64) float64 {
+	return horizonFactor * (math.Sqrt(max(a, 0)) + math.Sqrt(max(b, 0)))
+}
+
+// SetPropagationModel replaces the model that decides which receivers hear a transmission while propagation is
+// enabled in the settings. nil restores the FreeSpaceModel of the settings.
+func (v *Server) SetPropagationModel(model PropagationModel) {
+	v.Lock()
+	defer v.Unlock()
+	v.propagation = model
+}
+
+// propagationModel returns the model transmissions on the frequency are propagated with, nil if propagation is
+// disabled or the frequency is heard everywhere anyway
+func (v *Server) propagationModel(frequency state.Frequency) PropagationModel {
+	settings := v.settingsState.GetPropagationSettings()
+	if (!settings.Distance && !settings.LineOfSight) || v.settingsState.IsFrequencyGlobal(frequency) {
+		return nil
+	}
+	v.RLock()
+	model := v.propagation
+	v.RUnlock()
+	if model != nil {
+		return model
+	}
+	if !settings.Distance {
+		settings.Bands = nil
+	}
+	return FreeSpaceModel{Bands: settings.Bands, Horizon: settings.LineOfSight}
+}
+
+// signalStrength converts a strength from 0 to 1 into the value of the ExtensionSignalStrength entry
+func signalStrength(strength float64) uint8 {
+	return uint8(math.Round(max(0, min(strength, 1)) * math.MaxUint8))
+}
+
+// reception is a client that hears a transmission
+type reception struct {
+	clientID  uuid.UUID
+	client    *Client
+	strength  uint8 // Value of the ExtensionSignalStrength entry the client gets
+	simulated bool  // The strength was simulated, otherwise the client gets no signal strength
+	garbled   bool  // Tuned with another encryption key, so the client gets noise, see decrypt
+}
+
+// propagate returns the listeners that hear the transmission of the transmitter on the frequency with their signal
+// strength. While propagation is disabled, and for listeners without a position or transmitters without one, every
+// listener hears it without a simulated signal strength.
+func (v *Server) propagate(listeners []uuid.UUID, transmitterId uuid.UUID, frequency state.Frequency, model PropagationModel) []reception {
+	receptions := make([]reception, 0, len(listeners))
+	var transmitter state.Position
+	located := false
+	if model != nil {
+		transmitter, located = v.serverState.GetPosition(transmitterId)
+	}
+	for _, clientID := range listeners {
+		if !located {
+			receptions = append(receptions, reception{clientID: clientID})
+			continue
+		}
+		receiver, exists := v.serverState.GetPosition(clientID)
+		if !exists {
+			receptions = append(receptions, reception{clientID: clientID})
+			continue
+		}
+		strength, ok := model.Propagate(frequency, transmitter, receiver)
+		if !ok {
+			continue
+		}
+		receptions = append(receptions, reception{clientID: clientID, strength: signalStrength(strength), simulated: true})
+	}
+	return receptions
+}
@@ -0,0 +1,127 @@
+package voice
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/google/uuid"
+)
+
+func TestFreeSpaceModel(t *testing.T) {
+	base := state.Position{Latitude: 42, Longitude: 42, Altitude: 100}
+	north := func(km, altitude float64) state.Position {
+		return state.Position{Latitude: base.Latitude + km/111.2, Longitude: base.Longitude, Altitude: altitude}
+	}
+
+	tests := []struct {
+		name      string
+		model     FreeSpaceModel
+		frequency state.Frequency
+		receiver  state.Position
+		wantOk    bool
+		wantFull  bool
+	}{
+		{"same position", FreeSpaceModel{Bands: state.DefaultRadioBands}, 251 * state.MHz, base, true, true},
+		{"within uhf range", FreeSpaceModel{Bands: state.DefaultRadioBands}, 251 * state.MHz, north(100, 100), true, false},
+		{"beyond uhf range", FreeSpaceModel{Bands: state.DefaultRadioBands}, 251 * state.MHz, north(250, 100), false, false},
+		{"within vhf range", FreeSpaceModel{Bands: state.DefaultRadioBands}, 124 * state.MHz, north(150, 100), true, false},
+		{"outside every band", FreeSpaceModel{Bands: state.DefaultRadioBands}, 900 * state.KHz, north(5000, 100), true, true},
+		{"below the horizon", FreeSpaceModel{Bands: state.DefaultRadioBands, Horizon: true}, 251 * state.MHz, north(100, 0), false, false},
+		{"above the horizon", FreeSpaceModel{Bands: state.DefaultRadioBands, Horizon: true}, 251 * state.MHz, north(100, 1000), true, false},
+		{"horizon without bands", FreeSpaceModel{Horizon: true}, 251 * state.MHz, north(100, 1000), true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strength, ok := tt.model.Propagate(tt.frequency, base, tt.receiver)
+			if ok != tt.wantOk {
+				t.Fatalf("Propagate() ok = %t, want %t", ok, tt.wantOk)
+			}
+			if ok && (strength == 1) != tt.wantFull {
+				t.Errorf("Propagate() strength = %v, want full strength %t", strength, tt.wantFull)
+			}
+			if strength < 0 || strength > 1 {
+				t.Errorf("Propagate() strength = %v, want between 0 and 1", strength)
+			}
+		})
+	}
+
+	near, _ := FreeSpaceModel{Bands: state.DefaultRadioBands}.Propagate(251*state.MHz, base, north(20, 100))
+	far, _ := FreeSpaceModel{Bands: state.DefaultRadioBands}.Propagate(251*state.MHz, base, north(120, 100))
+	if near <= far {
+		t.Errorf("Propagate() strength at 20 km = %v, not above the strength at 120 km = %v", near, far)
+	}
+}
+
+func TestPropagatedVoice(t *testing.T) {
+	serverState := &state.ServerState{}
+	settingsState := &state.SettingsState{Propagation: state.PropagationSettings{Distance: true, Bands: state.DefaultRadioBands}}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, settingsState, events.NewEventBus())
+	attachLoopback(t, server)
+
+	sender := uuid.New()
+	origin := state.Position{Latitude: 42, Longitude: 42, Altitude: 1000}
+	conns := make(map[string]*net.UDPConn)
+	addClient := func(name string, position *state.Position) uuid.UUID {
+		id := uuid.New()
+		serverState.AddClient(id, &state.ClientState{Name: name, Coalition: "blue"})
+		serverState.SetRadioState(id, &state.RadioState{Radios: []state.Radio{{ID: 1, Frequency: 251 * state.MHz, Enabled: true}}})
+		if position != nil {
+			if err := serverState.SetPosition(id, *position); err != nil {
+				t.Fatalf("SetPosition() error = %v", err)
+			}
+		}
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("ListenUDP() error = %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		conns[name] = conn
+		server.clients[id] = &Client{Addr: conn.LocalAddr().(*net.UDPAddr), LastSeen: time.Now()}
+		return id
+	}
+	serverState.AddClient(sender, &state.ClientState{Name: "Sender", Coalition: "blue"})
+	serverState.SetRadioState(sender, &state.RadioState{Radios: []state.Radio{{ID: 1, Frequency: 251 * state.MHz, Enabled: true}}})
+	if err := serverState.SetPosition(sender, origin); err != nil {
+		t.Fatalf("SetPosition() error = %v", err)
+	}
+	addClient("near", &state.Position{Latitude: 42.5, Longitude: 42, Altitude: 1000})
+	addClient("far", &state.Position{Latitude: 45, Longitude: 42, Altitude: 1000})
+	addClient("unlocated", nil)
+
+	receive := func(name string) (*VCSPacket, bool) {
+		buf := make([]byte, BufferSize)
+		_ = conns[name].SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, err := conns[name].Read(buf)
+		if err != nil {
+			return nil, false
+		}
+		packet, err := ParsePacket(buf[:n])
+		if err != nil {
+			t.Fatalf("ParsePacket() error = %v", err)
+		}
+		return packet, true
+	}
+
+	packet := NewVCSVoicePacket(sender, 1, 251000, []byte{0xF8, 0x01})
+	packet.SetSignalStrength(255) // Senders can not fake the signal strength
+	server.broadcastVoice(packet, sender)
+
+	if got, ok := receive("near"); !ok {
+		t.Errorf("receiver within range did not hear the transmission")
+	} else if strength, ok := got.SignalStrength(); !ok || strength == 0 || strength == 255 {
+		t.Errorf("receiver within range got signal strength %d, %t, want a simulated strength", strength, ok)
+	}
+	if _, ok := receive("far"); ok {
+		t.Errorf("receiver beyond range heard the transmission")
+	}
+	if got, ok := receive("unlocated"); !ok {
+		t.Errorf("receiver without position did not hear the transmission")
+	} else if _, ok := got.SignalStrength(); ok {
+		t.Errorf("receiver without position got a signal strength")
+	}
+}
@@ -1,9 +1,12 @@
 package voice
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 
+	"github.com/FPGSchiba/vcs-srs-server/state"
 	"github.com/google/uuid"
 )
 
@@ -45,17 +48,31 @@ type VCSPacket struct {
 	Magic     [3]byte    // Protocol identifier (VCS)
 	Version   uint8      // Protocol version (4 bits)
 	Type      PacketType // Packet type (4 bits)
-	Flags     uint8      // Flags (1. bit PTT, 2. bit Intercom, 6 bits reserved)
+	Flags     uint8      // Flags (1. bit PTT, 2. bit Intercom, 3. bit Authenticated, 4. bit Encrypted, 5. bit Overlap, 6. bit Extended, 7. bit Garbled, 1 bit reserved)
 	Sequence  uint32     // 24-bit sequence number
 	Frequency uint32     // 24-bit frequency in kHz
 	SenderID  uuid.UUID  // UUIDv4 session identifier
+	Extension []byte     // Entries of the header extension, only serialized with the Extended flag, see ExtensionSignalStrength
 	Payload   []byte     // Variable payload data
 }
 
 // Constants
 const (
 	HeaderSize = 27 // Total header size in bytes
+	TagSize    = 16 // Size of the truncated HMAC-SHA256 tag trailing authenticated packets
 	MagicVCS   = "VCS"
+
+	MaxExtensionSize = 255 // Bytes of entries a header extension can hold behind its length byte
+
+	signalEntrySize = 3 // Bytes of a signal strength entry in the header extension
+)
+
+// Types of header extension entries. Every entry is a type byte, a length byte and length bytes of value.
+const (
+	ExtensionSignalStrength uint8 = 0x01 // 1 byte, strength a receiver hears the transmission with, 0 to 255 for full strength
+	ExtensionEncryptionKey  uint8 = 0x02 // 1 byte, key slot the radio of the transmission encrypts with, see state.Radio.EncryptionKey
+	ExtensionHops           uint8 = 0x03 // 1 byte, relays the transmission passed, see state.RetransmissionSettings
+	ExtensionOrigin         uint8 = 0x04 // 3 bytes, frequency in kHz a relayed transmission was originally sent on
 )
 
 func NewVCSHelloAckPacket(clientId uuid.UUID) *VCSPacket {
@@ -125,6 +142,160 @@ func (p *VCSPacket) SetIntercom(active bool) {
 	}
 }
 
+// IsAuthenticated returns true if the Authenticated flag is set, meaning an HMAC tag trails the payload
+func (p *VCSPacket) IsAuthenticated() bool {
+	return (p.Flags & 0x04) != 0
+}
+
+// SetAuthenticated sets or clears the Authenticated flag
+func (p *VCSPacket) SetAuthenticated(active bool) {
+	if active {
+		p.Flags |= 0x04
+	} else {
+		p.Flags &= 0xFB
+	}
+}
+
+// IsEncrypted returns true if the Encrypted flag is set, meaning the payload is sealed with the session key
+func (p *VCSPacket) IsEncrypted() bool {
+	return (p.Flags & 0x08) != 0
+}
+
+// SetEncrypted sets or clears the Encrypted flag
+func (p *VCSPacket) SetEncrypted(active bool) {
+	if active {
+		p.Flags |= 0x08
+	} else {
+		p.Flags &= 0xF7
+	}
+}
+
+// IsOverlapping returns true if the Overlap flag is set, meaning another client transmits on the frequency at the same time
+func (p *VCSPacket) IsOverlapping() bool {
+	return (p.Flags & 0x10) != 0
+}
+
+// SetOverlapping sets or clears the Overlap flag
+func (p *VCSPacket) SetOverlapping(active bool) {
+	if active {
+		p.Flags |= 0x10
+	} else {
+		p.Flags &= 0xEF
+	}
+}
+
+// IsExtended returns true if the Extended flag is set, meaning a header extension follows the header
+func (p *VCSPacket) IsExtended() bool {
+	return (p.Flags & 0x20) != 0
+}
+
+// extensionEntry returns the value of an entry of the header extension with the given length, ok is false if the
+// packet has none
+func (p *VCSPacket) extensionEntry(entryType uint8, length int) (value []byte, ok bool) {
+	if !p.IsExtended() {
+		return nil, false
+	}
+	for entries := p.Extension; len(entries) >= 2; {
+		size := 2 + int(entries[1])
+		if size > len(entries) {
+			break
+		}
+		if entries[0] == entryType && int(entries[1]) == length {
+			return entries[2:size], true
+		}
+		entries = entries[size:]
+	}
+	return nil, false
+}
+
+// extensionByte returns the value of a 1 byte entry of the header extension, ok is false if the packet has none
+func (p *VCSPacket) extensionByte(entryType uint8) (value uint8, ok bool) {
+	entry, ok := p.extensionEntry(entryType, 1)
+	if !ok {
+		return 0, false
+	}
+	return entry[0], true
+}
+
+// appendExtension adds an entry to the header extension and sets the Extended flag
+func (p *VCSPacket) appendExtension(entryType uint8, value ...byte) {
+	p.Extension = append(p.Extension, entryType, byte(len(value)))
+	p.Extension = append(p.Extension, value...)
+	p.Flags |= 0x20
+}
+
+// truncateExtension keeps the first size bytes of the header extension, and clears the Extended flag if none are left
+func (p *VCSPacket) truncateExtension(size int) {
+	p.Extension = p.Extension[:size]
+	if size == 0 {
+		p.Flags &= 0xDF
+	}
+}
+
+// SignalStrength returns the signal strength entry of the header extension, ok is false if the packet has none
+func (p *VCSPacket) SignalStrength() (strength uint8, ok bool) {
+	return p.extensionByte(ExtensionSignalStrength)
+}
+
+// SetSignalStrength adds a signal strength entry to the header extension
+func (p *VCSPacket) SetSignalStrength(strength uint8) {
+	p.appendExtension(ExtensionSignalStrength, strength)
+}
+
+// EncryptionKey returns the encryption key entry of the header extension, ok is false if the packet has none
+func (p *VCSPacket) EncryptionKey() (key uint8, ok bool) {
+	return p.extensionByte(ExtensionEncryptionKey)
+}
+
+// SetEncryptionKey adds an encryption key entry to the header extension
+func (p *VCSPacket) SetEncryptionKey(key uint8) {
+	p.appendExtension(ExtensionEncryptionKey, key)
+}
+
+// Hops returns the hop counter entry of the header extension, ok is false if the packet has none
+func (p *VCSPacket) Hops() (hops uint8, ok bool) {
+	return p.extensionByte(ExtensionHops)
+}
+
+// SetHops adds a hop counter entry to the header extension
+func (p *VCSPacket) SetHops(hops uint8) {
+	p.appendExtension(ExtensionHops, hops)
+}
+
+// OriginFrequency returns the origin entry of the header extension in kHz, ok is false if the packet has none
+func (p *VCSPacket) OriginFrequency() (frequency uint32, ok bool) {
+	entry, ok := p.extensionEntry(ExtensionOrigin, 3)
+	if !ok {
+		return 0, false
+	}
+	return uint32(entry[0])<<16 | uint32(entry[1])<<8 | uint32(entry[2]), true
+}
+
+// SetOriginFrequency adds an origin entry with the frequency in kHz to the header extension
+func (p *VCSPacket) SetOriginFrequency(frequency uint32) {
+	p.appendExtension(ExtensionOrigin, byte(frequency>>16), byte(frequency>>8), byte(frequency))
+}
+
+// IsGarbled returns true if the Garbled flag is set, meaning the receiver is tuned with another encryption key and
+// the payload is noise
+func (p *VCSPacket) IsGarbled() bool {
+	return (p.Flags & 0x40) != 0
+}
+
+// SetGarbled sets or clears the Garbled flag
+func (p *VCSPacket) SetGarbled(active bool) {
+	if active {
+		p.Flags |= 0x40
+	} else {
+		p.Flags &= 0xBF
+	}
+}
+
+// ClearExtension removes the header extension and clears the Extended flag
+func (p *VCSPacket) ClearExtension() {
+	p.truncateExtension(0)
+}
+
 // FrequencyMHz returns the frequency in MHz as a float64
 func (p *VCSPacket) FrequencyMHz() float64 {
 	return float64(p.Frequency) / 1000.0
@@ -137,23 +308,44 @@ func (p *VCSPacket) SetFrequencyMHz(freqMHz float64) {
 
 // ParsePacket parses a raw UDP packet into a VCSPacket struct
 func ParsePacket(data []byte) (*VCSPacket, error) {
-	if len(data) < HeaderSize {
-		return nil, errors.New("packet too short")
+	packet := &VCSPacket{}
+	if err := ParsePacketInto(data, packet); err != nil {
+		return nil, err
 	}
 
-	packet := &VCSPacket{}
+	// Detach the extension and payload from the receive buffer
+	if len(packet.Extension) > 0 {
+		packet.Extension = append([]byte(nil), packet.Extension...)
+	} else {
+		packet.Extension = nil
+	}
+	if len(packet.Payload) > 0 {
+		packet.Payload = append([]byte(nil), packet.Payload...)
+	} else {
+		packet.Payload = nil
+	}
+
+	return packet, nil
+}
+
+// ParsePacketInto parses a raw UDP packet into the given VCSPacket without allocating.
+// The payload references data, so it is only valid as long as data is not reused.
+func ParsePacketInto(data []byte, packet *VCSPacket) error {
+	if len(data) < HeaderSize {
+		return errors.New("packet too short")
+	}
 
 	// Parse magic (3 bytes)
 	copy(packet.Magic[:], data[0:3])
 	if string(packet.Magic[:]) != MagicVCS {
-		return nil, fmt.Errorf("invalid magic: expected %s, got %s", MagicVCS, string(packet.Magic[:]))
+		return fmt.Errorf("invalid magic: expected %s, got %s", MagicVCS, string(packet.Magic[:]))
 	}
 
 	// Parse version/type (1 byte)
 	versionType := data[3]
 	packet.Version = (versionType >> 4) & 0x0F
 	if packet.Version != currentVersion {
-		return nil, fmt.Errorf("unsupported protocol version: %d", packet.Version)
+		return fmt.Errorf("unsupported protocol version: %d", packet.Version)
 	}
 	packet.Type = PacketType(versionType & 0x0F)
 
@@ -167,26 +359,66 @@ func ParsePacket(data []byte) (*VCSPacket, error) {
 	packet.Frequency = uint32(data[8])<<16 | uint32(data[9])<<8 | uint32(data[10])
 
 	// Parse session ID (16 bytes)
-	sessionBytes := data[11:27]
-	var err error
-	packet.SenderID, err = uuid.FromBytes(sessionBytes)
-	if err != nil {
-		return nil, fmt.Errorf("invalid session ID: %v", err)
+	copy(packet.SenderID[:], data[11:27])
+
+	// Parse header extension (1 byte length, then the entries)
+	headerSize := HeaderSize
+	packet.Extension = nil
+	if packet.IsExtended() {
+		if len(data) < HeaderSize+1 || len(data) < HeaderSize+1+int(data[HeaderSize]) {
+			return errors.New("header extension too short")
+		}
+		headerSize += 1 + int(data[HeaderSize])
+		// Capped, so extending it can never overwrite the payload
+		packet.Extension = data[HeaderSize+1 : headerSize : headerSize]
 	}
 
 	// Parse payload (remaining bytes)
-	if len(data) > HeaderSize {
-		packet.Payload = make([]byte, len(data)-HeaderSize)
-		copy(packet.Payload, data[HeaderSize:])
-	}
+	packet.Payload = data[headerSize:]
 
-	return packet, nil
+	return nil
 }
 
 // SerializePacket converts a VCSPacket struct back to raw bytes
 func (p *VCSPacket) SerializePacket() []byte {
-	data := make([]byte, HeaderSize+len(p.Payload))
+	data := make([]byte, p.Size())
+	_, _ = p.SerializeInto(data) // Cannot fail, data is exactly Size() bytes long
+	return data
+}
+
+// Size returns the length of the serialized packet in bytes
+func (p *VCSPacket) Size() int {
+	return p.HeaderLength() + len(p.Payload)
+}
+
+// HeaderLength returns the length of the serialized header, including the header extension, in bytes
+func (p *VCSPacket) HeaderLength() int {
+	if !p.IsExtended() {
+		return HeaderSize
+	}
+	return HeaderSize + 1 + len(p.Extension)
+}
+
+// SerializeInto writes the packet into data and returns the number of bytes written.
+// data has to be at least Size() bytes long.
+func (p *VCSPacket) SerializeInto(data []byte) (int, error) {
+	if len(data) < p.Size() {
+		return 0, fmt.Errorf("buffer too small: need %d bytes, got %d", p.Size(), len(data))
+	}
+	if len(p.Extension) > MaxExtensionSize {
+		return 0, fmt.Errorf("header extension too long: %d bytes", len(p.Extension))
+	}
+
+	p.writeHeader(data)
+
+	// Payload
+	copy(data[p.HeaderLength():], p.Payload)
+
+	return p.Size(), nil
+}
 
+// writeHeader writes the first HeaderLength bytes of the serialized packet into data
+func (p *VCSPacket) writeHeader(data []byte) {
 	// Magic (3 bytes)
 	copy(data[0:3], p.Magic[:])
 
@@ -207,15 +439,34 @@ func (p *VCSPacket) SerializePacket() []byte {
 	data[10] = byte(p.Frequency)
 
 	// Session ID (16 bytes)
-	sessionBytes, _ := p.SenderID.MarshalBinary()
-	copy(data[11:27], sessionBytes)
+	copy(data[11:27], p.SenderID[:])
 
-	// Payload
-	if len(p.Payload) > 0 {
-		copy(data[HeaderSize:], p.Payload)
+	// Header extension (1 byte length, then the entries)
+	if p.IsExtended() {
+		data[HeaderSize] = byte(len(p.Extension))
+		copy(data[HeaderSize+1:], p.Extension)
 	}
+}
 
-	return data
+// SignPacket appends the HMAC tag of the serialized packet keyed by the session secret.
+// The packet has to be serialized with the Authenticated flag set.
+func SignPacket(data []byte, secret []byte) []byte {
+	return append(data, packetTag(data, secret)...)
+}
+
+// VerifyPacketTag checks the HMAC tag trailing the serialized packet against the session secret
+func VerifyPacketTag(data []byte, secret []byte) bool {
+	if len(data) < HeaderSize+TagSize {
+		return false
+	}
+	body := data[:len(data)-TagSize]
+	return hmac.Equal(data[len(data)-TagSize:], packetTag(body, secret))
+}
+
+func packetTag(data []byte, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return mac.Sum(nil)[:TagSize]
 }
 
 // String returns a string representation of the packet for debugging
@@ -227,3 +478,8 @@ func (p *VCSPacket) String() string {
 func (p *VCSPacket) FrequencyAsFloat32() float32 {
 	return float32(p.Frequency) / 1000.0
 }
+
+// RadioFrequency returns the frequency in Hz for matching it against radios and settings
+func (p *VCSPacket) RadioFrequency() state.Frequency {
+	return state.FrequencyFromKHz(p.Frequency)
+}
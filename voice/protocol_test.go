@@ -0,0 +1,153 @@
+package voice
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSerializeParseRoundTrip(t *testing.T) {
+	packet := NewVCSVoicePacket(uuid.New(), 0x123456, 251000, []byte{0xF8, 0x01, 0x02, 0x03})
+	packet.SetPTT(true)
+
+	data := packet.SerializePacket()
+	if len(data) != packet.Size() {
+		t.Fatalf("SerializePacket() length = %d, want %d", len(data), packet.Size())
+	}
+
+	var parsed VCSPacket
+	if err := ParsePacketInto(data, &parsed); err != nil {
+		t.Fatalf("ParsePacketInto() error = %v", err)
+	}
+	if parsed.SenderID != packet.SenderID || parsed.Sequence != packet.Sequence || parsed.Frequency != packet.Frequency || parsed.Flags != packet.Flags {
+		t.Errorf("ParsePacketInto() = %v, want %v", parsed.String(), packet.String())
+	}
+	if !bytes.Equal(parsed.Payload, packet.Payload) {
+		t.Errorf("ParsePacketInto() payload = %v, want %v", parsed.Payload, packet.Payload)
+	}
+
+	copied, err := ParsePacket(data)
+	if err != nil {
+		t.Fatalf("ParsePacket() error = %v", err)
+	}
+	data[HeaderSize] = 0x00
+	if copied.Payload[0] != 0xF8 {
+		t.Errorf("ParsePacket() payload still references the input buffer")
+	}
+
+	if _, err := packet.SerializeInto(make([]byte, HeaderSize)); err == nil {
+		t.Errorf("SerializeInto() with a short buffer did not return an error")
+	}
+}
+
+func TestHeaderExtension(t *testing.T) {
+	packet := NewVCSVoicePacket(uuid.New(), 1, 251000, []byte{0xF8, 0x01, 0x02})
+	packet.SetSignalStrength(200)
+
+	data := packet.SerializePacket()
+	if len(data) != HeaderSize+4+len(packet.Payload) {
+		t.Fatalf("SerializePacket() length = %d, want %d", len(data), HeaderSize+4+len(packet.Payload))
+	}
+	parsed, err := ParsePacket(data)
+	if err != nil {
+		t.Fatalf("ParsePacket() error = %v", err)
+	}
+	if strength, ok := parsed.SignalStrength(); !ok || strength != 200 {
+		t.Errorf("SignalStrength() = %d, %t, want 200, true", strength, ok)
+	}
+	if !bytes.Equal(parsed.Payload, packet.Payload) {
+		t.Errorf("ParsePacket() payload = %v, want %v", parsed.Payload, packet.Payload)
+	}
+
+	// Replacing the extension of a parsed packet must not overwrite its payload
+	var into VCSPacket
+	if err := ParsePacketInto(data, &into); err != nil {
+		t.Fatalf("ParsePacketInto() error = %v", err)
+	}
+	into.ClearExtension()
+	into.SetSignalStrength(10)
+	if !bytes.Equal(into.Payload, packet.Payload) {
+		t.Errorf("SetSignalStrength() changed the payload to %v", into.Payload)
+	}
+
+	packet.SetEncryptionKey(7)
+	if key, ok := packet.EncryptionKey(); !ok || key != 7 {
+		t.Errorf("EncryptionKey() = %d, %t, want 7, true", key, ok)
+	}
+	if strength, ok := packet.SignalStrength(); !ok || strength != 200 {
+		t.Errorf("SignalStrength() next to an encryption key = %d, %t, want 200, true", strength, ok)
+	}
+
+	packet.ClearExtension()
+	if _, ok := packet.SignalStrength(); ok || packet.Size() != HeaderSize+len(packet.Payload) {
+		t.Errorf("ClearExtension() left size %d with signal strength %t", packet.Size(), ok)
+	}
+
+	if err := ParsePacketInto(data[:HeaderSize+2], &into); err == nil {
+		t.Errorf("ParsePacketInto() with a truncated extension did not return an error")
+	}
+}
+
+func TestVerifyPacketTag(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	packet := NewVCSVoicePacket(uuid.New(), 1, 251000, []byte{0xF8, 0x01})
+	packet.SetAuthenticated(true)
+	data := SignPacket(packet.SerializePacket(), secret)
+
+	if !VerifyPacketTag(data, secret) {
+		t.Errorf("VerifyPacketTag() = false for a correctly signed packet")
+	}
+	if VerifyPacketTag(data, []byte("another secret")) {
+		t.Errorf("VerifyPacketTag() = true with the wrong secret")
+	}
+	data[HeaderSize] ^= 0xFF
+	if VerifyPacketTag(data, secret) {
+		t.Errorf("VerifyPacketTag() = true for a tampered payload")
+	}
+	if VerifyPacketTag(data[:HeaderSize], secret) {
+		t.Errorf("VerifyPacketTag() = true for a packet without tag")
+	}
+}
+
+func BenchmarkParsePacket(b *testing.B) {
+	data := NewVCSVoicePacket(uuid.New(), 1, 251000, make([]byte, 120)).SerializePacket()
+
+	b.Run("copy", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := ParsePacket(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("into", func(b *testing.B) {
+		b.ReportAllocs()
+		var packet VCSPacket
+		for b.Loop() {
+			if err := ParsePacketInto(data, &packet); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkSerializePacket(b *testing.B) {
+	packet := NewVCSVoicePacket(uuid.New(), 1, 251000, make([]byte, 120))
+
+	b.Run("alloc", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			packet.SerializePacket()
+		}
+	})
+	b.Run("into", func(b *testing.B) {
+		b.ReportAllocs()
+		buf := make([]byte, BufferSize)
+		for b.Loop() {
+			if _, err := packet.SerializeInto(buf); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
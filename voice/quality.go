@@ -0,0 +1,197 @@
+package voice
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/google/uuid"
+)
+
+const (
+	QualityInterval     = 2 * time.Second       // Interval the voice quality of all sessions is published with
+	defaultFrameLength  = 20 * time.Millisecond // Assumed length of a voice packet whose Opus frames cannot be parsed
+	jitterGain          = 16                    // RFC 3550 smoothing of the inter-arrival jitter
+	sequenceWindowWidth = 64                    // Sequences behind the highest one that are checked for duplicates
+)
+
+// streamQuality are the statistics of the voice of a single session on a single frequency, derived from the sequence numbers
+type streamQuality struct {
+	received   uint64
+	lost       uint64
+	reordered  uint64
+	duplicates uint64
+	jitter     float64 // Smoothed inter-arrival jitter in seconds
+
+	started     bool
+	highest     uint32 // Highest sequence received
+	seen        uint64 // Bit i is set if the sequence i behind highest was received
+	lastArrival time.Time
+}
+
+// record accounts a packet with the sequence that arrived at now. Sequences skipped by the packet are counted as lost
+// until they arrive late, and a restarted sequence starts over without counting the gap as loss.
+func (s *streamQuality) record(sequence uint32, frameLength time.Duration, now time.Time) {
+	s.received++
+	switch {
+	case !s.started || isSequenceRestart(s.highest, sequence):
+		s.started = true
+		s.highest = sequence
+		s.seen = 1
+	case isSequenceAhead(s.highest, sequence):
+		distance := (sequence - s.highest) & sequenceMask
+		s.lost += uint64(distance - 1)
+		if distance < sequenceWindowWidth {
+			s.seen = s.seen<<distance | 1
+		} else {
+			s.seen = 1
+		}
+		// Jitter is only measured within a transmission, the pause between two of them is not jitter
+		if now.Sub(s.lastArrival) <= TransmissionTimeout {
+			transit := now.Sub(s.lastArrival) - time.Duration(distance)*frameLength
+			s.jitter += (math.Abs(transit.Seconds()) - s.jitter) / jitterGain
+		}
+		s.highest = sequence
+	default:
+		behind := (s.highest - sequence) & sequenceMask
+		if behind < sequenceWindowWidth && s.seen&(1<<behind) != 0 {
+			s.duplicates++
+			return
+		}
+		if behind < sequenceWindowWidth {
+			s.seen |= 1 << behind
+		}
+		s.reordered++
+		if s.lost > 0 {
+			s.lost-- // Counted as lost when it was skipped
+		}
+		return
+	}
+	s.lastArrival = now
+}
+
+// qualityTracker holds the stream statistics of all sessions
+type qualityTracker struct {
+	mu      sync.Mutex
+	streams map[uuid.UUID]map[state.Frequency]*streamQuality // Streams of every session by channel
+	dirty   map[uuid.UUID]struct{}
+}
+
+func newQualityTracker() *qualityTracker {
+	return &qualityTracker{
+		streams: make(map[uuid.UUID]map[state.Frequency]*streamQuality),
+		dirty:   make(map[uuid.UUID]struct{}),
+	}
+}
+
+// record accounts the packet to the stream of the sender on the channel, frequencies of the same channel are one stream
+func (q *qualityTracker) record(packet *VCSPacket, channel state.Frequency, now time.Time) {
+	frameLength := defaultFrameLength
+	if samples, err := opusPacketSamples(packet.Payload); err == nil {
+		frameLength = time.Duration(samples) * time.Second / OpusSampleRate
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	streams, exists := q.streams[packet.SenderID]
+	if !exists {
+		streams = make(map[state.Frequency]*streamQuality)
+		q.streams[packet.SenderID] = streams
+	}
+	stream, exists := streams[channel]
+	if !exists {
+		stream = &streamQuality{}
+		streams[channel] = stream
+	}
+	stream.record(packet.Sequence, frameLength, now)
+	q.dirty[packet.SenderID] = struct{}{}
+}
+
+// remove drops the statistics of a session that left
+func (q *qualityTracker) remove(clientID uuid.UUID) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.streams, clientID)
+	delete(q.dirty, clientID)
+}
+
+// changed returns the statistics of all sessions that received voice since the last call
+func (q *qualityTracker) changed() map[uuid.UUID][]state.VoiceQuality {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	changed := make(map[uuid.UUID][]state.VoiceQuality, len(q.dirty))
+	for clientID := range q.dirty {
+		changed[clientID] = q.quality(clientID)
+	}
+	clear(q.dirty)
+	return changed
+}
+
+// all returns the statistics of all sessions
+func (q *qualityTracker) all() map[uuid.UUID][]state.VoiceQuality {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	all := make(map[uuid.UUID][]state.VoiceQuality, len(q.streams))
+	for clientID := range q.streams {
+		all[clientID] = q.quality(clientID)
+	}
+	return all
+}
+
+// quality has to be called with mu held
+func (q *qualityTracker) quality(clientID uuid.UUID) []state.VoiceQuality {
+	streams := q.streams[clientID]
+	quality := make([]state.VoiceQuality, 0, len(streams))
+	for frequency, stream := range streams {
+		quality = append(quality, state.VoiceQuality{
+			Frequency:  frequency,
+			Received:   stream.received,
+			Lost:       stream.lost,
+			Reordered:  stream.reordered,
+			Duplicates: stream.duplicates,
+			JitterMs:   stream.jitter * 1000,
+		})
+	}
+	slices.SortFunc(quality, func(a, b state.VoiceQuality) int {
+		return cmp.Compare(a.Frequency, b.Frequency)
+	})
+	return quality
+}
+
+// GetVoiceQuality returns the voice statistics of all sessions by frequency
+func (v *Server) GetVoiceQuality() map[uuid.UUID][]state.VoiceQuality {
+	return v.quality.all()
+}
+
+// publishQuality copies the statistics of sessions that received voice into their client state
+func (v *Server) publishQuality() {
+	changed := v.quality.changed()
+	if len(changed) == 0 {
+		return
+	}
+	for clientID, quality := range changed {
+		v.serverState.SetVoiceQuality(clientID, quality)
+	}
+	v.eventBus.Publish(events.Event{
+		Name: events.VoiceQualityChanged,
+		Data: changed,
+	})
+}
+
+func (v *Server) qualityRoutine() {
+	ticker := time.NewTicker(QualityInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-v.stopChan:
+			return
+		case <-ticker.C:
+			v.publishQuality()
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package voice
+
+import (
+	"io"
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/google/uuid"
+)
+
+func TestStreamQuality(t *testing.T) {
+	tests := []struct {
+		name      string
+		sequences []uint32
+		want      streamQuality
+	}{
+		{"in order", []uint32{1, 2, 3, 4}, streamQuality{received: 4}},
+		{"lost", []uint32{1, 2, 5, 6}, streamQuality{received: 4, lost: 2}},
+		{"reordered", []uint32{1, 3, 2, 4}, streamQuality{received: 4, reordered: 1}},
+		{"duplicate", []uint32{1, 2, 2, 3, 1}, streamQuality{received: 5, duplicates: 2}},
+		{"wrap around", []uint32{sequenceMask - 1, sequenceMask, 0, 2}, streamQuality{received: 4, lost: 1}},
+		{"restart", []uint32{500, 501, 1, 2}, streamQuality{received: 4}},
+		{"late beyond the window", []uint32{100, 170, 106}, streamQuality{received: 3, lost: 68, reordered: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got streamQuality
+			now := time.Now()
+			for i, sequence := range tt.sequences {
+				got.record(sequence, 20*time.Millisecond, now.Add(time.Duration(i)*20*time.Millisecond))
+			}
+			if got.received != tt.want.received || got.lost != tt.want.lost || got.reordered != tt.want.reordered || got.duplicates != tt.want.duplicates {
+				t.Errorf("received %d, lost %d, reordered %d, duplicates %d, want %d, %d, %d, %d",
+					got.received, got.lost, got.reordered, got.duplicates,
+					tt.want.received, tt.want.lost, tt.want.reordered, tt.want.duplicates)
+			}
+		})
+	}
+}
+
+func TestStreamQualityJitter(t *testing.T) {
+	var stream streamQuality
+	now := time.Now()
+	for sequence := range uint32(50) {
+		stream.record(sequence, 20*time.Millisecond, now.Add(time.Duration(sequence)*20*time.Millisecond))
+	}
+	if stream.jitter != 0 {
+		t.Errorf("jitter of evenly paced packets is %v, want 0", stream.jitter)
+	}
+
+	// Packets alternating 10 ms early and late converge to a jitter of 20 ms
+	for sequence := uint32(50); sequence < 500; sequence++ {
+		offset := 10 * time.Millisecond
+		if sequence%2 == 0 {
+			offset = -offset
+		}
+		stream.record(sequence, 20*time.Millisecond, now.Add(time.Duration(sequence)*20*time.Millisecond+offset))
+	}
+	if math.Abs(stream.jitter-0.020) > 0.001 {
+		t.Errorf("jitter is %v, want 20ms", stream.jitter)
+	}
+
+	// The pause between two transmissions is not jitter
+	before := stream.jitter
+	stream.record(500, 20*time.Millisecond, now.Add(time.Minute))
+	if stream.jitter != before {
+		t.Errorf("pause between transmissions changed the jitter from %v to %v", before, stream.jitter)
+	}
+}
+
+func TestPublishQuality(t *testing.T) {
+	serverState := &state.ServerState{}
+	eventBus := events.NewEventBus()
+	changed := eventBus.Subscribe(events.VoiceQualityChanged)
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, &state.SettingsState{}, eventBus)
+	client := uuid.New()
+	serverState.AddClient(client, &state.ClientState{Name: "Pilot", Coalition: "blue"})
+
+	now := time.Now()
+	for _, sequence := range []uint32{1, 2, 4} {
+		server.quality.record(NewVCSVoicePacket(client, sequence, 251000, []byte{31 << 3}), 251*state.MHz, now)
+	}
+	server.quality.record(NewVCSVoicePacket(client, 1, 243000, []byte{31 << 3}), 243*state.MHz, now)
+	server.publishQuality()
+
+	stored, _ := serverState.GetClient(client)
+	want := []state.VoiceQuality{
+		{Frequency: 243 * state.MHz, Received: 1},
+		{Frequency: 251 * state.MHz, Received: 3, Lost: 1},
+	}
+	if len(stored.VoiceQuality) != len(want) {
+		t.Fatalf("VoiceQuality = %+v, want %+v", stored.VoiceQuality, want)
+	}
+	for i := range want {
+		if got := stored.VoiceQuality[i]; got.Frequency != want[i].Frequency || got.Received != want[i].Received || got.Lost != want[i].Lost {
+			t.Errorf("VoiceQuality[%d] = %+v, want %+v", i, got, want[i])
+		}
+	}
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Errorf("no quality change was published")
+	}
+
+	status, _ := server.buildServerStatus()
+	if len(status.SessionQuality) != 2 {
+		t.Errorf("heartbeat reports %d session qualities, want 2", len(status.SessionQuality))
+	}
+
+	server.quality.remove(client)
+	if len(server.GetVoiceQuality()) != 0 {
+		t.Errorf("quality of a removed session is still tracked")
+	}
+}
@@ -0,0 +1,257 @@
+package voice
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/google/uuid"
+)
+
+const (
+	RecordingQueueSize = 256 // Voice frames waiting to be written, further frames are dropped
+
+	defaultRecordingDirectory = "recordings"
+	recordingCheckInterval    = time.Second
+	maxSilenceFrames          = 50 // Larger gaps in the sequence of a sender are not filled with silence
+)
+
+// recordedFrame is a copy of the Opus payload of a voice packet on a recorded frequency
+type recordedFrame struct {
+	frequency state.Frequency
+	senderID  uuid.UUID
+	sequence  uint32
+	payload   []byte
+}
+
+// recordedSender keeps track of the sequence of a sender to fill missed frames with silence
+type recordedSender struct {
+	lastSequence uint32
+	lastSamples  int
+}
+
+// recording is the recording session of a single frequency, split into numbered Ogg/Opus files
+type recording struct {
+	frequency state.Frequency
+	started   time.Time
+	part      int
+	file      *os.File
+	ogg       *oggOpusWriter
+	opened    time.Time
+	senders   map[uuid.UUID]*recordedSender
+}
+
+// Recorder writes the voice of recorded frequencies to one Ogg/Opus file per frequency and recording session
+type Recorder struct {
+	settingsState *state.SettingsState
+	logger        *slog.Logger
+	frames        chan recordedFrame
+	recordings    map[state.Frequency]*recording // Only used by Run
+}
+
+func NewRecorder(settingsState *state.SettingsState, logger *slog.Logger) *Recorder {
+	return &Recorder{
+		settingsState: settingsState,
+		logger:        logger,
+		frames:        make(chan recordedFrame, RecordingQueueSize),
+		recordings:    make(map[state.Frequency]*recording),
+	}
+}
+
+// Record queues the voice of the packet if its frequency is recorded. Frequencies of the same channel are recorded into
+// the same file. The payload is copied, so the packet may be reused.
+func (r *Recorder) Record(packet *VCSPacket) {
+	frequency := r.settingsState.GetChannelPlan().Channel(packet.RadioFrequency())
+	if len(packet.Payload) == 0 || !r.settingsState.IsFrequencyRecorded(frequency) {
+		return
+	}
+	frame := recordedFrame{
+		frequency: frequency,
+		senderID:  packet.SenderID,
+		sequence:  packet.Sequence,
+		payload:   bytes.Clone(packet.Payload),
+	}
+	select {
+	case r.frames <- frame:
+	default:
+		r.logger.Warn("Recording queue is full, dropping voice frame", "frequency", frequency)
+	}
+}
+
+// Run writes queued frames until stopChan is closed and closes all recordings afterwards
+func (r *Recorder) Run(stopChan <-chan struct{}) {
+	ticker := time.NewTicker(recordingCheckInterval)
+	defer ticker.Stop()
+	defer r.closeAll()
+
+	for {
+		select {
+		case <-stopChan:
+			for {
+				select {
+				case frame := <-r.frames:
+					r.write(frame, time.Now())
+				default:
+					return
+				}
+			}
+		case frame := <-r.frames:
+			r.write(frame, time.Now())
+		case <-ticker.C:
+			r.closeStopped()
+		}
+	}
+}
+
+func (r *Recorder) write(frame recordedFrame, now time.Time) {
+	samples, err := opusPacketSamples(frame.payload)
+	if err != nil {
+		r.logger.Warn("Dropping invalid voice frame from recording", "frequency", frame.frequency, "sender_id", frame.senderID, "error", err)
+		return
+	}
+
+	rec, err := r.recordingFor(frame.frequency, now)
+	if err != nil {
+		r.logger.Error("Failed to open recording", "frequency", frame.frequency, "error", err)
+		return
+	}
+
+	sender, exists := rec.senders[frame.senderID]
+	if !exists {
+		sender = &recordedSender{}
+		rec.senders[frame.senderID] = sender
+	} else {
+		switch {
+		case isSequenceAhead(sender.lastSequence, frame.sequence):
+			missed := int((frame.sequence - sender.lastSequence - 1) & sequenceMask)
+			if missed <= maxSilenceFrames {
+				for _, silence := range opusSilence(missed * sender.lastSamples) {
+					if err := rec.ogg.WritePacket(silence, opusFrameSamples(silence[0]>>3)); err != nil {
+						r.fail(rec, err)
+						return
+					}
+				}
+			}
+		case !isSequenceRestart(sender.lastSequence, frame.sequence):
+			return // Late or duplicate frame, its time was already filled with silence
+		}
+	}
+	sender.lastSequence = frame.sequence
+	sender.lastSamples = samples
+
+	if err := rec.ogg.WritePacket(frame.payload, samples); err != nil {
+		r.fail(rec, err)
+	}
+}
+
+// recordingFor returns the recording of the frequency, starting a new one or splitting it into a new file if needed
+func (r *Recorder) recordingFor(frequency state.Frequency, now time.Time) (*recording, error) {
+	settings := r.settingsState.GetRecordingSettings()
+	rec, exists := r.recordings[frequency]
+	if !exists {
+		rec = &recording{
+			frequency: frequency,
+			started:   now,
+			senders:   make(map[uuid.UUID]*recordedSender),
+		}
+		r.recordings[frequency] = rec
+		r.logger.Info("Started recording", "frequency", frequency)
+	} else if rec.exceeds(settings, now) {
+		r.closeFile(rec)
+		rec.part++
+	}
+
+	if rec.file == nil {
+		if err := rec.open(settings.Directory, now); err != nil {
+			delete(r.recordings, frequency)
+			return nil, err
+		}
+	}
+	return rec, nil
+}
+
+// closeStopped ends the recordings of all frequencies that are no longer recorded
+func (r *Recorder) closeStopped() {
+	for frequency, rec := range r.recordings {
+		if r.settingsState.IsFrequencyRecorded(frequency) {
+			continue
+		}
+		r.closeFile(rec)
+		delete(r.recordings, frequency)
+		r.logger.Info("Stopped recording", "frequency", frequency)
+	}
+}
+
+func (r *Recorder) closeAll() {
+	for frequency, rec := range r.recordings {
+		r.closeFile(rec)
+		delete(r.recordings, frequency)
+	}
+}
+
+func (r *Recorder) closeFile(rec *recording) {
+	if err := rec.close(); err != nil {
+		r.logger.Error("Failed to close recording", "frequency", rec.frequency, "error", err)
+	}
+}
+
+// fail ends a recording that could not be written, the next frame starts a new one
+func (r *Recorder) fail(rec *recording, err error) {
+	r.logger.Error("Failed to write recording", "frequency", rec.frequency, "error", err)
+	_ = rec.close()
+	delete(r.recordings, rec.frequency)
+}
+
+func (rec *recording) exceeds(settings state.RecordingSettings, now time.Time) bool {
+	if rec.file == nil {
+		return false
+	}
+	if settings.MaxFileSize > 0 && rec.ogg.Size() >= settings.MaxFileSize {
+		return true
+	}
+	return settings.MaxDuration > 0 && now.Sub(rec.opened) >= time.Duration(settings.MaxDuration)*time.Second
+}
+
+func (rec *recording) open(directory string, now time.Time) error {
+	if directory == "" {
+		directory = defaultRecordingDirectory
+	}
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%07.3f-%s-%03d.opus", rec.frequency.MHz(), rec.started.UTC().Format("20060102-150405"), rec.part)
+	file, err := os.OpenFile(filepath.Join(directory, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	ogg, err := newOggOpusWriter(file, rand.Uint32(),
+		fmt.Sprintf("FREQUENCY=%.3f", rec.frequency.MHz()),
+		"DATE="+now.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	rec.file = file
+	rec.ogg = ogg
+	rec.opened = now
+	return nil
+}
+
+func (rec *recording) close() error {
+	if rec.file == nil {
+		return nil
+	}
+	err := rec.ogg.Close()
+	if closeErr := rec.file.Close(); err == nil {
+		err = closeErr
+	}
+	rec.file = nil
+	rec.ogg = nil
+	return err
+}
@@ -0,0 +1,230 @@
+package voice
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/google/uuid"
+)
+
+// oggPage is a page read back from a written stream
+type oggPage struct {
+	flags    byte
+	granule  uint64
+	sequence uint32
+	packet   []byte
+}
+
+// readOggPages parses a stream of single-packet pages and verifies their checksums
+func readOggPages(t *testing.T, data []byte) []oggPage {
+	t.Helper()
+	var pages []oggPage
+	for len(data) > 0 {
+		if len(data) < oggPageHeaderSize || string(data[:4]) != "OggS" {
+			t.Fatalf("invalid page header at page %d", len(pages))
+		}
+		segments := int(data[26])
+		size := 0
+		for _, lacing := range data[oggPageHeaderSize : oggPageHeaderSize+segments] {
+			size += int(lacing)
+		}
+		pageSize := oggPageHeaderSize + segments + size
+		page := bytes.Clone(data[:pageSize])
+		checksum := binary.LittleEndian.Uint32(page[22:])
+		binary.LittleEndian.PutUint32(page[22:], 0)
+		if oggChecksum(page) != checksum {
+			t.Fatalf("checksum mismatch on page %d", len(pages))
+		}
+		pages = append(pages, oggPage{
+			flags:    data[5],
+			granule:  binary.LittleEndian.Uint64(data[6:]),
+			sequence: binary.LittleEndian.Uint32(data[18:]),
+			packet:   page[oggPageHeaderSize+segments:],
+		})
+		data = data[pageSize:]
+	}
+	return pages
+}
+
+func TestOggChecksum(t *testing.T) {
+	// CRC-32 with polynomial 0x04c11db7, no reflection, initial value and final xor of 0
+	if got := oggChecksum([]byte("123456789")); got != 0x89A1897F {
+		t.Errorf("expected 0x89A1897F, got %#x", got)
+	}
+}
+
+func TestOggOpusWriter(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := newOggOpusWriter(&buf, 1234, "FREQUENCY=251.000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	large := bytes.Repeat([]byte{0xFC}, 600) // Needs multiple lacing values
+	packets := [][]byte{{0xFC, 1, 2}, large, {0xFC, 3}}
+	for _, packet := range packets {
+		if err := writer.WritePacket(packet, 960); err != nil {
+			t.Fatal(err)
+		}
+	}
+	size := writer.Size()
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(buf.Len()) {
+		t.Errorf("expected size %d before closing, got %d", buf.Len(), size)
+	}
+
+	pages := readOggPages(t, buf.Bytes())
+	if len(pages) != 2+len(packets) {
+		t.Fatalf("expected %d pages, got %d", 2+len(packets), len(pages))
+	}
+	if pages[0].flags != oggFlagBOS || string(pages[0].packet[:8]) != "OpusHead" {
+		t.Errorf("first page is not the OpusHead BOS page")
+	}
+	if !bytes.Contains(pages[1].packet, []byte("FREQUENCY=251.000")) {
+		t.Errorf("OpusTags are missing the comment")
+	}
+	for i, packet := range packets {
+		page := pages[2+i]
+		if page.sequence != uint32(2+i) {
+			t.Errorf("page %d: expected sequence %d, got %d", i, 2+i, page.sequence)
+		}
+		if page.granule != uint64(960*(i+1)) {
+			t.Errorf("page %d: expected granule %d, got %d", i, 960*(i+1), page.granule)
+		}
+		if !bytes.Equal(page.packet, packet) {
+			t.Errorf("page %d: packet mismatch", i)
+		}
+	}
+	if pages[len(pages)-1].flags != oggFlagEOS {
+		t.Errorf("last page is not flagged EOS")
+	}
+}
+
+func TestOpusPacketSamples(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet []byte
+		want   int
+	}{
+		{"silk 20ms", []byte{1 << 3}, 960},
+		{"silk 60ms", []byte{3 << 3}, 2880},
+		{"hybrid 10ms", []byte{12 << 3}, 480},
+		{"celt 2.5ms", []byte{28 << 3}, 120},
+		{"celt 20ms two frames", []byte{31<<3 | 1}, 1920},
+		{"celt 20ms three frames", []byte{31<<3 | 3, 3}, 2880},
+		{"empty", nil, 0},
+		{"code 3 without count", []byte{31<<3 | 3}, 0},
+		{"longer than 120ms", []byte{3<<3 | 3, 3}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := opusPacketSamples(tt.packet)
+			if got != tt.want || (err == nil) != (tt.want != 0) {
+				t.Errorf("expected %d samples, got %d (error %v)", tt.want, got, err)
+			}
+		})
+	}
+
+	samples := 0
+	for _, silence := range opusSilence(2880 + 360) {
+		n, err := opusPacketSamples(silence)
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples += n
+	}
+	if samples != 2880+360 {
+		t.Errorf("expected silence of %d samples, got %d", 2880+360, samples)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	directory := t.TempDir()
+	settingsState := &state.SettingsState{Recording: state.RecordingSettings{
+		Directory:   directory,
+		Frequencies: []state.Frequency{251 * state.MHz},
+		MaxDuration: 60,
+	}}
+	recorder := NewRecorder(settingsState, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	sender := uuid.New()
+	start := time.Now()
+	frame := func(sequence uint32, at time.Duration) {
+		recorder.write(recordedFrame{
+			frequency: 251 * state.MHz,
+			senderID:  sender,
+			sequence:  sequence,
+			payload:   []byte{31 << 3, 0x01, 0x02}, // 20 ms
+		}, start.Add(at))
+	}
+
+	frame(1, 0)
+	frame(2, 20*time.Millisecond)
+	frame(5, 80*time.Millisecond)  // Two missed frames are filled with silence
+	frame(4, 100*time.Millisecond) // Late frame is dropped
+	frame(6, 61*time.Second)       // Split into a new file
+	recorder.closeAll()
+
+	files, err := filepath.Glob(filepath.Join(directory, "*.opus"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(files))
+	}
+
+	first, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := readOggPages(t, first)[2:]
+	if len(pages) != 5 {
+		t.Fatalf("expected 5 audio pages, got %d", len(pages))
+	}
+	for i, page := range pages {
+		if page.granule != uint64(960*(i+1)) {
+			t.Errorf("page %d: expected granule %d, got %d", i, 960*(i+1), page.granule)
+		}
+	}
+	if !bytes.Equal(pages[2].packet, []byte{31 << 3, 0xFF, 0xFE}) || !bytes.Equal(pages[3].packet, []byte{31 << 3, 0xFF, 0xFE}) {
+		t.Errorf("missed frames were not filled with silence")
+	}
+
+	second, err := os.ReadFile(files[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages := readOggPages(t, second)[2:]; len(pages) != 1 || pages[0].granule != 960 {
+		t.Errorf("expected the split file to start over with a single frame, got %+v", pages)
+	}
+}
+
+func TestRecorderStopsRecording(t *testing.T) {
+	directory := t.TempDir()
+	settingsState := &state.SettingsState{Recording: state.RecordingSettings{
+		Directory:   directory,
+		Frequencies: []state.Frequency{251 * state.MHz},
+	}}
+	recorder := NewRecorder(settingsState, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	packet := NewVCSVoicePacket(uuid.New(), 1, 251000, []byte{31 << 3, 0x01})
+	recorder.Record(packet)
+	recorder.Record(NewVCSVoicePacket(uuid.New(), 1, 305000, []byte{31 << 3, 0x01}))
+	if len(recorder.frames) != 1 {
+		t.Fatalf("expected only the recorded frequency to be queued, got %d frames", len(recorder.frames))
+	}
+	recorder.write(<-recorder.frames, time.Now())
+
+	settingsState.Recording.Frequencies = nil
+	recorder.closeStopped()
+	if len(recorder.recordings) != 0 {
+		t.Errorf("expected the recording to be stopped")
+	}
+}
@@ -0,0 +1,87 @@
+package voice
+
+import (
+	"slices"
+
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/google/uuid"
+)
+
+// relayHop is a frequency a transmission reached, with the receivers on it and the relays it passed to get there
+type relayHop struct {
+	frequency  state.Frequency
+	receptions []reception
+	hops       uint8
+}
+
+// relayTarget is a frequency a transmission is retransmitted onto by a retransmission node, or a static relay if the
+// node is uuid.Nil
+type relayTarget struct {
+	frequency state.Frequency
+	node      uuid.UUID
+}
+
+// relayVoice retransmits the packet from its frequency onto the frequencies the static relays and the retransmission
+// nodes among its receivers connect it to, hop by hop up to the node limit. Every frequency is sent to once, so loops
+// end where they close. Retransmitted packets keep the sender, so receivers show the original talker, and carry the
+// hop counter and the origin frequency in the header extension.
+func (v *Server) relayVoice(packet *VCSPacket, senderID uuid.UUID, receptions []reception, hops uint8) {
+	settings := v.settingsState.GetRetransmissionSettings()
+	if settings.NodeLimit <= 0 || int(hops) >= settings.NodeLimit {
+		return
+	}
+	plan := v.settingsState.GetChannelPlan()
+	origin := packet.Frequency
+	base := len(packet.Extension)
+	defer func() {
+		packet.Frequency = origin
+		packet.truncateExtension(base)
+	}()
+
+	visited := []state.Frequency{packet.RadioFrequency()}
+	queue := []relayHop{{frequency: packet.RadioFrequency(), receptions: receptions, hops: hops}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if int(current.hops) >= settings.NodeLimit {
+			continue
+		}
+		for _, target := range v.relayTargets(current, settings.Relays, plan) {
+			if slices.ContainsFunc(visited, func(frequency state.Frequency) bool { return plan.Matches(frequency, target.frequency) }) {
+				v.logger.Debug("Dropped relay loop", "sender_id", senderID, "from", current.frequency.String(), "to", target.frequency.String())
+				continue
+			}
+			visited = append(visited, target.frequency)
+
+			// Static relays have no position, so everybody hears them with propagation enabled
+			packet.Frequency = uint32(target.frequency / state.KHz)
+			packet.truncateExtension(base)
+			packet.SetHops(current.hops + 1)
+			packet.SetOriginFrequency(origin)
+			next := relayHop{frequency: target.frequency, receptions: v.receptions(packet, senderID, target.node), hops: current.hops + 1}
+			v.sendVoice(packet, senderID, next.receptions)
+			queue = append(queue, next)
+		}
+	}
+}
+
+// relayTargets returns the frequencies the static relays and the retransmission nodes among the receivers of the hop
+// retransmit it onto. Spectators only listen, so they are never retransmission nodes, and nodes tuned with another
+// encryption key only hear noise, which they do not pass on.
+func (v *Server) relayTargets(hop relayHop, relays []state.Relay, plan state.ChannelPlan) []relayTarget {
+	var targets []relayTarget
+	for _, relay := range relays {
+		if plan.Matches(relay.From, hop.frequency) {
+			targets = append(targets, relayTarget{frequency: relay.To})
+		}
+	}
+	for _, reception := range hop.receptions {
+		if reception.garbled || v.serverState.IsSpectator(reception.clientID) {
+			continue
+		}
+		for _, frequency := range v.serverState.GetRetransmitFrequencies(reception.clientID, hop.frequency, plan) {
+			targets = append(targets, relayTarget{frequency: frequency, node: reception.clientID})
+		}
+	}
+	return targets
+}
@@ -0,0 +1,116 @@
+package voice
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/google/uuid"
+)
+
+func TestRelayVoice(t *testing.T) {
+	serverState := &state.ServerState{}
+	settingsState := &state.SettingsState{Retransmission: state.RetransmissionSettings{
+		NodeLimit: 2,
+		Relays: []state.Relay{
+			{Name: "Tower", From: 251 * state.MHz, To: 252 * state.MHz},
+			{Name: "Back", From: 252 * state.MHz, To: 251 * state.MHz},
+		},
+	}}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, settingsState, events.NewEventBus())
+	attachLoopback(t, server)
+
+	conns := make(map[string]*net.UDPConn)
+	addClient := func(name string, radios ...state.Radio) uuid.UUID {
+		id := uuid.New()
+		serverState.AddClient(id, &state.ClientState{Name: name, Coalition: "blue"})
+		serverState.SetRadioState(id, &state.RadioState{Radios: radios})
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("ListenUDP() error = %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		conns[name] = conn
+		server.clients[id] = &Client{Addr: conn.LocalAddr().(*net.UDPAddr), LastSeen: time.Now()}
+		return id
+	}
+	sender := addClient("sender", state.Radio{ID: 1, Frequency: 251 * state.MHz, Enabled: true})
+	addClient("tower", state.Radio{ID: 1, Frequency: 252 * state.MHz, Enabled: true})
+	addClient("node",
+		state.Radio{ID: 1, Frequency: 252 * state.MHz, Enabled: true, Retransmit: true},
+		state.Radio{ID: 2, Frequency: 253 * state.MHz, Enabled: true, Retransmit: true},
+	)
+	addClient("beyond node", state.Radio{ID: 1, Frequency: 253 * state.MHz, Enabled: true})
+
+	receive := func(name string) (*VCSPacket, bool) {
+		buf := make([]byte, BufferSize)
+		_ = conns[name].SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, err := conns[name].Read(buf)
+		if err != nil {
+			return nil, false
+		}
+		packet, err := ParsePacket(buf[:n])
+		if err != nil {
+			t.Fatalf("ParsePacket() error = %v", err)
+		}
+		return packet, true
+	}
+	drain := func() {
+		for name := range conns {
+			for {
+				if _, ok := receive(name); !ok {
+					break
+				}
+			}
+		}
+	}
+	payload := []byte{0xF8, 0x01, 0x02, 0x03}
+	transmit := func() {
+		server.broadcastVoice(NewVCSVoicePacket(sender, 1, 251000, bytes.Clone(payload)), sender)
+	}
+
+	transmit()
+	if got, ok := receive("tower"); !ok || got.SenderID != sender || got.Frequency != 252000 || !bytes.Equal(got.Payload, payload) {
+		t.Errorf("listener behind the static relay got %v, want the voice of the sender on 252 MHz", got)
+	} else {
+		if hops, ok := got.Hops(); !ok || hops != 1 {
+			t.Errorf("listener behind the static relay got %d hops, %t, want 1", hops, ok)
+		}
+		if origin, ok := got.OriginFrequency(); !ok || origin != 251000 {
+			t.Errorf("listener behind the static relay got origin %d, %t, want 251000", origin, ok)
+		}
+	}
+	if got, ok := receive("beyond node"); !ok || got.SenderID != sender || got.Frequency != 253000 {
+		t.Errorf("listener behind the retransmission node got %v, want the voice of the sender on 253 MHz", got)
+	} else if hops, ok := got.Hops(); !ok || hops != 2 {
+		t.Errorf("listener behind the retransmission node got %d hops, %t, want 2", hops, ok)
+	}
+	// The Back relay closes the loop to 251 MHz, which must not be sent to again
+	if got, ok := receive("sender"); ok {
+		t.Errorf("sender got %v, want the relay loop dropped", got)
+	}
+	drain()
+
+	settingsState.Retransmission.NodeLimit = 1
+	transmit()
+	if _, ok := receive("tower"); !ok {
+		t.Errorf("listener behind the static relay did not hear the transmission with a node limit of 1")
+	}
+	if got, ok := receive("beyond node"); ok {
+		t.Errorf("listener two relays away got %v with a node limit of 1", got)
+	}
+	drain()
+
+	settingsState.Retransmission.NodeLimit = 0
+	transmit()
+	for _, name := range []string{"tower", "beyond node"} {
+		if got, ok := receive(name); ok {
+			t.Errorf("listener %s got %v with retransmission disabled", name, got)
+		}
+	}
+}
@@ -1,39 +1,42 @@
 package voice
 
 import (
+	"crypto/cipher"
 	_ "encoding/binary"
-	"fmt"
-	"io"
+	"errors"
 	"log/slog"
 	"net"
+	"net/netip"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/FPGSchiba/vcs-srs-server/events"
 	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/FPGSchiba/vcs-srs-server/transmissions"
 	"github.com/FPGSchiba/vcs-srs-server/voiceontrol"
-	"github.com/faiface/beep"
-	"github.com/faiface/beep/speaker"
 	"github.com/google/uuid"
-	"github.com/pion/opus"
 )
 
 const (
-	BufferSize        = 1024                  // UDP buffer size
-	JitterBufferSize  = 10                    // Number of packets to buffer
-	PlayoutDelay      = 60 * time.Millisecond // Initial playout delay
-	minCELTConfig     = 16
-	maxOpusFrameSize  = 11520
-	samplesPerChannel = 960 // 20ms at 48kHz
+	BufferSize       = 1024                  // UDP buffer size
+	JitterBufferSize = 10                    // Number of packets to buffer
+	PlayoutDelay     = 60 * time.Millisecond // Initial playout delay
 )
 
 type Client struct {
-	Addr     *net.UDPAddr
-	LastSeen time.Time
+	Addr          *net.UDPAddr // Address the session is bound to by its HELLO
+	LastSeen      time.Time
+	Authenticated bool // Session proved it holds the voice secret, so all of its packets have to carry a tag
+
+	aead cipher.AEAD // Session cipher derived on HELLO, nil if the client does not encrypt
 }
 
 type Server struct {
 	sync.RWMutex
 	conn              *net.UDPConn
+	batchConn         batchWriter
 	clients           map[uuid.UUID]*Client
 	serverState       *state.ServerState
 	settingsState     *state.SettingsState
@@ -43,21 +46,37 @@ type Server struct {
 	stopChan          chan struct{}
 	controlClient     *voiceontrol.VoiceControlClient
 	serverId          string
-
-	// Playback/decoder state
-	playOnce   sync.Once
-	opusDec    opus.Decoder
-	pipeW      *io.PipeWriter
-	playFormat beep.Format
-	playErr    error
-
-	// Optional: gate concurrent writes to the pipe if PlayVoiceData is called from multiple goroutines
-	playMu sync.Mutex
+	eventBus          *events.EventBus
+
+	transmissionsMu sync.Mutex
+	transmissions   map[uuid.UUID]*activeTransmission
+	cutOffs         map[uuid.UUID][]time.Time // Times the transmissions of a client were cut off within the auto-mute window
+	recorder        *Recorder
+	transmissionLog atomic.Pointer[transmissions.Log] // Finished transmissions are added to it, nil if there is none
+	rejections      transmitRejections
+	flood           *floodGuard
+	quality         *qualityTracker
+	arbiter         *transmissionArbiter
+
+	echoMu   sync.Mutex
+	echoes   map[uuid.UUID]*echoRecording
+	playback *localPlayback // Plays test frequencies on the speakers of the server, only in the GUI
+
+	propagation PropagationModel // Replaces the FreeSpaceModel of the settings, see SetPropagationModel
 }
 
-func NewServer(state *state.ServerState, logger *slog.Logger, distributionState *state.DistributionState, settingsState *state.SettingsState) *Server {
+func NewServer(state *state.ServerState, logger *slog.Logger, distributionState *state.DistributionState, settingsState *state.SettingsState, eventBus *events.EventBus) *Server {
 	return &Server{
 		clients:           make(map[uuid.UUID]*Client),
+		transmissions:     make(map[uuid.UUID]*activeTransmission),
+		cutOffs:           make(map[uuid.UUID][]time.Time),
+		recorder:          NewRecorder(settingsState, logger),
+		flood:             newFloodGuard(),
+		quality:           newQualityTracker(),
+		arbiter:           newTransmissionArbiter(),
+		echoes:            make(map[uuid.UUID]*echoRecording),
+		playback:          newLocalPlayback(logger),
+		eventBus:          eventBus,
 		serverState:       state,
 		logger:            logger,
 		settingsState:     settingsState,
@@ -96,6 +115,7 @@ func (v *Server) Listen(address string, stopChan chan struct{}) error {
 
 	v.Lock()
 	v.conn = conn
+	v.batchConn = newBatchWriter(conn)
 	v.running = true
 	v.Unlock()
 
@@ -103,9 +123,23 @@ func (v *Server) Listen(address string, stopChan chan struct{}) error {
 
 	// Start the cleanup routine
 	go v.cleanupRoutine()
+	go v.transmissionRoutine()
+	go v.recorder.Run(v.stopChan)
+	go v.floodRoutine()
+	go v.qualityRoutine()
+	if v.controlClient != nil {
+		go v.heartbeatRoutine()
+	}
+
+	// Packets are handled by a fixed set of workers, each received into its own pooled buffer
+	queue := make(chan *inboundPacket, PacketQueueSize)
+	workers := v.startWorkers(queue)
+	defer func() {
+		close(queue)
+		workers.Wait()
+	}()
 
 	// Main receive loop
-	buffer := make([]byte, BufferSize)
 	for {
 		select {
 		case <-stopChan:
@@ -117,66 +151,131 @@ func (v *Server) Listen(address string, stopChan chan struct{}) error {
 			if err != nil {
 				return err
 			}
-			n, remoteAddr, err := v.conn.ReadFromUDP(buffer)
+			in := inboundPool.Get().(*inboundPacket)
+			n, remoteAddr, err := v.conn.ReadFromUDPAddrPort(in.buf[:])
 
 			if err != nil {
+				inboundPool.Put(in)
 				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 					continue
 				}
 				v.logger.Error("Error reading UDP", "error", err)
 				continue
 			}
+			in.n = n
+			in.addr = netip.AddrPortFrom(remoteAddr.Addr().Unmap(), remoteAddr.Port())
+			if !v.admitAddress(in.addr.Addr(), time.Now()) {
+				inboundPool.Put(in)
+				continue
+			}
 
-			// Handle the received packet
-			go v.handlePacket(buffer[:n], remoteAddr)
+			// Hand the packet to a worker, voice is real-time so drop it if all workers are busy
+			select {
+			case queue <- in:
+			default:
+				inboundPool.Put(in)
+				v.logger.Warn("Voice packet queue full, dropping packet", "addr", remoteAddr.String())
+			}
 		}
 	}
 }
 
-func (v *Server) handlePacket(data []byte, addr *net.UDPAddr) {
+// handlePacket handles a single received packet. The packet and its payload are only valid until it returns.
+func (v *Server) handlePacket(in *inboundPacket) {
 	if !v.isRunning() {
 		v.logger.Warn("Voice server is not running, ignoring packet")
 		return
 	}
 
-	packet, err := ParsePacket(data)
-	if err != nil {
-		v.logger.Error("Failed to parse voice packet", "error", err)
-		// Optionally send an error response back to the client
+	packet := &in.packet
+	now := time.Now()
+	if err := ParsePacketInto(in.buf[:in.n], packet); err != nil {
+		v.reportParseError(in.addr, err, now)
+		return
+	}
+	if !v.admitPacket(packet, in.addr, now) {
 		return
 	}
 
+	authenticated := false
+	if packet.IsAuthenticated() {
+		secret, exists := v.serverState.GetVoiceSecret(packet.SenderID)
+		// The header extension counts towards the length VerifyPacketTag checks, so the payload may still be too short
+		if !exists || len(packet.Payload) < TagSize || !VerifyPacketTag(in.buf[:in.n], secret) {
+			v.logger.Warn("Dropping packet with invalid authentication tag", "sender_id", packet.SenderID, "addr", in.addr.String())
+			return
+		}
+		// The tag is not forwarded, receivers do not know the secret of the sender
+		packet.Payload = packet.Payload[:len(packet.Payload)-TagSize]
+		packet.SetAuthenticated(false)
+		authenticated = true
+	}
+
+	if packet.Type == PacketTypeVoice {
+		if packet.IsEncrypted() {
+			if !v.openVoicePacket(packet, in.buf[:packet.HeaderLength()]) {
+				return
+			}
+			authenticated = true // Only the session holder can seal with the session key
+		} else if v.settingsState.IsVoiceEncryptionRequired() {
+			v.logger.Warn("Dropping unencrypted voice packet", "sender_id", packet.SenderID)
+			return
+		}
+	}
+
 	switch packet.Type {
 	case PacketTypeHello:
-		v.handleHelloPacket(packet, addr)
+		v.handleHelloPacket(packet, in.addr, authenticated)
 	case PacketTypeVoice:
-		v.handleVoicePacket(packet)
+		v.handleVoicePacket(packet, in.addr, authenticated)
 	case PacketTypeBye:
-		v.handleGoodbyePacket(packet)
+		v.handleGoodbyePacket(packet, in.addr, authenticated)
 	case PacketTypeKeepalive:
-		v.handleKeepalivePacket(packet, addr)
+		v.handleKeepalivePacket(packet, in.addr, authenticated)
 	default:
 		v.logger.Warn("Unknown packet type received", "type", packet.Type)
 	}
 }
 
-func (v *Server) handleHelloPacket(packet *VCSPacket, addr *net.UDPAddr) {
-	v.logger.Info("Received hello packet", "sender_id", packet.SenderID, "addr", addr.String())
+// handleHelloPacket binds the session to the address the HELLO was sent from.
+// A session that is already bound can only move to a new address (NAT rebinding) with an authenticated HELLO.
+func (v *Server) handleHelloPacket(packet *VCSPacket, addr netip.AddrPort, authenticated bool) {
+	v.logger.Info("Received hello packet", "sender_id", packet.SenderID, "addr", addr.String(), "authenticated", authenticated)
 	if !v.serverState.DoesClientExist(packet.SenderID) {
 		v.logger.Warn("Client with hello, that does not exist", "sender_id", packet.SenderID)
 		// Ignore hello from unknown client
 		return
 	}
 
+	aead, err := v.sessionCipher(packet)
+	if err != nil {
+		v.logger.Warn("Rejected hello without usable voice encryption", "sender_id", packet.SenderID, "error", err)
+		return
+	}
+
 	v.Lock()
+	previous, bound := v.clients[packet.SenderID]
+	rebinding := bound && previous.Addr.AddrPort() != addr
+	if bound && (rebinding || previous.Authenticated) && !authenticated {
+		v.Unlock()
+		v.logger.Warn("Rejected unauthenticated hello for bound session", "sender_id", packet.SenderID, "addr", addr.String())
+		return
+	}
 	v.clients[packet.SenderID] = &Client{
-		Addr:     addr,
-		LastSeen: time.Now(),
+		Addr:          net.UDPAddrFromAddrPort(addr),
+		LastSeen:      time.Now(),
+		Authenticated: authenticated,
+		aead:          aead,
 	}
 	v.Unlock()
+	if rebinding {
+		v.logger.Info("Voice client moved to new address", "sender_id", packet.SenderID, "from", previous.Addr.String(), "to", addr.String())
+	}
+
 	ackPacket := NewVCSHelloAckPacket(packet.SenderID)
+	ackPacket.SetEncrypted(aead != nil) // Confirms the new session key is in use
 	ackData := ackPacket.SerializePacket()
-	_, err := v.conn.WriteToUDP(ackData, addr)
+	_, err = v.conn.WriteToUDPAddrPort(ackData, addr)
 	if err != nil {
 		v.logger.Error("Failed to send hello acknowledgment",
 			"to", addr.String(),
@@ -185,12 +284,65 @@ func (v *Server) handleHelloPacket(packet *VCSPacket, addr *net.UDPAddr) {
 	}
 }
 
-func (v *Server) handleKeepalivePacket(packet *VCSPacket, addr *net.UDPAddr) {
+// sessionCipher derives the session cipher from the salt of an encrypted HELLO.
+// It returns nil without error for clients that do not encrypt, as long as encryption is not required.
+func (v *Server) sessionCipher(packet *VCSPacket) (cipher.AEAD, error) {
+	if !packet.IsEncrypted() {
+		if v.settingsState.IsVoiceEncryptionRequired() {
+			return nil, errors.New("voice encryption is required")
+		}
+		return nil, nil
+	}
+	if len(packet.Payload) < KeySaltSize {
+		return nil, errors.New("hello is missing the key salt")
+	}
+	masterKey, exists := v.serverState.GetVoiceKey(packet.SenderID)
+	if !exists {
+		return nil, errors.New("no voice key was negotiated on login")
+	}
+	return NewSessionCipher(masterKey, packet.Payload[:KeySaltSize])
+}
+
+// openVoicePacket decrypts an encrypted voice packet in place with the session cipher of the sender
+func (v *Server) openVoicePacket(packet *VCSPacket, header []byte) bool {
+	v.RLock()
+	client, exists := v.clients[packet.SenderID]
+	v.RUnlock()
+	if !exists || client.aead == nil {
+		v.logger.Warn("Dropping encrypted voice packet without session key", "sender_id", packet.SenderID)
+		return false
+	}
+	if err := packet.Open(header, client.aead); err != nil {
+		v.logger.Warn("Dropping voice packet that failed to decrypt", "sender_id", packet.SenderID, "error", err)
+		return false
+	}
+	return true
+}
+
+// boundClient returns the voice client of the packet, if the packet was sent from the address the session is bound to.
+// Sessions that authenticated their HELLO have to authenticate every following packet as well.
+func (v *Server) boundClient(packet *VCSPacket, addr netip.AddrPort, authenticated bool) (*Client, bool) {
 	v.RLock()
 	client, exists := v.clients[packet.SenderID]
 	v.RUnlock()
 	if !exists {
-		v.logger.Warn("Received keepalive from unknown client", "sender_id", packet.SenderID)
+		v.logger.Warn("Received packet from unknown client", "type", packet.Type, "sender_id", packet.SenderID)
+		return nil, false
+	}
+	if client.Addr.AddrPort() != addr {
+		v.logger.Warn("Rejected packet from address the session is not bound to", "type", packet.Type, "sender_id", packet.SenderID, "addr", addr.String())
+		return nil, false
+	}
+	if client.Authenticated && !authenticated {
+		v.logger.Warn("Rejected unauthenticated packet for authenticated session", "type", packet.Type, "sender_id", packet.SenderID)
+		return nil, false
+	}
+	return client, true
+}
+
+func (v *Server) handleKeepalivePacket(packet *VCSPacket, addr netip.AddrPort, authenticated bool) {
+	client, ok := v.boundClient(packet, addr, authenticated)
+	if !ok {
 		return
 	}
 	v.Lock()
@@ -199,7 +351,7 @@ func (v *Server) handleKeepalivePacket(packet *VCSPacket, addr *net.UDPAddr) {
 	v.logger.Debug("Updated last seen for client", "sender_id", packet.SenderID, "addr", addr.String())
 	ackPacket := NewVCSKeepalivePacket(packet.SenderID)
 	ackData := ackPacket.SerializePacket()
-	_, err := v.conn.WriteToUDP(ackData, addr)
+	_, err := v.conn.WriteToUDPAddrPort(ackData, addr)
 	if err != nil {
 		v.logger.Error("Failed to send keepalive acknowledgment",
 			"to", addr.String(),
@@ -208,19 +360,45 @@ func (v *Server) handleKeepalivePacket(packet *VCSPacket, addr *net.UDPAddr) {
 	}
 }
 
-func (v *Server) handleVoicePacket(packet *VCSPacket) {
-	v.RLock()
-	client, exists := v.clients[packet.SenderID]
-	v.RUnlock()
-	if !exists {
-		v.logger.Warn("Received voice packet from unknown client", "sender_id", packet.SenderID)
+func (v *Server) handleVoicePacket(packet *VCSPacket, addr netip.AddrPort, authenticated bool) {
+	client, ok := v.boundClient(packet, addr, authenticated)
+	if !ok {
 		return
 	}
 
 	// Update last seen time
+	now := time.Now()
 	v.Lock()
-	client.LastSeen = time.Now()
+	client.LastSeen = now
 	v.Unlock()
+	v.quality.record(packet, v.settingsState.GetChannelPlan().Channel(packet.RadioFrequency()), now)
+
+	if v.serverState.IsMuted(packet.SenderID, now) {
+		v.logger.Debug("Dropping voice packet of muted client", "sender_id", packet.SenderID)
+		return
+	}
+	if !v.checkTransmit(packet) {
+		return
+	}
+	if packet.IsIntercom() {
+		// Intercom is crew chatter and not a radio transmission, so it is neither tracked, recorded nor echoed
+		v.broadcastVoice(packet, packet.SenderID)
+		return
+	}
+
+	// Only transmissions that are on the air are tracked, blocked and pre-empted talkers are never published or logged
+	if !v.arbitrate(packet, now) {
+		return
+	}
+	if !v.trackTransmission(packet, now) {
+		return
+	}
+	v.recorder.Record(packet)
+
+	if v.settingsState.IsFrequencyTest(packet.RadioFrequency()) {
+		v.handleTestVoice(packet, now)
+		return
+	}
 
 	// Broadcast the voice data to other clients
 	v.broadcastVoice(packet, packet.SenderID)
@@ -232,22 +410,96 @@ func (v *Server) handleVoicePacket(packet *VCSPacket) {
 		"size", len(packet.Payload))
 }
 
-func (v *Server) handleGoodbyePacket(packet *VCSPacket) {
+func (v *Server) handleGoodbyePacket(packet *VCSPacket, addr netip.AddrPort, authenticated bool) {
+	if _, ok := v.boundClient(packet, addr, authenticated); !ok {
+		return
+	}
 	v.DisconnectClient(packet.SenderID)
 }
 
+// broadcastVoice sends the packet to all listening clients and retransmits it onto the frequencies relays connect
+// its frequency to. Only the encryption key and the hop counter are taken from the header extension of the sender.
 func (v *Server) broadcastVoice(packet *VCSPacket, senderID uuid.UUID) {
-	for _, client := range v.GetListeningClients(packet, senderID) { // Already a lot of logic is done in GetListeningClients
-		go func(addr *net.UDPAddr) {
-			_, err := v.conn.WriteToUDP(packet.SerializePacket(), addr)
-			v.logger.Debug("Sent packet to client", "sender_id", packet.SenderID, "receiver_addr", addr.String())
+	receptions := v.receptions(packet, senderID, senderID) // Already a lot of logic is done in receptions
+
+	key := v.transmissionKey(packet)
+	hops, _ := packet.Hops()
+	packet.ClearExtension()
+	if key != 0 {
+		packet.SetEncryptionKey(key)
+	}
+	v.sendVoice(packet, senderID, receptions)
+	if !packet.IsIntercom() {
+		v.relayVoice(packet, senderID, receptions, hops)
+	}
+	packet.ClearExtension()
+}
+
+// sendVoice serializes the packet once and sends it to the receivers in batches.
+// Receivers with a session key get the packet sealed with their own key, receivers with a simulated signal strength
+// get their own copy with the strength added to the header extension, and receivers tuned with another encryption key
+// get noise with the Garbled flag. The packet is left as it was passed.
+func (v *Server) sendVoice(packet *VCSPacket, senderID uuid.UUID, receptions []reception) {
+	if len(receptions) == 0 {
+		return
+	}
+
+	base := len(packet.Extension)
+	payload := packet.Payload
+	var noise []byte
+
+	batch := sendBatchPool.Get().(*sendBatch)
+	n, err := packet.SerializeInto(batch.buf[:])
+	if err != nil {
+		sendBatchPool.Put(batch)
+		v.logger.Error("Failed to serialize voice packet", "sender_id", senderID, "error", err)
+		return
+	}
+	copySize := HeaderSize + 1 + base + signalEntrySize + len(payload) + SealOverhead
+	copies := batch.copiesFor(receptions, copySize)
+
+	messages := batch.messagesFor(len(receptions))
+	sent := 0
+	for _, reception := range receptions {
+		client := reception.client
+		data := batch.buf[:n]
+		if reception.isReceiverSpecific() {
+			packet.truncateExtension(base)
+			if reception.simulated {
+				packet.SetSignalStrength(reception.strength)
+			}
+			packet.Payload = payload
+			if reception.garbled {
+				if noise == nil {
+					noise = garble(payload)
+				}
+				packet.Payload = noise
+			}
+			packet.SetGarbled(reception.garbled)
+			data, copies = copies[:copySize], copies[copySize:]
+			var size int
+			if client.aead != nil {
+				size, err = packet.SealInto(data, client.aead)
+			} else {
+				size, err = packet.SerializeInto(data)
+			}
 			if err != nil {
-				v.logger.Error("Failed to send voice packet",
-					"to", addr.String(),
-					"error", err)
+				v.logger.Error("Failed to serialize voice packet", "to", client.Addr.String(), "error", err)
+				continue
 			}
-		}(client.Addr)
-	}
+			data = data[:size]
+		}
+		messages[sent].Buffers[0] = data
+		messages[sent].Addr = client.Addr
+		sent++
+	}
+	packet.truncateExtension(base)
+	packet.Payload = payload
+	packet.SetGarbled(false)
+	messages = messages[:sent]
+	v.writeBatch(messages)
+	v.logger.Debug("Sent voice packet to clients", "sender_id", senderID, "receivers", len(receptions))
+	batch.release(messages)
 }
 
 func (v *Server) cleanupRoutine() {
@@ -273,6 +525,7 @@ func (v *Server) cleanup() {
 	for id, client := range v.clients {
 		if client.LastSeen.Before(threshold) {
 			delete(v.clients, id)
+			v.quality.remove(id)
 			v.logger.Info("Removed inactive voice client",
 				"id", id,
 				"addr", client.Addr.String())
@@ -320,6 +573,9 @@ func (v *Server) DisconnectClient(clientID uuid.UUID) {
 		v.Lock()
 		delete(v.clients, clientID)
 		v.Unlock()
+		v.endClientTransmission(clientID)
+		v.quality.remove(clientID)
+		v.arbiter.remove(clientID)
 		v.logger.Info("Disconnected voice client",
 			"id", clientID,
 			"addr", client.Addr.String())
@@ -341,202 +597,50 @@ func (v *Server) GetClientCount() int {
 }
 
 func (v *Server) GetListeningClients(packet *VCSPacket, senderId uuid.UUID) []*Client {
-	if v.settingsState.IsFrequencyTest(packet.FrequencyAsFloat32()) {
-		v.serverState.RLock()
-		defer v.serverState.RUnlock()
-		if _, exists := v.clients[packet.SenderID]; exists {
-			// Instead of echoing, play the voice data locally on the server
-			go v.PlayVoiceData(packet.Payload)
-			return []*Client{} // Do not send to any clients
-		}
-		v.logger.Warn("Received test frequency packet from unknown client", "sender_id", packet.SenderID)
-		return []*Client{} // No clients to return if sender is unknown
-	}
-
-	var listeningClients []*Client
-	for _, client := range v.serverState.GetAllClients() {
-		if client.ID == senderId {
-			continue // Skip the sender
-		}
-		if v.serverState.IsListeningOnFrequency(client.ID, senderId, packet.FrequencyAsFloat32(), v.settingsState.IsFrequencyGlobal(packet.FrequencyAsFloat32())) {
-			v.RLock()
-			clientData, exists := v.clients[client.ID]
-			v.RUnlock()
-			if exists {
-				listeningClients = append(listeningClients, clientData)
-			}
-		}
+	receptions := v.receptions(packet, senderId, senderId)
+	listeningClients := make([]*Client, len(receptions))
+	for i, reception := range receptions {
+		listeningClients[i] = reception.client
 	}
 	return listeningClients
 }
 
-// Simple, direct PlayVoiceData without dejitter buffer - for clean local test playback
-func (v *Server) PlayVoiceData(payload []byte) {
-	if len(payload) == 0 {
-		return
-	}
-	toc := payload[0]
-	config := toc >> 3
-	if config < minCELTConfig {
-		// Not CELT-only (likely SILK/hybrid). Drop to avoid Pion error.
-		v.logger.Warn("Dropping non-CELT Opus packet", "config", config, "len_payload", len(payload))
-		return
-	}
-
-	// Lazy-initialize decoder and speaker once
-	v.playOnce.Do(func() {
-		var err error
-		v.opusDec = opus.NewDecoder() // mono 48kHz
-
-		v.playFormat = beep.Format{
-			SampleRate:  beep.SampleRate(48000),
-			NumChannels: 1,
-			Precision:   2,
-		}
-
-		// Larger buffer for smoother playback
-		err = speaker.Init(v.playFormat.SampleRate, v.playFormat.SampleRate.N(300*time.Millisecond))
-		if err != nil {
-			v.playErr = err
-			v.logger.Error("Failed to init Speaker", "error", err)
-			return
-		}
-
-		pr, pw := io.Pipe()
-		v.pipeW = pw
-
-		stream := &pcmStream{
-			r:   pr,
-			f:   v.playFormat,
-			buf: make([]byte, 8192*v.playFormat.Width()),
+// receptions returns the voice clients that hear the packet, with the signal strength they hear it with from the
+// position of the transmitter, which is the sender or the retransmission node that relayed the packet
+func (v *Server) receptions(packet *VCSPacket, senderId uuid.UUID, transmitterId uuid.UUID) []reception {
+	var listeners []uuid.UUID
+	var model PropagationModel
+	frequency := packet.RadioFrequency()
+	switch {
+	case packet.IsIntercom():
+		listeners = v.serverState.GetIntercomListeners(senderId) // Intercom reaches the crew regardless of frequency
+	case v.settingsState.IsFrequencyTest(frequency):
+		return []reception{} // Test frequencies are only echoed back to the sender, see handleTestVoice
+	default:
+		listeners = v.serverState.GetListeningClients(senderId, frequency, v.settingsState.IsFrequencyGlobal(frequency), v.settingsState.IsFrequencyGuard(frequency), v.settingsState.GetChannelPlan(), v.settingsState.GetCoalitionMatrix())
+		if v.settingsState.IsFrequencyRestricted(frequency) {
+			listeners = v.allowedListeners(listeners, frequency)
 		}
-		speaker.Play(stream)
-	})
-
-	if v.playErr != nil || v.pipeW == nil {
-		v.logger.Error("Audio playback not initialized", "error", v.playErr)
-		return
-	}
-
-	// Decode directly - buffer for up to 60ms stereo @ 48kHz
-	out := make([]byte, maxOpusFrameSize)
-
-	// Serialize opus decoder access and pipe writes; opus.Decoder is not goroutine-safe
-	v.playMu.Lock()
-	// Protect against library panics on corrupt/non-Opus payloads
-	var (
-		bw       opus.Bandwidth
-		isStereo bool
-		err      error
-	)
-	func() {
-		defer func() {
-			if r := recover(); r != nil {
-				err = fmt.Errorf("opus decode panic: %v", r)
-			}
-		}()
-		bw, isStereo, err = v.opusDec.Decode(payload, out)
-	}()
-	if err != nil {
-		v.playMu.Unlock()
-		v.logger.Error("Failed to decode Opus data", "error", err)
-		return
-	}
-
-	// Get sample rate and assume 20ms frame
-	sr := bw.SampleRate()
-	if sr == 0 {
-		sr = 48000
-	}
-	samplesPerCh := sr / 50 // 20ms
-	if samplesPerCh <= 0 {
-		samplesPerCh = samplesPerChannel
-	}
-
-	// Calculate actual bytes to use
-	channels := 1
-	if isStereo {
-		channels = 2
-	}
-	bytesToUse := samplesPerCh * 2 * channels
-	if bytesToUse > len(out) {
-		bytesToUse = len(out)
-	}
-
-	var pcmData []byte
-	if isStereo {
-		// Downmix to mono
-		pcmData = downmixStereoS16LEToMono(out[:bytesToUse], samplesPerCh)
-	} else {
-		pcmData = out[:bytesToUse]
-	}
-
-	// Write directly to pipe while still holding the lock (serialize with decoder)
-	_, err = v.pipeW.Write(pcmData)
-	v.playMu.Unlock()
-
-	if err != nil {
-		v.logger.Error("Failed to write PCM to speaker pipe", "error", err)
+		model = v.propagationModel(frequency)
 	}
-}
-
-// downmixStereoS16LEToMono averages L/R channels into mono S16LE.
-func downmixStereoS16LEToMono(in []byte, samplesPerCh int) []byte {
-	out := make([]byte, samplesPerCh*2)
-	for i := 0; i < samplesPerCh; i++ {
-		li := 4 * i
-		ri := li + 2
-		l := int16(uint16(in[li]) | uint16(in[li+1])<<8)
-		r := int16(uint16(in[ri]) | uint16(in[ri+1])<<8)
-		m := int16((int32(l) + int32(r)) / 2)
-		oi := 2 * i
-		out[oi] = byte(uint16(m))
-		out[oi+1] = byte(uint16(m) >> 8)
-	}
-	return out
-}
-
-// pcmStream allows faiface to play raw S16LE PCM directly.
-// This is adapted from the example you pasted.
-type pcmStream struct {
-	r   io.Reader
-	f   beep.Format
-	buf []byte
-	len int
-	pos int
-	err error
-}
-
-func (s *pcmStream) Err() error { return s.err }
-
-func (s *pcmStream) Stream(samples [][2]float64) (n int, ok bool) {
-	width := s.f.Width()
+	receptions := v.decrypt(v.propagate(listeners, transmitterId, frequency, model), v.transmissionKey(packet), frequency)
 
-	// If there's not enough data for a full sample, get more
-	if size := s.len - s.pos; size < width {
-		// If there's a partial sample, move it to the beginning of the buffer
-		if size != 0 {
-			copy(s.buf, s.buf[s.pos:s.len])
-		}
-		s.len = size
-		s.pos = 0
-
-		// Refill the buffer
-		nbytes, err := s.r.Read(s.buf[s.len:])
-		if err != nil {
-			if err != io.EOF {
-				s.err = err
-			}
-			return n, false
+	v.RLock()
+	defer v.RUnlock()
+	connected := receptions[:0]
+	for _, reception := range receptions {
+		if clientData, exists := v.clients[reception.clientID]; exists {
+			reception.client = clientData
+			connected = append(connected, reception)
 		}
-		s.len += nbytes
 	}
+	return connected
+}
 
-	// Decode as many samples as we can
-	for n < len(samples) && s.len-s.pos >= width {
-		samples[n], _ = s.f.DecodeSigned(s.buf[s.pos:])
-		n++
-		s.pos += width
-	}
-	return n, true
+// allowedListeners drops the listeners the frequency policies do not allow to listen on the frequency
+func (v *Server) allowedListeners(listeners []uuid.UUID, frequency state.Frequency) []uuid.UUID {
+	return slices.DeleteFunc(listeners, func(clientID uuid.UUID) bool {
+		client, exists := v.serverState.GetClient(clientID)
+		return !exists || v.settingsState.CheckFrequencyAccess(frequency, client, state.FrequencyAccessListen) != nil
+	})
 }
@@ -1 +1,612 @@
 package voice
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/google/uuid"
+	"golang.org/x/net/ipv4"
+)
+
+// newBenchmarkServer creates a voice server with the given amount of connected clients.
+// The clients are spread over ten frequencies, so roughly a tenth of them listen to the sender.
+func newBenchmarkServer(clients int) (*Server, uuid.UUID) {
+	serverState := &state.ServerState{}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, &state.SettingsState{}, events.NewEventBus())
+
+	var sender uuid.UUID
+	for i := range clients {
+		id := uuid.New()
+		if i == 0 {
+			sender = id
+		}
+		serverState.AddClient(id, &state.ClientState{Name: fmt.Sprintf("Client %d", i), Coalition: "blue"})
+		serverState.SetRadioState(id, &state.RadioState{Radios: []state.Radio{
+			{ID: 1, Frequency: state.Frequency(251+i%10) * state.MHz, Enabled: true},
+			{ID: 2, Frequency: 243 * state.MHz, Enabled: false},
+		}})
+		server.clients[id] = &Client{
+			Addr:     &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 10000 + i},
+			LastSeen: time.Now(),
+		}
+	}
+	return server, sender
+}
+
+// linearListeningClients is the full client scan GetListeningClients used before the frequency index
+func (v *Server) linearListeningClients(packet *VCSPacket, senderId uuid.UUID) []*Client {
+	var listeningClients []*Client
+	for _, client := range v.serverState.GetAllClients() {
+		if client.ID == senderId {
+			continue
+		}
+		if v.serverState.IsListeningOnFrequency(client.ID, senderId, packet.RadioFrequency(), v.settingsState.IsFrequencyGlobal(packet.RadioFrequency()), v.settingsState.IsFrequencyGuard(packet.RadioFrequency()), v.settingsState.GetChannelPlan(), v.settingsState.GetCoalitionMatrix()) {
+			v.RLock()
+			clientData, exists := v.clients[client.ID]
+			v.RUnlock()
+			if exists {
+				listeningClients = append(listeningClients, clientData)
+			}
+		}
+	}
+	return listeningClients
+}
+
+func TestGetListeningClients(t *testing.T) {
+	server, sender := newBenchmarkServer(50)
+	packet := NewVCSVoicePacket(sender, 1, 251000, []byte{0x01})
+
+	got := server.GetListeningClients(packet, sender)
+	want := server.linearListeningClients(packet, sender)
+	if len(got) != len(want) {
+		t.Fatalf("GetListeningClients() returned %d clients, want %d", len(got), len(want))
+	}
+	wanted := make(map[*Client]bool, len(want))
+	for _, client := range want {
+		wanted[client] = true
+	}
+	for _, client := range got {
+		if !wanted[client] {
+			t.Errorf("GetListeningClients() returned unexpected client %s", client.Addr)
+		}
+	}
+}
+
+func BenchmarkGetListeningClients(b *testing.B) {
+	for _, clients := range []int{50, 150, 500} {
+		server, sender := newBenchmarkServer(clients)
+		packet := NewVCSVoicePacket(sender, 1, 251000, []byte{0x01})
+
+		b.Run(fmt.Sprintf("indexed/%d", clients), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				server.GetListeningClients(packet, sender)
+			}
+		})
+		b.Run(fmt.Sprintf("linear/%d", clients), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				server.linearListeningClients(packet, sender)
+			}
+		})
+	}
+}
+
+// attachLoopback gives the server a loopback socket to send from, without starting the receive loop
+func attachLoopback(tb testing.TB, server *Server) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		tb.Fatalf("ListenUDP() error = %v", err)
+	}
+	tb.Cleanup(func() { _ = conn.Close() })
+	server.conn = conn
+	server.batchConn = newBatchWriter(conn)
+}
+
+// goroutineBroadcastVoice is the per-receiver goroutine fan-out broadcastVoice used before batching
+func (v *Server) goroutineBroadcastVoice(packet *VCSPacket, senderID uuid.UUID) {
+	var wg sync.WaitGroup
+	for _, client := range v.GetListeningClients(packet, senderID) {
+		wg.Go(func() {
+			_, _ = v.conn.WriteToUDP(packet.SerializePacket(), client.Addr)
+		})
+	}
+	wg.Wait()
+}
+
+func BenchmarkBroadcastVoice(b *testing.B) {
+	for _, clients := range []int{50, 150, 500} {
+		server, sender := newBenchmarkServer(clients)
+		attachLoopback(b, server)
+		packet := NewVCSVoicePacket(sender, 1, 251000, make([]byte, 120))
+
+		b.Run(fmt.Sprintf("batched/%d", clients), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				server.broadcastVoice(packet, sender)
+			}
+		})
+		b.Run(fmt.Sprintf("goroutines/%d", clients), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				server.goroutineBroadcastVoice(packet, sender)
+			}
+		})
+	}
+}
+
+// voicePayload encodes the sender and sequence into the payload, so receivers can detect packets
+// whose buffer was overwritten while they were handled.
+func voicePayload(sender uuid.UUID, sequence uint32) []byte {
+	payload := make([]byte, 0, 64)
+	payload = append(payload, sender[:]...)
+	payload = binary.BigEndian.AppendUint32(payload, sequence)
+	return append(payload, bytes.Repeat([]byte{byte(sequence)}, 40)...)
+}
+
+// TestListenFlood floods the server from several clients at once. Run it with -race.
+func TestListenFlood(t *testing.T) {
+	const (
+		clientCount = 8
+		perClient   = 300
+	)
+
+	serverState := &state.ServerState{}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, &state.SettingsState{}, events.NewEventBus())
+
+	stopChan := make(chan struct{})
+	listenErr := make(chan error, 1)
+	go func() { listenErr <- server.Listen("127.0.0.1:0", stopChan) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for !server.isRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("voice server did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	server.RLock()
+	serverAddr := server.conn.LocalAddr().(*net.UDPAddr)
+	server.RUnlock()
+	defer func() {
+		close(stopChan)
+		if err := <-listenErr; err != nil {
+			t.Errorf("Listen() error = %v", err)
+		}
+		if err := server.Stop(); err != nil {
+			t.Errorf("Stop() error = %v", err)
+		}
+	}()
+
+	ids := make([]uuid.UUID, clientCount)
+	conns := make([]*net.UDPConn, clientCount)
+	for i := range clientCount {
+		ids[i] = uuid.New()
+		serverState.AddClient(ids[i], &state.ClientState{Name: fmt.Sprintf("Client %d", i), Coalition: "blue"})
+		serverState.SetRadioState(ids[i], &state.RadioState{Radios: []state.Radio{{ID: 1, Frequency: 251 * state.MHz, Enabled: true}}})
+
+		conn, err := net.DialUDP("udp", nil, serverAddr)
+		if err != nil {
+			t.Fatalf("DialUDP() error = %v", err)
+		}
+		defer conn.Close()
+		_ = conn.SetReadBuffer(4 << 20)
+		conns[i] = conn
+
+		hello := &VCSPacket{Magic: [3]byte{'V', 'C', 'S'}, Version: currentVersion, Type: PacketTypeHello, SenderID: ids[i]}
+		if _, err := conn.Write(hello.SerializePacket()); err != nil {
+			t.Fatalf("Write(hello) error = %v", err)
+		}
+		buf := make([]byte, BufferSize)
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("Read(hello ack) error = %v", err)
+		}
+		if ack, err := ParsePacket(buf[:n]); err != nil || ack.Type != PacketTypeHelloAck {
+			t.Fatalf("hello ack = %v, %v, want HELLO_ACK", ack, err)
+		}
+	}
+
+	var received, corrupted atomic.Int64
+	var readers sync.WaitGroup
+	for _, conn := range conns {
+		readers.Go(func() {
+			buf := make([]byte, BufferSize)
+			for {
+				_ = conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+				n, err := conn.Read(buf)
+				if err != nil {
+					return
+				}
+				packet, err := ParsePacket(buf[:n])
+				if err != nil || packet.Type != PacketTypeVoice {
+					continue
+				}
+				received.Add(1)
+				if !bytes.Equal(packet.Payload, voicePayload(packet.SenderID, packet.Sequence)) {
+					corrupted.Add(1)
+				}
+			}
+		})
+	}
+
+	var senders sync.WaitGroup
+	for i, conn := range conns {
+		senders.Go(func() {
+			for sequence := range uint32(perClient) {
+				packet := NewVCSVoicePacket(ids[i], sequence, 251000, voicePayload(ids[i], sequence))
+				if _, err := conn.Write(packet.SerializePacket()); err != nil {
+					t.Errorf("Write(voice) error = %v", err)
+					return
+				}
+				if sequence%50 == 0 {
+					_, _ = conn.Write(NewVCSKeepalivePacket(ids[i]).SerializePacket())
+				}
+			}
+		})
+	}
+	senders.Wait()
+	readers.Wait()
+
+	if received.Load() == 0 {
+		t.Fatal("no voice packets were forwarded")
+	}
+	if corrupted.Load() != 0 {
+		t.Errorf("%d of %d forwarded voice packets were corrupted", corrupted.Load(), received.Load())
+	}
+}
+
+// newInbound builds a received packet from addr, signed with secret if one is given
+func newInbound(packet *VCSPacket, addr netip.AddrPort, secret []byte) *inboundPacket {
+	packet.SetAuthenticated(secret != nil)
+	data := packet.SerializePacket()
+	if secret != nil {
+		data = SignPacket(data, secret)
+	}
+	in := &inboundPacket{addr: addr}
+	in.n = copy(in.buf[:], data)
+	return in
+}
+
+func TestSessionBinding(t *testing.T) {
+	serverState := &state.ServerState{}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, &state.SettingsState{}, events.NewEventBus())
+	attachLoopback(t, server)
+	server.running = true
+
+	id := uuid.New()
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	serverState.AddClient(id, &state.ClientState{Name: "Pilot", Coalition: "blue", VoiceSecret: secret})
+	home := netip.MustParseAddrPort("127.0.0.1:40001")
+	attacker := netip.MustParseAddrPort("127.0.0.1:40002")
+	rebound := netip.MustParseAddrPort("127.0.0.1:40003")
+	hello := func() *VCSPacket {
+		return &VCSPacket{Magic: [3]byte{'V', 'C', 'S'}, Version: currentVersion, Type: PacketTypeHello, SenderID: id}
+	}
+	bye := func() *VCSPacket {
+		return &VCSPacket{Magic: [3]byte{'V', 'C', 'S'}, Version: currentVersion, Type: PacketTypeBye, SenderID: id}
+	}
+	boundTo := func() netip.AddrPort {
+		server.RLock()
+		defer server.RUnlock()
+		client, exists := server.clients[id]
+		if !exists {
+			return netip.AddrPort{}
+		}
+		return client.Addr.AddrPort()
+	}
+
+	server.handlePacket(newInbound(hello(), home, nil))
+	if got := boundTo(); got != home {
+		t.Fatalf("session bound to %v after hello, want %v", got, home)
+	}
+
+	server.handlePacket(newInbound(bye(), attacker, nil))
+	if got := boundTo(); got != home {
+		t.Errorf("bye from other address changed binding to %v, want %v", got, home)
+	}
+
+	server.handlePacket(newInbound(hello(), attacker, nil))
+	if got := boundTo(); got != home {
+		t.Errorf("unauthenticated re-hello moved session to %v, want %v", got, home)
+	}
+
+	server.handlePacket(newInbound(hello(), attacker, []byte("wrong secret")))
+	if got := boundTo(); got != home {
+		t.Errorf("re-hello with wrong secret moved session to %v, want %v", got, home)
+	}
+
+	server.handlePacket(newInbound(hello(), rebound, secret))
+	if got := boundTo(); got != rebound {
+		t.Fatalf("authenticated re-hello bound session to %v, want %v", got, rebound)
+	}
+
+	server.handlePacket(newInbound(bye(), rebound, nil))
+	if got := boundTo(); got != rebound {
+		t.Errorf("unauthenticated bye for authenticated session disconnected it")
+	}
+
+	server.handlePacket(newInbound(bye(), rebound, secret))
+	if got := boundTo(); got.IsValid() {
+		t.Errorf("authenticated bye did not disconnect session, still bound to %v", got)
+	}
+}
+
+func TestShortAuthenticatedPayload(t *testing.T) {
+	serverState := &state.ServerState{}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, &state.SettingsState{}, events.NewEventBus())
+	attachLoopback(t, server)
+	server.running = true
+
+	id := uuid.New()
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	serverState.AddClient(id, &state.ClientState{Name: "Pilot", Coalition: "blue", VoiceSecret: secret})
+	addr := netip.MustParseAddrPort("127.0.0.1:40004")
+
+	// The length byte of the extension claims half of the tag, which leaves a payload shorter than the tag
+	packet := &VCSPacket{Magic: [3]byte{'V', 'C', 'S'}, Version: currentVersion, Type: PacketTypeHello, SenderID: id}
+	packet.appendExtension(0x7F, make([]byte, TagSize/2-2)...)
+	packet.SetAuthenticated(true)
+	data := SignPacket(packet.SerializePacket()[:HeaderSize+1], secret)
+	in := &inboundPacket{addr: addr}
+	in.n = copy(in.buf[:], data)
+
+	server.handlePacket(in) // Must not panic
+	server.RLock()
+	_, bound := server.clients[id]
+	server.RUnlock()
+	if bound {
+		t.Errorf("authenticated packet with a payload shorter than the tag was accepted")
+	}
+}
+
+func TestEncryptedVoice(t *testing.T) {
+	serverState := &state.ServerState{}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, &state.SettingsState{}, events.NewEventBus())
+	attachLoopback(t, server)
+	server.running = true
+
+	type session struct {
+		id   uuid.UUID
+		key  []byte
+		conn *net.UDPConn
+		addr netip.AddrPort
+	}
+	newSession := func(name string, key []byte) *session {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("ListenUDP() error = %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		s := &session{id: uuid.New(), key: key, conn: conn, addr: conn.LocalAddr().(*net.UDPAddr).AddrPort()}
+		serverState.AddClient(s.id, &state.ClientState{Name: name, Coalition: "blue", VoiceKey: key})
+		serverState.SetRadioState(s.id, &state.RadioState{Radios: []state.Radio{{ID: 1, Frequency: 251 * state.MHz, Enabled: true}}})
+		return s
+	}
+	hello := func(s *session, salt []byte) {
+		packet := &VCSPacket{Magic: [3]byte{'V', 'C', 'S'}, Version: currentVersion, Type: PacketTypeHello, SenderID: s.id, Payload: salt}
+		packet.SetEncrypted(salt != nil)
+		server.handlePacket(newInbound(packet, s.addr, nil))
+		buf := make([]byte, BufferSize)
+		_ = s.conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := s.conn.Read(buf)
+		if err != nil {
+			t.Fatalf("Read(hello ack) error = %v", err)
+		}
+		ack, err := ParsePacket(buf[:n])
+		if err != nil || ack.Type != PacketTypeHelloAck || ack.IsEncrypted() != (salt != nil) {
+			t.Fatalf("hello ack = %v, %v, want HELLO_ACK with encrypted %t", ack, err, salt != nil)
+		}
+	}
+	receive := func(s *session) *VCSPacket {
+		buf := make([]byte, BufferSize)
+		_ = s.conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := s.conn.Read(buf)
+		if err != nil {
+			t.Fatalf("Read(voice) error = %v", err)
+		}
+		packet, err := ParsePacket(buf[:n])
+		if err != nil {
+			t.Fatalf("ParsePacket() error = %v", err)
+		}
+		return packet
+	}
+
+	sender := newSession("Sender", bytes.Repeat([]byte{0x01}, 32))
+	encrypted := newSession("Encrypted", bytes.Repeat([]byte{0x02}, 32))
+	plain := newSession("Plain", nil)
+	senderSalt := bytes.Repeat([]byte{0xA1}, KeySaltSize)
+	receiverSalt := bytes.Repeat([]byte{0xB2}, KeySaltSize)
+	hello(sender, senderSalt)
+	hello(encrypted, receiverSalt)
+	hello(plain, nil)
+
+	senderCipher, err := NewSessionCipher(sender.key, senderSalt)
+	if err != nil {
+		t.Fatalf("NewSessionCipher() error = %v", err)
+	}
+	receiverCipher, err := NewSessionCipher(encrypted.key, receiverSalt)
+	if err != nil {
+		t.Fatalf("NewSessionCipher() error = %v", err)
+	}
+
+	payload := []byte{0xF8, 0xDE, 0xAD, 0xBE, 0xEF}
+	voice := NewVCSVoicePacket(sender.id, 1, 251000, payload)
+	data := make([]byte, voice.SealedSize())
+	n, err := voice.SealInto(data, senderCipher)
+	if err != nil {
+		t.Fatalf("SealInto() error = %v", err)
+	}
+	in := &inboundPacket{addr: sender.addr}
+	in.n = copy(in.buf[:], data[:n])
+	server.handlePacket(in)
+
+	sealed := receive(encrypted)
+	if !sealed.IsEncrypted() {
+		t.Fatalf("encrypted receiver got a plain packet")
+	}
+	header := sealed.SerializePacket()[:HeaderSize]
+	if err := sealed.Open(header, receiverCipher); err != nil {
+		t.Fatalf("Open() with receiver key error = %v", err)
+	}
+	if !bytes.Equal(sealed.Payload, payload) {
+		t.Errorf("encrypted receiver payload = %v, want %v", sealed.Payload, payload)
+	}
+
+	if got := receive(plain); got.IsEncrypted() || !bytes.Equal(got.Payload, payload) {
+		t.Errorf("plain receiver payload = %v (encrypted %t), want %v", got.Payload, got.IsEncrypted(), payload)
+	}
+
+	// After a re-HELLO the old session key must not be accepted anymore
+	hello(sender, bytes.Repeat([]byte{0xC3}, KeySaltSize))
+	in = &inboundPacket{addr: sender.addr}
+	in.n = copy(in.buf[:], data[:n])
+	server.handlePacket(in)
+	_ = plain.conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, err := plain.conn.Read(make([]byte, BufferSize)); err == nil {
+		t.Errorf("voice sealed with the rotated key was forwarded")
+	}
+}
+
+// failingBatchWriter fails every batch like x/net does, with n == -1
+type failingBatchWriter struct{}
+
+func (failingBatchWriter) WriteBatch([]ipv4.Message, int) (int, error) {
+	return -1, errors.New("batch send failed")
+}
+
+func TestWriteBatchFallback(t *testing.T) {
+	for name, writer := range map[string]batchWriter{"failing": failingBatchWriter{}, "unsupported": nil} {
+		t.Run(name, func(t *testing.T) {
+			server := NewServer(&state.ServerState{}, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, &state.SettingsState{}, events.NewEventBus())
+			attachLoopback(t, server)
+			server.batchConn = writer
+
+			var receivers []*net.UDPConn
+			var messages []ipv4.Message
+			for i := range 3 {
+				receiver, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+				if err != nil {
+					t.Fatalf("ListenUDP() error = %v", err)
+				}
+				t.Cleanup(func() { _ = receiver.Close() })
+				receivers = append(receivers, receiver)
+				messages = append(messages, ipv4.Message{Buffers: [][]byte{{byte(i)}}, Addr: receiver.LocalAddr()})
+			}
+
+			server.writeBatch(messages)
+			for i, receiver := range receivers {
+				buf := make([]byte, 16)
+				_ = receiver.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+				n, err := receiver.Read(buf)
+				if err != nil || n != 1 || buf[0] != byte(i) {
+					t.Errorf("receiver %d got %v, %v, want its datagram", i, buf[:max(n, 0)], err)
+				}
+			}
+		})
+	}
+}
+
+func TestMutedVoiceIsDropped(t *testing.T) {
+	serverState := &state.ServerState{}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, &state.SettingsState{}, events.NewEventBus())
+	attachLoopback(t, server)
+	server.running = true
+
+	receiver, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP() error = %v", err)
+	}
+	t.Cleanup(func() { _ = receiver.Close() })
+	senderAddr := netip.MustParseAddrPort("127.0.0.1:40010")
+	sender, listener := uuid.New(), uuid.New()
+	for id, addr := range map[uuid.UUID]netip.AddrPort{sender: senderAddr, listener: receiver.LocalAddr().(*net.UDPAddr).AddrPort()} {
+		serverState.AddClient(id, &state.ClientState{Name: id.String(), Coalition: "blue"})
+		serverState.SetRadioState(id, &state.RadioState{Radios: []state.Radio{{ID: 1, Frequency: 251 * state.MHz, Enabled: true}}})
+		hello := &VCSPacket{Magic: [3]byte{'V', 'C', 'S'}, Version: currentVersion, Type: PacketTypeHello, SenderID: id}
+		server.handlePacket(newInbound(hello, addr, nil))
+	}
+	_ = receiver.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := receiver.Read(make([]byte, BufferSize)); err != nil {
+		t.Fatalf("Read(hello ack) error = %v", err)
+	}
+
+	received := func() bool {
+		server.handlePacket(newInbound(NewVCSVoicePacket(sender, 1, 251000, []byte{0xF8, 0x01}), senderAddr, nil))
+		_ = receiver.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		_, err := receiver.Read(make([]byte, BufferSize))
+		return err == nil
+	}
+
+	if _, err := serverState.MuteClient(sender, "Admin", "hot mic", 0); err != nil {
+		t.Fatal(err)
+	}
+	if received() {
+		t.Errorf("voice of a client muted by an admin was forwarded")
+	}
+	if _, err := serverState.UnmuteClient(sender, "Admin"); err != nil {
+		t.Fatal(err)
+	}
+	serverState.SetRadioState(sender, &state.RadioState{Radios: []state.Radio{{ID: 1, Frequency: 251 * state.MHz, Enabled: true}}, Muted: true})
+	if received() {
+		t.Errorf("voice of a client that muted itself was forwarded")
+	}
+	serverState.SetRadioState(sender, &state.RadioState{Radios: []state.Radio{{ID: 1, Frequency: 251 * state.MHz, Enabled: true}}})
+	if !received() {
+		t.Errorf("voice of an unmuted client was not forwarded")
+	}
+}
+
+func TestIntercomRouting(t *testing.T) {
+	serverState := &state.ServerState{}
+	server := NewServer(serverState, slog.New(slog.NewTextHandler(io.Discard, nil)), &state.DistributionState{}, &state.SettingsState{}, events.NewEventBus())
+	intercom := state.Radio{ID: 0, Name: "Intercom", Frequency: 100 * state.MHz, Enabled: true, IsIntercom: true}
+	add := func(name, unitId, coalition string, radios ...state.Radio) uuid.UUID {
+		id := uuid.New()
+		serverState.AddClient(id, &state.ClientState{Name: name, UnitId: unitId, Coalition: coalition})
+		serverState.SetRadioState(id, &state.RadioState{Radios: radios})
+		server.clients[id] = &Client{Addr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 10000 + len(server.clients)}}
+		return id
+	}
+	pilot := add("Pilot", "hornet-1", "blue", intercom, state.Radio{ID: 1, Frequency: 251 * state.MHz, Enabled: true})
+	wso := add("WSO", "hornet-1", "blue", intercom)
+	add("Wingman", "hornet-2", "blue", intercom, state.Radio{ID: 1, Frequency: 100 * state.MHz, Enabled: true})
+	add("Enemy", "hornet-1", "red", intercom)
+	add("Offline", "hornet-1", "blue", state.Radio{ID: 0, Frequency: 100 * state.MHz, Enabled: false, IsIntercom: true})
+
+	packet := NewVCSVoicePacket(pilot, 1, 100000, []byte{0x01})
+	packet.SetIntercom(true)
+	if got := server.GetListeningClients(packet, pilot); len(got) != 1 || got[0] != server.clients[wso] {
+		t.Errorf("intercom reached %d clients, want only the crew of the unit", len(got))
+	}
+	if rejection := server.authorizeTransmit(packet); rejection != TransmitAuthorized {
+		t.Errorf("intercom of the pilot was rejected: %v", rejection)
+	}
+
+	// Radio traffic on the frequency the intercoms happen to be on only reaches real radios
+	radio := NewVCSVoicePacket(wso, 1, 100000, []byte{0x01})
+	if got := server.GetListeningClients(radio, wso); len(got) != 1 {
+		t.Errorf("radio traffic reached %d clients, want only the wingman tuned to it", len(got))
+	}
+	if rejection := server.authorizeTransmit(radio); rejection != TransmitNoRadio {
+		t.Errorf("transmitting with an intercom on a frequency got %v, want %v", rejection, TransmitNoRadio)
+	}
+
+	serverState.SetRadioState(wso, &state.RadioState{Radios: []state.Radio{{ID: 0, Frequency: 100 * state.MHz, IsIntercom: true}}})
+	packet.SenderID = wso
+	if rejection := server.authorizeTransmit(packet); rejection != TransmitRadioDisabled {
+		t.Errorf("intercom of a disabled intercom got %v, want %v", rejection, TransmitRadioDisabled)
+	}
+}
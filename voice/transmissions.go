@@ -0,0 +1,177 @@
+package voice
+
+import (
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	"github.com/FPGSchiba/vcs-srs-server/transmissions"
+	"github.com/google/uuid"
+)
+
+const (
+	TransmissionTimeout = 500 * time.Millisecond // A transmission without voice packets for this long is over
+
+	sequenceMask          = 0xFFFFFF // Sequence numbers are 24-bit and wrap around
+	sequenceReorderWindow = 64       // Sequences this far behind are reordered packets, not a new transmission
+)
+
+// activeTransmission is a transmission of a client that has not ended yet
+type activeTransmission struct {
+	record       transmissions.Record
+	lastSeen     time.Time
+	lastSequence uint32
+	cutOff       bool // Exceeded the maximum transmission length, the rest of it is dropped
+}
+
+// trackTransmission derives start and end of transmissions from the PTT flag and sequence of voice packets.
+// A transmission ends with a packet without PTT, a change of frequency, a restarted sequence or a timeout.
+// It reports if the packet is forwarded, which is not the case once the transmission was cut off for its length.
+func (v *Server) trackTransmission(packet *VCSPacket, now time.Time) bool {
+	v.transmissionsMu.Lock()
+	forward, ended := v.updateTransmission(packet, now)
+	v.transmissionsMu.Unlock()
+	if ended != nil {
+		v.logTransmissions(*ended)
+	}
+	return forward
+}
+
+// updateTransmission has to be called with transmissionsMu held. It returns the record of the transmission the packet
+// ended, nil if it ended none.
+func (v *Server) updateTransmission(packet *VCSPacket, now time.Time) (bool, *transmissions.Record) {
+	var ended *transmissions.Record
+	frequency := packet.RadioFrequency()
+	if active, exists := v.transmissions[packet.SenderID]; exists {
+		switch {
+		case !v.settingsState.GetChannelPlan().Matches(active.record.Frequency, frequency),
+			now.Sub(active.lastSeen) > TransmissionTimeout,
+			isSequenceRestart(active.lastSequence, packet.Sequence):
+			record := v.endTransmission(packet.SenderID, active, active.lastSeen)
+			ended = &record
+		case !packet.IsPTTActive():
+			record := v.endTransmission(packet.SenderID, active, now)
+			return !active.cutOff, &record
+		default:
+			if isSequenceAhead(active.lastSequence, packet.Sequence) {
+				active.lastSequence = packet.Sequence
+			}
+			active.lastSeen = now
+			if limit := v.settingsState.GetMaxTransmissionDuration(packet.RadioFrequency()); !active.cutOff && limit > 0 && now.Sub(active.record.Start) > limit {
+				v.cutOff(packet.SenderID, active, limit, now)
+			}
+			return !active.cutOff, nil
+		}
+	}
+
+	if !packet.IsPTTActive() {
+		return true, ended
+	}
+	client, exists := v.serverState.GetClient(packet.SenderID)
+	if !exists {
+		return true, ended
+	}
+	active := &activeTransmission{
+		record: transmissions.Record{
+			ClientGuid: packet.SenderID.String(),
+			Name:       client.Name,
+			UnitId:     client.UnitId,
+			Coalition:  client.Coalition,
+			Frequency:  frequency,
+			Start:      now,
+		},
+		lastSeen:     now,
+		lastSequence: packet.Sequence,
+	}
+	v.transmissions[packet.SenderID] = active
+	v.eventBus.Publish(events.Event{
+		Name: events.TransmissionStarted,
+		Data: active.record,
+	})
+	return true, ended
+}
+
+// endTransmission has to be called with transmissionsMu held. The returned record has to be passed to
+// logTransmissions once the lock is released.
+func (v *Server) endTransmission(clientID uuid.UUID, active *activeTransmission, end time.Time) transmissions.Record {
+	delete(v.transmissions, clientID)
+	record := active.record
+	record.DurationMs = end.Sub(record.Start).Milliseconds()
+	v.logger.Debug("Transmission ended", "sender_id", clientID, "frequency", record.Frequency, "duration_ms", record.DurationMs)
+	v.eventBus.Publish(events.Event{
+		Name: events.TransmissionEnded,
+		Data: record,
+	})
+	return record
+}
+
+// logTransmissions adds finished transmissions to the transmission log. The event bus drops events for busy
+// subscribers, so the log gets its records directly.
+func (v *Server) logTransmissions(records ...transmissions.Record) {
+	log := v.transmissionLog.Load()
+	if log == nil {
+		return
+	}
+	for _, record := range records {
+		log.Add(record)
+	}
+}
+
+// SetTransmissionLog sets the log finished transmissions are written to, nil stops writing them
+func (v *Server) SetTransmissionLog(log *transmissions.Log) {
+	v.transmissionLog.Store(log)
+}
+
+// endClientTransmission ends the transmission of a client that left
+func (v *Server) endClientTransmission(clientID uuid.UUID) {
+	v.transmissionsMu.Lock()
+	var ended []transmissions.Record
+	if active, exists := v.transmissions[clientID]; exists {
+		ended = append(ended, v.endTransmission(clientID, active, active.lastSeen))
+	}
+	delete(v.cutOffs, clientID)
+	v.transmissionsMu.Unlock()
+	v.logTransmissions(ended...)
+}
+
+// endTimedOutTransmissions ends all transmissions whose last voice packet is older than TransmissionTimeout
+func (v *Server) endTimedOutTransmissions(now time.Time) {
+	v.transmissionsMu.Lock()
+	var ended []transmissions.Record
+	for clientID, active := range v.transmissions {
+		if now.Sub(active.lastSeen) > TransmissionTimeout {
+			ended = append(ended, v.endTransmission(clientID, active, active.lastSeen))
+		}
+	}
+	v.transmissionsMu.Unlock()
+	v.logTransmissions(ended...)
+}
+
+func (v *Server) transmissionRoutine() {
+	ticker := time.NewTicker(TransmissionTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-v.stopChan:
+			return
+		case now := <-ticker.C:
+			v.endTimedOutTransmissions(now)
+			v.endTimedOutEchoes(now)
+			v.arbiter.expire(now)
+		}
+	}
+}
+
+// isSequenceAhead reports if next comes after last, taking the 24-bit wrap around into account
+func isSequenceAhead(last, next uint32) bool {
+	distance := (next - last) & sequenceMask
+	return distance != 0 && distance < sequenceMask/2
+}
+
+// isSequenceRestart reports if next is too far behind last to be a reordered packet of the same transmission
+func isSequenceRestart(last, next uint32) bool {
+	if next == last || isSequenceAhead(last, next) {
+		return false
+	}
+	return (last-next)&sequenceMask > sequenceReorderWindow
+}
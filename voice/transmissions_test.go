@@ -0,0 +1,172 @@
+package voice
+
+import (
+	"io"
+	"log/slog"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/FPGSchiba/vcs-srs-server/events"
+	"github.com/FPGSchiba/vcs-srs-server/state"
+	"github.com/FPGSchiba/vcs-srs-server/transmissions"
+	"github.com/google/uuid"
+)
+
+func TestTrackTransmission(t *testing.T) {
+	serverState := &state.ServerState{}
+	settingsState := &state.SettingsState{
+		TransmissionLog: state.TransmissionLogSettings{Enabled: true, Directory: t.TempDir()},
+	}
+	eventBus := events.NewEventBus()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	server := NewServer(serverState, logger, &state.DistributionState{}, settingsState, eventBus)
+	transmissionLog := transmissions.NewLog(settingsState, logger)
+	server.SetTransmissionLog(transmissionLog)
+	ended := eventBus.Subscribe(events.TransmissionEnded)
+
+	id := uuid.New()
+	serverState.AddClient(id, &state.ClientState{Name: "Pilot", UnitId: "unit", Coalition: "blue"})
+	start := time.Now()
+	voice := func(sequence uint32, frequency uint32, ptt bool, at time.Duration) {
+		packet := NewVCSVoicePacket(id, sequence, frequency, nil)
+		packet.SetPTT(ptt)
+		server.trackTransmission(packet, start.Add(at))
+	}
+	expectEnded := func(frequency state.Frequency, duration time.Duration) {
+		t.Helper()
+		select {
+		case event := <-ended:
+			record := event.Data.(transmissions.Record)
+			if record.Name != "Pilot" || record.Coalition != "blue" || record.Frequency != frequency {
+				t.Errorf("unexpected record: %+v", record)
+			}
+			if record.DurationMs != duration.Milliseconds() {
+				t.Errorf("expected duration %v, got %dms", duration, record.DurationMs)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected a transmission to end")
+		}
+	}
+
+	// Released PTT ends the transmission
+	voice(1, 251000, true, 0)
+	voice(2, 251000, true, 20*time.Millisecond)
+	voice(3, 251000, false, 40*time.Millisecond)
+	expectEnded(251*state.MHz, 40*time.Millisecond)
+
+	// A frequency change ends the transmission at its last packet and starts a new one
+	voice(500, 251000, true, time.Second)
+	voice(501, 251000, true, time.Second+20*time.Millisecond)
+	voice(502, 305000, true, time.Second+40*time.Millisecond)
+	expectEnded(251*state.MHz, 20*time.Millisecond)
+
+	// Reordered packets continue the transmission, a restarted sequence does not
+	voice(501, 305000, true, time.Second+60*time.Millisecond)
+	voice(1, 305000, true, time.Second+80*time.Millisecond)
+	expectEnded(305*state.MHz, 20*time.Millisecond)
+
+	// Silence ends the transmission after the timeout
+	server.endTimedOutTransmissions(start.Add(time.Second + 80*time.Millisecond + TransmissionTimeout + time.Millisecond))
+	expectEnded(305*state.MHz, 0)
+
+	// Leaving ends the transmission
+	voice(1, 251000, true, 2*time.Second)
+	server.endClientTransmission(id)
+	expectEnded(251*state.MHz, 0)
+
+	// Every ended transmission is written to the log
+	stopChan := make(chan struct{})
+	close(stopChan)
+	transmissionLog.Run(stopChan)
+	records, err := transmissionLog.Query(transmissions.Filter{})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(records) != 5 {
+		t.Errorf("expected 5 logged transmissions, got %d", len(records))
+	}
+}
+
+func TestBlockedTalkerIsNotTracked(t *testing.T) {
+	serverState := &state.ServerState{}
+	settingsState := &state.SettingsState{
+		General:         state.GeneralSettings{SimultaneousPolicy: state.SimultaneousPolicyFirst},
+		TransmissionLog: state.TransmissionLogSettings{Enabled: true, Directory: t.TempDir()},
+	}
+	eventBus := events.NewEventBus()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	server := NewServer(serverState, logger, &state.DistributionState{}, settingsState, eventBus)
+	transmissionLog := transmissions.NewLog(settingsState, logger)
+	server.SetTransmissionLog(transmissionLog)
+	attachLoopback(t, server)
+	server.running = true
+	started := eventBus.Subscribe(events.TransmissionStarted)
+
+	first, second := uuid.New(), uuid.New()
+	addrs := map[uuid.UUID]netip.AddrPort{first: netip.MustParseAddrPort("127.0.0.1:40020"), second: netip.MustParseAddrPort("127.0.0.1:40021")}
+	for id, addr := range addrs {
+		serverState.AddClient(id, &state.ClientState{Name: id.String(), Coalition: "blue"})
+		serverState.SetRadioState(id, &state.RadioState{Radios: []state.Radio{{ID: 1, Frequency: 251 * state.MHz, Enabled: true}}})
+		hello := &VCSPacket{Magic: [3]byte{'V', 'C', 'S'}, Version: currentVersion, Type: PacketTypeHello, SenderID: id}
+		server.handlePacket(newInbound(hello, addr, nil))
+	}
+	voice := func(sender uuid.UUID, sequence uint32, ptt bool) {
+		packet := NewVCSVoicePacket(sender, sequence, 251000, []byte{0xF8, 0x01})
+		packet.SetPTT(ptt)
+		server.handlePacket(newInbound(packet, addrs[sender], nil))
+	}
+
+	// The second talker keys up while the first one transmits and is blocked
+	voice(first, 1, true)
+	voice(second, 1, true)
+	voice(second, 2, false)
+	voice(first, 2, false)
+
+	select {
+	case event := <-started:
+		if record := event.Data.(transmissions.Record); record.ClientGuid != first.String() {
+			t.Errorf("unexpected transmission start %+v", record)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the first transmission to start")
+	}
+	select {
+	case event := <-started:
+		t.Errorf("blocked talker was published as transmitting: %+v", event.Data)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	stopChan := make(chan struct{})
+	close(stopChan)
+	transmissionLog.Run(stopChan)
+	records, err := transmissionLog.Query(transmissions.Filter{})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(records) != 1 || records[0].ClientGuid != first.String() {
+		t.Errorf("expected only the first transmission to be logged, got %+v", records)
+	}
+}
+
+func TestIsSequenceRestart(t *testing.T) {
+	tests := []struct {
+		name       string
+		last, next uint32
+		want       bool
+	}{
+		{"next", 10, 11, false},
+		{"same", 10, 10, false},
+		{"reordered", 100, 90, false},
+		{"restart", 1000, 1, true},
+		{"wrap around", sequenceMask, 0, false},
+		{"reordered across wrap", 2, sequenceMask - 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isSequenceRestart(tt.last, tt.next); got != tt.want {
+				t.Errorf("isSequenceRestart(%d, %d) = %v, want %v", tt.last, tt.next, got, tt.want)
+			}
+		})
+	}
+}
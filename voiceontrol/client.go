@@ -197,6 +197,21 @@ func (v *VoiceControlClient) handleReconnection() {
 	}
 }
 
+// SendHeartbeat reports the status and the connected clients of the voice server to the control server
+func (v *VoiceControlClient) SendHeartbeat(status *pb.ServerStatus, clients []*pb.ClientInfo) error {
+	if v.client == nil {
+		return fmt.Errorf("not connected to a control server")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := v.client.SendHeartbeat(ctx, &pb.HeartbeatRequest{
+		ServerId:         v.serverId,
+		Status:           status,
+		ConnectedClients: clients,
+	})
+	return err
+}
+
 func (v *VoiceControlClient) Close() error {
 	if v.stopc != nil {
 		close(v.stopc)
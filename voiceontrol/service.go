@@ -57,3 +57,20 @@ func (s *VoiceControlServer) RegisterVoiceServer(ctx context.Context, req *pb.Re
 		AssignedFrequencies: make([]*pb.FrequencyRange, 0),
 	}, nil
 }
+
+func (s *VoiceControlServer) SendHeartbeat(ctx context.Context, req *pb.HeartbeatRequest) (*pb.HeartbeatResponse, error) {
+	status := req.GetStatus()
+	s.logger.Debug("Received heartbeat", "serverId", req.ServerId, "connections", status.GetActiveConnections(), "healthy", status.GetIsHealthy())
+	for _, quality := range status.GetSessionQuality() {
+		if quality.PacketsLost > 0 {
+			s.logger.Debug("Voice session is losing packets", "serverId", req.ServerId, "clientId", quality.ClientId,
+				"frequency", quality.Frequency, "received", quality.PacketsReceived, "lost", quality.PacketsLost, "jitterMs", quality.JitterMs)
+		}
+	}
+	for reason, count := range status.GetTransmitRejections() {
+		if count > 0 {
+			s.logger.Debug("Voice server rejected unauthorized voice packets", "serverId", req.ServerId, "reason", reason, "count", count)
+		}
+	}
+	return &pb.HeartbeatResponse{Acknowledged: true}, nil
+}